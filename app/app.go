@@ -0,0 +1,12 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"myapp/internal/scheduler"
+)
+
+// App — HTTP-сервер і планувальник, зібрані з одного графа залежностей
+type App struct {
+	Engine    *gin.Engine
+	Scheduler *scheduler.Scheduler
+}
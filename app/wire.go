@@ -4,29 +4,55 @@
 package app
 
 import (
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 	"go.uber.org/zap"
 	controllers2 "myapp/internal/http/controllers"
 	"myapp/internal/http/routes"
+	"myapp/internal/scheduler"
 	"myapp/pkg/config"
 	"myapp/pkg/database"
+	"myapp/pkg/events"
+	"myapp/pkg/notifier"
+	"myapp/pkg/provider"
 	repository2 "myapp/pkg/repository"
 	services2 "myapp/pkg/services"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
 )
 
-func InitializeApp() (*gin.Engine, error) {
+func InitializeApp(bus *events.Bus) (*App, error) {
 	wire.Build(
 
 		config.NewConfig,
 		database.Connect,
+		signedlink.NewSigner,
+		templates.New,
+
+		notifier.NewRouter,
+		wire.Bind(new(notifier.Notifier), new(*notifier.Router)),
+
+		provider.NewOpenWeatherMap,
+		wire.Bind(new(provider.WeatherProvider), new(*provider.OpenWeatherMap)),
 
 		repository2.NewGormRepo,
 		wire.Bind(new(repository2.WeatherRepository), new(*repository2.GormRepo)),
 		wire.Bind(new(repository2.SubscriptionRepository), new(*repository2.GormRepo)),
+		wire.Bind(new(repository2.WeatherHistoryRepository), new(*repository2.GormRepo)),
+		wire.Bind(new(repository2.OutboxRepository), new(*repository2.GormRepo)),
+		wire.Bind(new(repository2.DigestRepository), new(*repository2.GormRepo)),
+		wire.Bind(new(repository2.RunRepository), new(*repository2.GormRepo)),
+		wire.Bind(new(repository2.LeaseRepository), new(*repository2.GormRepo)),
 
 		services2.NewWeatherService,
+		services2.NewHistoryService,
 		services2.NewSubscriptionService,
+		services2.NewOutboxService,
+		services2.NewDigestService,
+		services2.NewNotifyService,
+		services2.NewCityEvaluator,
+		services2.NewRunService,
+		services2.NewLeaseService,
+		services2.NewFetchService,
 
 		wire.Value([]zap.Option{}),
 
@@ -34,8 +60,12 @@ func InitializeApp() (*gin.Engine, error) {
 
 		controllers2.NewWeatherController,
 		controllers2.NewSubscriptionController,
+		controllers2.NewAdminController,
 
 		routes.NewRouter,
+		scheduler.New,
+
+		wire.Struct(new(App), "*"),
 	)
-	return &gin.Engine{}, nil
+	return &App{}, nil
 }
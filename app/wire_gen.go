@@ -7,36 +7,65 @@
 package app
 
 import (
-	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"myapp/internal/http/controllers"
 	"myapp/internal/http/routes"
+	"myapp/internal/scheduler"
 	"myapp/pkg/config"
 	"myapp/pkg/database"
+	"myapp/pkg/events"
+	"myapp/pkg/notifier"
+	"myapp/pkg/provider"
 	"myapp/pkg/repository"
 	"myapp/pkg/services"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
 )
 
 // Injectors from wire.go:
 
-func InitializeApp() (*gin.Engine, error) {
+func InitializeApp(bus *events.Bus) (*App, error) {
 	configConfig := config.NewConfig()
 	db, err := database.Connect(configConfig)
 	if err != nil {
 		return nil, err
 	}
 	gormRepo := repository.NewGormRepo()
-	weatherService := services.NewWeatherService(gormRepo)
+	weatherService := services.NewWeatherService(gormRepo, bus)
+	historyService := services.NewHistoryService(gormRepo, gormRepo, configConfig)
 	v := _wireValue
 	logger, err := zap.NewProduction(v...)
 	if err != nil {
 		return nil, err
 	}
-	weatherController := controllers.NewWeatherController(weatherService, logger)
-	subscriptionService := services.NewSubscriptionService(gormRepo, gormRepo)
-	subscriptionController := controllers.NewSubscriptionController(subscriptionService, logger)
-	engine := routes.NewRouter(configConfig, db, weatherController, subscriptionController)
-	return engine, nil
+	weatherController := controllers.NewWeatherController(weatherService, historyService, logger)
+	signer, err := signedlink.NewSigner(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	renderer, err := templates.New(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	subscriptionService := services.NewSubscriptionService(gormRepo, gormRepo, signer, renderer, configConfig)
+	router := notifier.NewRouter(configConfig)
+	outboxService := services.NewOutboxService(gormRepo, gormRepo, subscriptionService, router, configConfig)
+	digestService := services.NewDigestService(gormRepo, gormRepo, signer, renderer)
+	subscriptionController := controllers.NewSubscriptionController(subscriptionService, outboxService, digestService, logger)
+	notifyService := services.NewNotifyService(gormRepo, gormRepo, signer, renderer, digestService)
+	cityEvaluator := services.NewCityEvaluator(weatherService, gormRepo, notifyService)
+	leaseService := services.NewLeaseService(gormRepo, configConfig)
+	runService := services.NewRunService(gormRepo, cityEvaluator, leaseService, configConfig)
+	adminController := controllers.NewAdminController(outboxService, runService, configConfig, logger)
+	engine := routes.NewRouter(configConfig, db, weatherController, subscriptionController, adminController)
+	openWeatherMap := provider.NewOpenWeatherMap(configConfig)
+	fetchService := services.NewFetchService(openWeatherMap, weatherService, gormRepo)
+	schedulerScheduler := scheduler.New(leaseService, cityEvaluator, runService, subscriptionService, outboxService, digestService, historyService, fetchService, configConfig)
+	appApp := &App{
+		Engine:    engine,
+		Scheduler: schedulerScheduler,
+	}
+	return appApp, nil
 }
 
 var (
@@ -4,14 +4,19 @@ package main
 import (
 	"log"
 	"myapp/app"
-	"myapp/internal/scheduler"
+	"myapp/pkg/events"
+
+	// база часових поясів для тихих годин підписок: в образі alpine її немає
+	_ "time/tzdata"
 )
 
 func main() {
-	engine, err := app.InitializeApp()
+	// спільна шина: зміни погоди з HTTP і fetcher доходять до планувальника
+	bus := events.NewBus()
+	a, err := app.InitializeApp(bus)
 	if err != nil {
 		log.Fatalf("failed to initialize app: %v", err)
 	}
-	go scheduler.Start()
-	engine.Run(":8080")
+	go a.Scheduler.Start(bus)
+	a.Engine.Run(":8080")
 }
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"myapp/pkg/config"
+	"myapp/pkg/i18n"
+	"myapp/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminController — службові ендпоінти /admin/*, доступні за Bearer-токеном ADMIN_TOKEN
+type AdminController struct {
+	Outbox *services.OutboxService
+	Runs   *services.RunService
+	Token  string
+	Logger *zap.Logger
+}
+
+func NewAdminController(
+	outbox *services.OutboxService,
+	runs *services.RunService,
+	cfg config.Config,
+	logger *zap.Logger,
+) *AdminController {
+	return &AdminController{Outbox: outbox, Runs: runs, Token: cfg.AdminToken, Logger: logger}
+}
+
+// Authorize пропускає лише запити з "Authorization: Bearer <ADMIN_TOKEN>".
+// Без налаштованого токена адмінські ендпоінти недоступні.
+func (h *AdminController) Authorize(c *gin.Context) {
+	if h.Token == "" {
+		h.errorResponse(c, http.StatusNotFound, i18n.MsgAdminDisabled)
+		c.Abort()
+		return
+	}
+	got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(h.Token)) != 1 {
+		h.errorResponse(c, http.StatusUnauthorized, i18n.MsgUnauthorized)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// ListOutbox повертає повідомлення outbox: GET /admin/outbox?status=dead&page=&per_page=
+func (h *AdminController) ListOutbox(c *gin.Context) {
+	page, perPage, ok := h.pagination(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.Outbox.List(c.Query("status"), page, perPage)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOutboxStatus) {
+			h.errorFor(c, http.StatusBadRequest, err, i18n.MsgInvalidOutboxStatus)
+		} else {
+			h.logError("ListOutbox failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: res})
+}
+
+// RetryOutbox повертає dead-повідомлення в чергу: POST /admin/outbox/:id/retry
+func (h *AdminController) RetryOutbox(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidOutboxID)
+		return
+	}
+
+	msg, err := h.Outbox.Retry(uint(id))
+	if err != nil {
+		switch {
+
+		case errors.Is(err, services.ErrOutboxNotFound):
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgOutboxNotFound)
+
+		case errors.Is(err, services.ErrInvalidOutboxStatus):
+			h.errorFor(c, http.StatusConflict, err, i18n.MsgInvalidOutboxStatus)
+
+		default:
+			h.logError("RetryOutbox failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: msg})
+}
+
+// ListRuns повертає журнал запусків обчислення підписок, новіші першими:
+// GET /admin/scheduler/runs?page=&per_page=
+func (h *AdminController) ListRuns(c *gin.Context) {
+	page, perPage, ok := h.pagination(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.Runs.List(page, perPage)
+	if err != nil {
+		h.logError("ListRuns failed", zap.Error(err))
+		h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: res})
+}
+
+// TriggerRun обчислює всі підписки зараз і відповідає записом запуску після його
+// завершення: POST /admin/scheduler/run?dry_run=true. З dry_run стан підписок не
+// змінюється і нічого не ставиться в outbox — лише видно, що було б надіслано.
+// Запуск без dry_run іде під орендою планувальника; якщо її утримує інша репліка — 409.
+func (h *AdminController) TriggerRun(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidDryRun)
+		return
+	}
+
+	run, err := h.Runs.Trigger(c.Request.Context(), dryRun)
+	if errors.Is(err, services.ErrLeaseHeld) {
+		h.errorResponse(c, http.StatusConflict, i18n.MsgSchedulerBusy)
+		return
+	}
+	if err != nil {
+		h.logError("TriggerRun failed", zap.Error(err))
+		h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: run})
+}
+
+// pagination читає page і per_page із запиту; на некоректних значеннях відповідає 400
+func (h *AdminController) pagination(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidPage)
+		return 0, 0, false
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 || perPage > services.MaxPerPage {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidPerPage, services.MaxPerPage)
+		return 0, 0, false
+	}
+	return page, perPage, true
+}
+
+// errorResponse відповідає повідомленням key з каталогу мовою клієнта
+func (h *AdminController) errorResponse(c *gin.Context, code int, key string, args ...interface{}) {
+	c.JSON(code, ResponseDTO{Status: "error", Error: i18n.T(lang(c), key, args...)})
+}
+
+// errorFor відповідає перекладом err, якщо він має ключ каталогу, інакше — fallback
+func (h *AdminController) errorFor(c *gin.Context, code int, err error, fallback string) {
+	c.JSON(code, ResponseDTO{Status: "error", Error: i18n.Message(lang(c), err, fallback)})
+}
+
+func (h *AdminController) logError(msg string, fields ...zap.Field) {
+	if h.Logger != nil {
+		h.Logger.Error(msg, fields...)
+	}
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"myapp/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lang вибирає мову відповіді за Accept-Language і позначає її в Content-Language
+func lang(c *gin.Context) string {
+	l := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", l)
+	c.Header("Vary", "Accept-Language")
+	return l
+}
@@ -5,13 +5,42 @@ import "github.com/gin-gonic/gin"
 func Register(r *gin.Engine,
 	wc *WeatherController,
 	sc *SubscriptionController,
+	ac *AdminController,
 ) {
 	// Weather
 	r.GET("/weather", wc.GetWeather)
+	r.GET("/weather/history", wc.GetHistory)
 	r.POST("/weather", wc.PostWeather)
 	r.PUT("/weather/:city", wc.UpdateWeather)
 
 	// Subscriptions
 	r.POST("/subscriptions", sc.CreateSubscription)
 	r.GET("/subscriptions/confirm", sc.ConfirmSubscription)
+	r.POST("/subscriptions/resend-confirmation", sc.ResendConfirmation)
+	r.POST("/subscriptions/manage-link", sc.RequestManageLink)
+	r.GET("/subscriptions/unsubscribe", sc.Unsubscribe)
+	r.POST("/subscriptions/unsubscribe", sc.Unsubscribe)
+	r.GET("/subscriptions/unsubscribe-all", sc.UnsubscribeAll)
+	r.POST("/subscriptions/unsubscribe-all", sc.UnsubscribeAll)
+	r.GET("/subscriptions/snooze", sc.SnoozeLink)
+	r.POST("/subscriptions/snooze", sc.SnoozeLink)
+
+	// Керування підписками адреси — за токеном із листа POST /subscriptions/manage-link
+	manage := r.Group("", sc.Authorize)
+	manage.GET("/subscriptions", sc.ListSubscriptions)
+	manage.GET("/subscriptions/:id", sc.GetSubscription)
+	manage.PATCH("/subscriptions/:id", sc.UpdateSubscription)
+	manage.DELETE("/subscriptions/:id", sc.DeleteSubscription)
+	manage.GET("/subscriptions/:id/notifications", sc.ListNotifications)
+	manage.POST("/subscriptions/:id/snooze", sc.SnoozeSubscription)
+	manage.POST("/subscriptions/:id/webhook-secret", sc.RotateWebhookSecret)
+	manage.GET("/preferences", sc.GetPreference)
+	manage.PUT("/preferences", sc.UpdatePreference)
+
+	// Admin
+	admin := r.Group("/admin", ac.Authorize)
+	admin.GET("/outbox", ac.ListOutbox)
+	admin.POST("/outbox/:id/retry", ac.RetryOutbox)
+	admin.GET("/scheduler/runs", ac.ListRuns)
+	admin.POST("/scheduler/run", ac.TriggerRun)
 }
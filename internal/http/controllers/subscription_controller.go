@@ -4,10 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"myapp/pkg/i18n"
 	"myapp/pkg/models"
 	"myapp/pkg/services"
+	"myapp/pkg/validation"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -20,33 +24,50 @@ type ResponseDTO struct {
 }
 
 type SubscriptionController struct {
-	Svc    *services.SubscriptionService
-	Logger *zap.Logger
+	Svc     *services.SubscriptionService
+	Outbox  *services.OutboxService
+	Digests *services.DigestService
+	Logger  *zap.Logger
 }
 
-func NewSubscriptionController(svc *services.SubscriptionService, logger *zap.Logger) *SubscriptionController {
-	return &SubscriptionController{Svc: svc, Logger: logger}
+func NewSubscriptionController(
+	svc *services.SubscriptionService,
+	outbox *services.OutboxService,
+	digests *services.DigestService,
+	logger *zap.Logger,
+) *SubscriptionController {
+	return &SubscriptionController{Svc: svc, Outbox: outbox, Digests: digests, Logger: logger}
 }
 
 func (h *SubscriptionController) CreateSubscription(c *gin.Context) {
 	var sub models.Subscription
 	if err := c.ShouldBindJSON(&sub); err != nil {
-		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		h.errorFor(c, http.StatusBadRequest, validation.Describe(err), i18n.MsgBadRequest)
 		return
 	}
+	// Мова листів: поле language або, якщо його немає, Accept-Language запиту
+	if sub.Language == "" {
+		sub.Language = lang(c)
+	}
 
 	if err := h.Svc.Create(&sub); err != nil {
 		switch {
 
 		case errors.Is(err, services.ErrCityNotFound):
-			h.errorResponse(c, http.StatusNotFound, err.Error())
+			h.errorFor(c, http.StatusNotFound, err, i18n.MsgCityNotFound)
+
+		case errors.Is(err, services.ErrInvalidChannel):
+			h.errorFor(c, http.StatusBadRequest, err, i18n.MsgInvalidChannel)
+
+		case errors.Is(err, services.ErrInvalidSchedule):
+			h.errorFor(c, http.StatusBadRequest, err, i18n.MsgInvalidSchedule)
 
 		case errors.Is(err, services.ErrDuplicateSubscription), strings.Contains(err.Error(), "Duplicate entry"):
-			h.errorResponse(c, http.StatusConflict, "subscription already exists")
+			h.errorResponse(c, http.StatusConflict, i18n.MsgSubscriptionExists)
 
 		default:
 			h.logError("CreateSubscription failed", zap.Error(err))
-			h.errorResponse(c, http.StatusInternalServerError, "internal server error")
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
 		}
 		return
 	}
@@ -55,8 +76,10 @@ func (h *SubscriptionController) CreateSubscription(c *gin.Context) {
 	c.JSON(http.StatusCreated, ResponseDTO{
 		Status: "success",
 		Data: gin.H{
-			"message":         "Check your email and click on the confirmation link.",
+			"message":         i18n.T(lang(c), i18n.MsgCheckEmail),
 			"subscription_id": sub.ID,
+			// ключ підпису вебхуків більше ніде не повертається
+			"webhook_secret": sub.WebhookSecret,
 		},
 	})
 }
@@ -64,7 +87,7 @@ func (h *SubscriptionController) CreateSubscription(c *gin.Context) {
 func (h *SubscriptionController) ConfirmSubscription(c *gin.Context) {
 	token := c.Query("token")
 	if token == "" {
-		h.errorResponse(c, http.StatusBadRequest, "token required")
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgTokenRequired)
 		return
 	}
 
@@ -73,14 +96,14 @@ func (h *SubscriptionController) ConfirmSubscription(c *gin.Context) {
 		switch {
 
 		case errors.Is(err, services.ErrTokenNotFound):
-			h.errorResponse(c, http.StatusNotFound, "invalid token")
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgInvalidToken)
 
 		case errors.Is(err, services.ErrTokenExpired):
-			h.errorResponse(c, http.StatusGone, "token expired")
+			h.errorResponse(c, http.StatusGone, i18n.MsgTokenExpired)
 
 		default:
 			h.logError("ConfirmSubscription failed", zap.Error(err))
-			h.errorResponse(c, http.StatusInternalServerError, "internal server error")
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
 		}
 		return
 	}
@@ -88,14 +111,481 @@ func (h *SubscriptionController) ConfirmSubscription(c *gin.Context) {
 	c.JSON(http.StatusOK, ResponseDTO{
 		Status: "success",
 		Data: gin.H{
-			"message":         "Email verified",
+			"message":         i18n.T(lang(c), i18n.MsgEmailVerified),
 			"subscription_id": confirmedSub.ID,
 		},
 	})
 }
 
-func (h *SubscriptionController) errorResponse(c *gin.Context, code int, msg string) {
-	c.JSON(code, ResponseDTO{Status: "error", Error: msg})
+// resendRequest — тіло POST /subscriptions/resend-confirmation
+type resendRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	City  string `json:"city"  binding:"required"`
+}
+
+// ResendConfirmation надсилає новий лист підтвердження з новим токеном:
+// POST /subscriptions/resend-confirmation. Частіші запити для адреси отримують 429.
+func (h *SubscriptionController) ResendConfirmation(c *gin.Context) {
+	var req resendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorFor(c, http.StatusBadRequest, validation.Describe(err), i18n.MsgBadRequest)
+		return
+	}
+
+	wait, err := h.Svc.ResendConfirmation(req.Email, req.City)
+	if err != nil {
+		switch {
+
+		case errors.Is(err, services.ErrSubscriptionNotFound):
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgSubscriptionNotFound)
+
+		case errors.Is(err, services.ErrAlreadyVerified):
+			h.errorResponse(c, http.StatusConflict, i18n.MsgAlreadyVerified)
+
+		case errors.Is(err, services.ErrResendTooSoon):
+			secs := int((wait + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(secs))
+			h.errorResponse(c, http.StatusTooManyRequests, i18n.MsgResendTooSoon, secs)
+
+		default:
+			h.logError("ResendConfirmation failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{
+		Status: "success",
+		Data:   gin.H{"message": i18n.T(lang(c), i18n.MsgCheckEmail)},
+	})
+}
+
+// manageLinkRequest — тіло POST /subscriptions/manage-link
+type manageLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RequestManageLink надсилає на адресу лист із посиланням на керування її підписками:
+// POST /subscriptions/manage-link. Відповідь однакова, є в адреси підписки чи ні;
+// частіші запити для адреси отримують 429.
+func (h *SubscriptionController) RequestManageLink(c *gin.Context) {
+	var req manageLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorFor(c, http.StatusBadRequest, validation.Describe(err), i18n.MsgBadRequest)
+		return
+	}
+
+	wait, err := h.Svc.RequestManageLink(req.Email)
+	if err != nil {
+		if errors.Is(err, services.ErrResendTooSoon) {
+			secs := int((wait + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(secs))
+			h.errorResponse(c, http.StatusTooManyRequests, i18n.MsgManageTooSoon, secs)
+		} else {
+			h.logError("RequestManageLink failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{
+		Status: "success",
+		Data:   gin.H{"message": i18n.T(lang(c), i18n.MsgManageSent)},
+	})
+}
+
+// ownerKey — ключ контексту запиту з адресою, яку підтвердив токен керування
+const ownerKey = "owner"
+
+// Authorize пропускає лише запити з дійсним токеном керування з листа
+// (POST /subscriptions/manage-link): "Authorization: Bearer <token>" або ?token=.
+// Адресу власника токена кладе в контекст запиту — див. owner.
+func (h *SubscriptionController) Authorize(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if token == "" {
+		h.errorResponse(c, http.StatusUnauthorized, i18n.MsgUnauthorized)
+		c.Abort()
+		return
+	}
+
+	email, err := h.Svc.Authorize(token)
+	if err != nil {
+		switch {
+
+		case errors.Is(err, services.ErrTokenNotFound):
+			h.errorResponse(c, http.StatusUnauthorized, i18n.MsgInvalidToken)
+
+		case errors.Is(err, services.ErrTokenExpired):
+			h.errorResponse(c, http.StatusUnauthorized, i18n.MsgTokenExpired)
+
+		default:
+			h.logError("Authorize failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		c.Abort()
+		return
+	}
+	c.Set(ownerKey, email)
+	c.Next()
+}
+
+// owner повертає адресу, підписками якої дозволяє керувати токен запиту
+func owner(c *gin.Context) string {
+	return c.GetString(ownerKey)
+}
+
+// Unsubscribe вимикає підписку за підписаним посиланням із листа.
+// GET — перехід за посиланням, POST — відписка в один клік (RFC 8058).
+func (h *SubscriptionController) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgTokenRequired)
+		return
+	}
+
+	sub, err := h.Svc.Unsubscribe(token)
+	if err != nil {
+		switch {
+
+		case errors.Is(err, services.ErrTokenNotFound):
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgInvalidToken)
+
+		case errors.Is(err, services.ErrTokenExpired):
+			h.errorResponse(c, http.StatusGone, i18n.MsgTokenExpired)
+
+		default:
+			h.logError("Unsubscribe failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{
+		Status: "success",
+		Data: gin.H{
+			"message":         i18n.T(lang(c), i18n.MsgUnsubscribed),
+			"subscription_id": sub.ID,
+		},
+	})
+}
+
+// UnsubscribeAll вимикає всі підписки адреси за підписаним посиланням із дайджесту.
+// GET — перехід за посиланням, POST — відписка в один клік (RFC 8058).
+func (h *SubscriptionController) UnsubscribeAll(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgTokenRequired)
+		return
+	}
+
+	n, err := h.Svc.UnsubscribeAll(token)
+	if err != nil {
+		switch {
+
+		case errors.Is(err, services.ErrTokenNotFound):
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgInvalidToken)
+
+		case errors.Is(err, services.ErrTokenExpired):
+			h.errorResponse(c, http.StatusGone, i18n.MsgTokenExpired)
+
+		default:
+			h.logError("UnsubscribeAll failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{
+		Status: "success",
+		Data: gin.H{
+			"message":      i18n.T(lang(c), i18n.MsgUnsubscribed),
+			"unsubscribed": n,
+		},
+	})
+}
+
+// SnoozeLink призупиняє підписку на добу за підписаним посиланням із листа:
+// GET або POST /subscriptions/snooze?token=
+func (h *SubscriptionController) SnoozeLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgTokenRequired)
+		return
+	}
+
+	sub, err := h.Svc.SnoozeByToken(token)
+	if err != nil {
+		switch {
+
+		case errors.Is(err, services.ErrTokenNotFound):
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgInvalidToken)
+
+		case errors.Is(err, services.ErrTokenExpired):
+			h.errorResponse(c, http.StatusGone, i18n.MsgTokenExpired)
+
+		default:
+			h.logError("SnoozeLink failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{
+		Status: "success",
+		Data: gin.H{
+			"message":         i18n.T(lang(c), i18n.MsgSnoozed, sub.PausedUntil.Format(time.RFC3339)),
+			"subscription_id": sub.ID,
+			"paused_until":    sub.PausedUntil,
+		},
+	})
+}
+
+// SnoozeSubscription призупиняє підписку на тривалість for (напр. 48h);
+// for=0 знімає паузу: POST /subscriptions/:id/snooze?for=
+func (h *SubscriptionController) SnoozeSubscription(c *gin.Context) {
+	id, ok := h.paramID(c)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(c.Query("for"))
+	if err != nil {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidSnooze, int(services.MaxSnooze/(24*time.Hour)))
+		return
+	}
+
+	sub, err := h.Svc.Snooze(owner(c), id, d)
+	if err != nil {
+		h.subscriptionError(c, "SnoozeSubscription failed", err)
+		return
+	}
+
+	msg := i18n.T(lang(c), i18n.MsgResumed)
+	if sub.PausedUntil != nil {
+		msg = i18n.T(lang(c), i18n.MsgSnoozed, sub.PausedUntil.Format(time.RFC3339))
+	}
+	c.JSON(http.StatusOK, ResponseDTO{
+		Status: "success",
+		Data: gin.H{
+			"message":         msg,
+			"subscription_id": sub.ID,
+			"paused_until":    sub.PausedUntil,
+		},
+	})
+}
+
+// RotateWebhookSecret видає підписці новий ключ підпису вебхуків і повертає його
+// один раз: POST /subscriptions/:id/webhook-secret
+func (h *SubscriptionController) RotateWebhookSecret(c *gin.Context) {
+	id, ok := h.paramID(c)
+	if !ok {
+		return
+	}
+
+	secret, err := h.Svc.RotateWebhookSecret(owner(c), id)
+	if err != nil {
+		h.subscriptionError(c, "RotateWebhookSecret failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{
+		Status: "success",
+		Data: gin.H{
+			"subscription_id": id,
+			"webhook_secret":  secret,
+		},
+	})
+}
+
+// GetSubscription повертає підписку: GET /subscriptions/:id
+func (h *SubscriptionController) GetSubscription(c *gin.Context) {
+	id, ok := h.paramID(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.Svc.Get(owner(c), id)
+	if err != nil {
+		h.subscriptionError(c, "GetSubscription failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: sub})
+}
+
+// ListSubscriptions повертає посторінково підписки адреси, якій видано токен:
+// GET /subscriptions?page=&per_page=
+func (h *SubscriptionController) ListSubscriptions(c *gin.Context) {
+	page, perPage, ok := h.pagination(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.Svc.List(owner(c), page, perPage)
+	if err != nil {
+		h.logError("ListSubscriptions failed", zap.Error(err))
+		h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: res})
+}
+
+// UpdateSubscription частково оновлює підписку: PATCH /subscriptions/:id
+func (h *SubscriptionController) UpdateSubscription(c *gin.Context) {
+	id, ok := h.paramID(c)
+	if !ok {
+		return
+	}
+	var p services.SubscriptionPatch
+	if err := c.ShouldBindJSON(&p); err != nil {
+		h.errorFor(c, http.StatusBadRequest, validation.Describe(err), i18n.MsgBadRequest)
+		return
+	}
+
+	sub, err := h.Svc.Update(owner(c), id, p)
+	if err != nil {
+		h.subscriptionError(c, "UpdateSubscription failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: sub})
+}
+
+// DeleteSubscription видаляє підписку: DELETE /subscriptions/:id
+func (h *SubscriptionController) DeleteSubscription(c *gin.Context) {
+	id, ok := h.paramID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Svc.Delete(owner(c), id); err != nil {
+		h.subscriptionError(c, "DeleteSubscription failed", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListNotifications повертає журнал сповіщень підписки (вид, стан доставки, час):
+// GET /subscriptions/:id/notifications?page=&per_page=
+func (h *SubscriptionController) ListNotifications(c *gin.Context) {
+	id, ok := h.paramID(c)
+	if !ok {
+		return
+	}
+	page, perPage, ok := h.pagination(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.Outbox.Notifications(owner(c), id, page, perPage)
+	if err != nil {
+		h.subscriptionError(c, "ListNotifications failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: res})
+}
+
+// GetPreference повертає режим доставки адреси, якій видано токен: GET /preferences
+func (h *SubscriptionController) GetPreference(c *gin.Context) {
+	p, err := h.Digests.Preference(owner(c))
+	if err != nil {
+		h.logError("GetPreference failed", zap.Error(err))
+		h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: p})
+}
+
+// preferenceRequest — тіло PUT /preferences; адресу визначає токен керування
+type preferenceRequest struct {
+	Delivery string `json:"delivery" binding:"required,oneof=immediate hourly daily"`
+}
+
+// UpdatePreference змінює режим доставки адреси, якій видано токен
+// (immediate, hourly, daily): PUT /preferences
+func (h *SubscriptionController) UpdatePreference(c *gin.Context) {
+	var req preferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorFor(c, http.StatusBadRequest, validation.Describe(err), i18n.MsgBadRequest)
+		return
+	}
+
+	p, err := h.Digests.SetPreference(owner(c), req.Delivery)
+	if err != nil {
+		h.subscriptionError(c, "UpdatePreference failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: p})
+}
+
+// pagination розбирає page і per_page; у разі помилки відповідає 400
+func (h *SubscriptionController) pagination(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidPage)
+		return 0, 0, false
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if err != nil || perPage < 1 || perPage > services.MaxPerPage {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidPerPage, services.MaxPerPage)
+		return 0, 0, false
+	}
+	return page, perPage, true
+}
+
+// paramID розбирає :id з шляху; у разі помилки відповідає 400
+func (h *SubscriptionController) paramID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidSubscriptionID)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// subscriptionError відображає помилки сервісу підписок на HTTP-статуси
+func (h *SubscriptionController) subscriptionError(c *gin.Context, msg string, err error) {
+	switch {
+
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		h.errorResponse(c, http.StatusNotFound, i18n.MsgSubscriptionNotFound)
+
+	case errors.Is(err, services.ErrCityNotFound):
+		h.errorFor(c, http.StatusNotFound, err, i18n.MsgCityNotFound)
+
+	case errors.Is(err, services.ErrInvalidChannel):
+		h.errorFor(c, http.StatusBadRequest, err, i18n.MsgInvalidChannel)
+
+	case errors.Is(err, services.ErrInvalidSchedule):
+		h.errorFor(c, http.StatusBadRequest, err, i18n.MsgInvalidSchedule)
+
+	case errors.Is(err, services.ErrInvalidSnooze):
+		h.errorFor(c, http.StatusBadRequest, err, i18n.MsgBadRequest)
+
+	case errors.Is(err, services.ErrDuplicateSubscription), strings.Contains(err.Error(), "Duplicate entry"):
+		h.errorResponse(c, http.StatusConflict, i18n.MsgSubscriptionExists)
+
+	default:
+		h.logError(msg, zap.Error(err))
+		h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+	}
+}
+
+// errorResponse відповідає повідомленням key з каталогу мовою клієнта
+func (h *SubscriptionController) errorResponse(c *gin.Context, code int, key string, args ...interface{}) {
+	c.JSON(code, ResponseDTO{Status: "error", Error: i18n.T(lang(c), key, args...)})
+}
+
+// errorFor відповідає перекладом err, якщо він має ключ каталогу, інакше — fallback
+func (h *SubscriptionController) errorFor(c *gin.Context, code int, err error, fallback string) {
+	c.JSON(code, ResponseDTO{Status: "error", Error: i18n.Message(lang(c), err, fallback)})
 }
 
 func (h *SubscriptionController) logError(msg string, fields ...zap.Field) {
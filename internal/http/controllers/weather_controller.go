@@ -4,37 +4,41 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"myapp/pkg/i18n"
 	"myapp/pkg/models"
 	"myapp/pkg/services"
+	"myapp/pkg/validation"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 type WeatherController struct {
-	Svc    *services.WeatherService
-	Logger *zap.Logger
+	Svc     *services.WeatherService
+	History *services.HistoryService
+	Logger  *zap.Logger
 }
 
-func NewWeatherController(svc *services.WeatherService, logger *zap.Logger) *WeatherController {
-	return &WeatherController{Svc: svc, Logger: logger}
+func NewWeatherController(svc *services.WeatherService, history *services.HistoryService, logger *zap.Logger) *WeatherController {
+	return &WeatherController{Svc: svc, History: history, Logger: logger}
 }
 
 func (h *WeatherController) GetWeather(c *gin.Context) {
 	city := c.Query("city")
 	if city == "" {
-		h.errorResponse(c, http.StatusBadRequest, "city required")
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgCityRequired)
 		return
 	}
 
 	w, err := h.Svc.GetCurrentWeather(city)
 	if err != nil {
 		if errors.Is(err, services.ErrCityNotFound) {
-			h.errorResponse(c, http.StatusNotFound, "city not found")
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgCityNotFound)
 		} else {
 			h.logError("GetWeather failed", zap.Error(err))
-			h.errorResponse(c, http.StatusInternalServerError, "internal server error")
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
 		}
 		return
 	}
@@ -45,16 +49,16 @@ func (h *WeatherController) GetWeather(c *gin.Context) {
 func (h *WeatherController) PostWeather(c *gin.Context) {
 	var w models.Weather
 	if err := c.ShouldBindJSON(&w); err != nil {
-		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		h.errorFor(c, http.StatusBadRequest, validation.Describe(err), i18n.MsgBadRequest)
 		return
 	}
 
 	if err := h.Svc.SaveWeather(&w); err != nil {
 		if errors.Is(err, services.ErrCityNotFound) {
-			h.errorResponse(c, http.StatusNotFound, "city not found")
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgCityNotFound)
 		} else {
 			h.logError("SaveWeather failed", zap.Error(err))
-			h.errorResponse(c, http.StatusInternalServerError, "internal server error")
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
 		}
 		return
 	}
@@ -67,17 +71,17 @@ func (h *WeatherController) UpdateWeather(c *gin.Context) {
 	city := c.Param("city")
 	var inp services.UpdateInput
 	if err := c.ShouldBindJSON(&inp); err != nil {
-		h.errorResponse(c, http.StatusBadRequest, err.Error())
+		h.errorFor(c, http.StatusBadRequest, validation.Describe(err), i18n.MsgBadRequest)
 		return
 	}
 
 	w, err := h.Svc.UpdateWeather(city, inp)
 	if err != nil {
 		if errors.Is(err, services.ErrCityNotFound) {
-			h.errorResponse(c, http.StatusNotFound, "city not found")
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgCityNotFound)
 		} else {
 			h.logError("UpdateWeather failed", zap.Error(err))
-			h.errorResponse(c, http.StatusInternalServerError, "internal server error")
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
 		}
 		return
 	}
@@ -85,8 +89,67 @@ func (h *WeatherController) UpdateWeather(c *gin.Context) {
 	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: w})
 }
 
-func (h *WeatherController) errorResponse(c *gin.Context, code int, msg string) {
-	c.JSON(code, ResponseDTO{Status: "error", Error: msg})
+// GetHistory повертає історію погоди: GET /weather/history?city=&from=&to=&step=
+// from/to — RFC3339 (за замовчуванням останні 24 години), step — тривалість (1h, 15m).
+func (h *WeatherController) GetHistory(c *gin.Context) {
+	city := c.Query("city")
+	if city == "" {
+		h.errorResponse(c, http.StatusBadRequest, i18n.MsgCityRequired)
+		return
+	}
+
+	q := services.HistoryQuery{City: city, To: time.Now()}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidTo)
+			return
+		}
+		q.To = t
+	}
+	q.From = q.To.Add(-24 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidFrom)
+			return
+		}
+		q.From = t
+	}
+	if v := c.Query("step"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			h.errorResponse(c, http.StatusBadRequest, i18n.MsgInvalidStep)
+			return
+		}
+		q.Step = d
+	}
+
+	res, err := h.History.Query(q)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCityNotFound):
+			h.errorResponse(c, http.StatusNotFound, i18n.MsgCityNotFound)
+		case errors.Is(err, services.ErrInvalidRange):
+			h.errorFor(c, http.StatusBadRequest, err, i18n.MsgInvalidRange)
+		default:
+			h.logError("GetHistory failed", zap.Error(err))
+			h.errorResponse(c, http.StatusInternalServerError, i18n.MsgInternal)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseDTO{Status: "success", Data: res})
+}
+
+// errorResponse відповідає повідомленням key з каталогу мовою клієнта
+func (h *WeatherController) errorResponse(c *gin.Context, code int, key string, args ...interface{}) {
+	c.JSON(code, ResponseDTO{Status: "error", Error: i18n.T(lang(c), key, args...)})
+}
+
+// errorFor відповідає перекладом err, якщо він має ключ каталогу, інакше — fallback
+func (h *WeatherController) errorFor(c *gin.Context, code int, err error, fallback string) {
+	c.JSON(code, ResponseDTO{Status: "error", Error: i18n.Message(lang(c), err, fallback)})
 }
 
 func (h *WeatherController) logError(msg string, fields ...zap.Field) {
@@ -16,6 +16,7 @@ func NewRouter(
 	db *gorm.DB,
 	wc *controllers2.WeatherController,
 	sc *controllers2.SubscriptionController,
+	ac *controllers2.AdminController,
 ) *gin.Engine {
 	database.DB = db
 
@@ -24,6 +25,6 @@ func NewRouter(
 	}
 
 	r := gin.Default()
-	controllers2.Register(r, wc, sc)
+	controllers2.Register(r, wc, sc, ac)
 	return r
 }
@@ -1,63 +1,163 @@
 package scheduler
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
 
 	"github.com/robfig/cron/v3"
-	"myapp/pkg/database"
-	"myapp/pkg/repository"
+	"myapp/pkg/config"
+	"myapp/pkg/events"
+	"myapp/pkg/models"
 	"myapp/pkg/services"
 )
 
-func Start() {
+// outboxSchedule — як часто диспетчер перевіряє outbox
+const outboxSchedule = "@every 10s"
 
+// digestSchedule — як часто перевіряються адреси, яким час отримати дайджест
+const digestSchedule = "@every 5m"
+
+// Scheduler — фонові завдання одного процесу. Кожен такт виконується лише під
+// орендою в БД, тож із кількох реплік сповіщення обчислює й надсилає одна,
+// а погоду із зовнішнього API запитує теж лише вона.
+type Scheduler struct {
+	Lease     *services.LeaseService
+	Evaluator *services.CityEvaluator
+	Runs      *services.RunService
+	Subs      *services.SubscriptionService
+	Outbox    *services.OutboxService
+	Digests   *services.DigestService
+	History   *services.HistoryService
+	Fetch     *services.FetchService
+	// FetchSchedule — розклад оновлення погоди; порожній вимикає fetcher
+	FetchSchedule string
+}
+
+// New збирає планувальник із сервісів графа wire, тож посилання в листах
+// підписує той самий Signer, що й перевіряє HTTP-частина
+func New(
+	lease *services.LeaseService,
+	evaluator *services.CityEvaluator,
+	runs *services.RunService,
+	subs *services.SubscriptionService,
+	outbox *services.OutboxService,
+	digests *services.DigestService,
+	history *services.HistoryService,
+	fetch *services.FetchService,
+	cfg config.Config,
+) *Scheduler {
+	return &Scheduler{
+		Lease:         lease,
+		Evaluator:     evaluator,
+		Runs:          runs,
+		Subs:          subs,
+		Outbox:        outbox,
+		Digests:       digests,
+		History:       history,
+		Fetch:         fetch,
+		FetchSchedule: cfg.FetchSchedule,
+	}
+}
+
+// Tick виконує job під орендою планувальника; false — оренду утримує інша репліка
+func (s *Scheduler) Tick(job func(ctx context.Context)) bool {
+	ran, err := s.Lease.Do(context.Background(), job)
+	if err != nil {
+		log.Println("scheduler lease error:", err)
+	}
+	return ran
+}
+
+// Evaluate обчислює всі підписки містами в EVAL_WORKERS паралельних обробниках
+// і записує запуск у журнал; запуск, що не вклався в EVAL_TIMEOUT, зупиняється,
+// решту візьме наступний
+func (s *Scheduler) Evaluate(ctx context.Context) {
+	if _, err := s.Runs.Run(ctx, models.RunCron, false); err != nil {
+		log.Println("evaluation error:", err)
+	}
+}
+
+// Dispatch доставляє сповіщення з outbox
+func (s *Scheduler) Dispatch(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if _, err := s.Outbox.DispatchDue(ctx, time.Now()); err != nil {
+		log.Println("outbox dispatch error:", err)
+	}
+}
+
+// SendDigests збирає відкладені сповіщення адрес в один лист
+func (s *Scheduler) SendDigests(ctx context.Context) {
+	if _, err := s.Digests.SendDigests(ctx, time.Now()); err != nil {
+		log.Println("digest error:", err)
+	}
+}
+
+// FetchWeather оновлює погоду міст із підписками; кожне збережене місто
+// публікується в bus і обчислюється одразу
+func (s *Scheduler) FetchWeather(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	if _, err := s.Fetch.RefreshSubscribedCities(ctx); err != nil {
+		log.Println("weather fetch error:", err)
+	}
+}
+
+// PruneHistory очищає історію погоди за HISTORY_RETENTION
+func (s *Scheduler) PruneHistory(context.Context) {
+	if _, err := s.History.Prune(time.Now()); err != nil {
+		log.Println("history prune error:", err)
+	}
+}
+
+// PurgeUnverified видаляє непідтверджені підписки після UNVERIFIED_GRACE
+func (s *Scheduler) PurgeUnverified(context.Context) {
+	if _, err := s.Subs.PurgeUnverified(time.Now()); err != nil {
+		log.Println("unverified purge error:", err)
+	}
+}
+
+// Start запускає cron-завдання; підписки міста, погоду якого змінено,
+// обчислюються одразу за подією з bus, не чекаючи на CRON_SCHEDULE.
+func (s *Scheduler) Start(bus *events.Bus) {
+
+	// такт лише знаходить підписки, час яких настав; як часто обчислюється
+	// кожна з них, задає її interval_minutes
 	spec := os.Getenv("CRON_SCHEDULE")
 	if spec == "" {
 		spec = "@daily"
 	}
 
-	repo := repository.NewGormRepo()
-	ws := services.NewWeatherService(repo)
-	ss := services.NewSubscriptionService(repo, repo)
+	// обчислення за подією, як і такт, виконується під орендою
+	bus.Subscribe(s.Evaluator.Enqueue)
+	go s.Evaluator.Run(context.Background(), s.Lease)
 
 	c := cron.New(cron.WithSeconds())
 
-	job := func() {
-		now := time.Now()
-		subs, err := ss.ListVerified()
-		if err != nil {
-			log.Println("subscription fetch error:", err)
-			return
-		}
-		for _, sub := range subs {
-
-			if sub.LastSent != nil && now.Sub(*sub.LastSent) < 24*time.Hour {
-				continue
-			}
-
-			w, err := ws.GetCurrentWeather(sub.City)
-			if err != nil {
-				log.Println("weather fetch error:", err)
-				continue
-			}
-
-			sent, err := services.EvaluateAndNotify(sub, w)
-			if err != nil {
-				log.Println("notify error:", err)
-				continue
-			}
-			if sent {
-
-				database.DB.Model(&sub).Update("LastSent", now)
-			}
-		}
-	}
-
-	if _, err := c.AddFunc(spec, job); err != nil {
+	if _, err := c.AddFunc(spec, func() { s.Tick(s.Evaluate) }); err != nil {
 		log.Fatalf("invalid CRON_SCHEDULE %q: %v", spec, err)
 	}
+	if _, err := c.AddFunc("@hourly", func() { s.Tick(s.PruneHistory) }); err != nil {
+		log.Fatalf("history prune job: %v", err)
+	}
+	if _, err := c.AddFunc("@hourly", func() { s.Tick(s.PurgeUnverified) }); err != nil {
+		log.Fatalf("unverified purge job: %v", err)
+	}
+	if _, err := c.AddFunc(outboxSchedule, func() { s.Tick(s.Dispatch) }); err != nil {
+		log.Fatalf("outbox dispatch job: %v", err)
+	}
+	if _, err := c.AddFunc(digestSchedule, func() { s.Tick(s.SendDigests) }); err != nil {
+		log.Fatalf("digest job: %v", err)
+	}
+	// fetcher теж під орендою: кожна репліка інакше витрачала б квоту API
+	// на ті самі міста й записувала б ту саму історію
+	if s.FetchSchedule == "" {
+		log.Println("weather fetcher disabled: FETCH_SCHEDULE is empty")
+	} else if _, err := c.AddFunc(s.FetchSchedule, func() { s.Tick(s.FetchWeather) }); err != nil {
+		log.Fatalf("invalid FETCH_SCHEDULE %q: %v", s.FetchSchedule, err)
+	}
 
 	c.Start()
 }
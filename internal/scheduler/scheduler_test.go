@@ -0,0 +1,215 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"myapp/internal/scheduler"
+	"myapp/pkg/config"
+	"myapp/pkg/database"
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/repository"
+	"myapp/pkg/services"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
+)
+
+// countingNotifier рахує надіслані повідомлення
+type countingNotifier struct {
+	mu   sync.Mutex
+	sent []uint
+}
+
+func (n *countingNotifier) Notify(_ context.Context, sub *models.Subscription, _ notifier.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, sub.ID)
+	return nil
+}
+
+// openDB відкриває спільну для обох екземплярів базу SQLite у файлі
+func openDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "app.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	// SQLite пише в один потік; без цього паралельні транзакції отримують SQLITE_BUSY
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+}
+
+// newScheduler збирає планувальник так само, як wire, але з власним notifier
+func newScheduler(t *testing.T, cfg config.Config, n notifier.Notifier) *scheduler.Scheduler {
+	t.Helper()
+	links, err := signedlink.NewSigner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := templates.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewGormRepo()
+	ws := services.NewWeatherService(repo, nil)
+	dg := services.NewDigestService(repo, repo, links, tmpl)
+	ns := services.NewNotifyService(repo, repo, links, tmpl, dg)
+	ev := services.NewCityEvaluator(ws, repo, ns)
+	ss := services.NewSubscriptionService(repo, repo, links, tmpl, cfg)
+	lease := services.NewLeaseService(repo, cfg)
+	return scheduler.New(
+		lease,
+		ev,
+		services.NewRunService(repo, ev, lease, cfg),
+		ss,
+		services.NewOutboxService(repo, repo, ss, n, cfg),
+		dg,
+		services.NewHistoryService(repo, repo, cfg),
+		services.NewFetchService(nil, ws, repo),
+		cfg,
+	)
+}
+
+func TestScheduler_TwoInstancesSendOnce(t *testing.T) {
+	openDB(t)
+	database.DB.Create(&models.Weather{City: "Kyiv", Temperature: -5, Humidity: 80, Condition: "Snow"})
+	database.DB.Create(&models.Subscription{Email: "a@b.c", City: "Kyiv", Condition: "temp < 0", Verified: true})
+
+	cfg := config.Config{
+		EvalWorkers:       2,
+		EvalTimeout:       time.Minute,
+		LeaseTTL:          time.Minute,
+		OutboxMaxAttempts: 3,
+		OutboxBackoff:     time.Second,
+		BaseURL:           "http://localhost",
+		AppSecret:         "secret",
+	}
+	n := &countingNotifier{}
+	var instances []*scheduler.Scheduler
+	for i := 0; i < 2; i++ {
+		instances = append(instances, newScheduler(t, cfg, n))
+	}
+
+	ran := make([]int, len(instances))
+	var wg sync.WaitGroup
+	for i, s := range instances {
+		wg.Add(1)
+		go func(i int, s *scheduler.Scheduler) {
+			defer wg.Done()
+			for _, job := range []func(context.Context){s.Evaluate, s.Dispatch} {
+				if s.Tick(job) {
+					ran[i]++
+				}
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	if len(n.sent) != 1 {
+		t.Fatalf("want exactly one alert sent, got %d", len(n.sent))
+	}
+	if ran[0]+ran[1] != 2 || ran[0]*ran[1] != 0 {
+		t.Errorf("ticks must run on one instance only, got %v", ran)
+	}
+
+	var runs []models.SchedulerRun
+	database.DB.Find(&runs)
+	if len(runs) != 1 || runs[0].Evaluated != 1 || runs[0].Sent != 1 || runs[0].FinishedAt == nil {
+		t.Errorf("want one finished run with one alert, got %+v", runs)
+	}
+
+	var lease models.Lease
+	database.DB.First(&lease, "name = ?", "scheduler")
+	if lease.Token != 1 || lease.Holder == "" {
+		t.Errorf("want lease held with token 1, got %+v", lease)
+	}
+}
+
+// Процес, що втратив оренду, не може записати нічого з того, що вже зробив
+// новий власник: fencing token перевіряється тим самим UPDATE, що пише
+func TestScheduler_StaleHolderWritesAreFenced(t *testing.T) {
+	openDB(t)
+	sub := models.Subscription{Email: "a@b.c", City: "Kyiv", Condition: "temp < 0", Verified: true}
+	database.DB.Create(&sub)
+	held := models.OutboxMessage{SubscriptionID: sub.ID, Recipient: sub.Email, Kind: notifier.KindAlert, Status: models.OutboxHeld}
+	database.DB.Create(&held)
+	pref := models.DeliveryPreference{Email: sub.Email, Delivery: models.DeliveryDaily}
+	database.DB.Create(&pref)
+
+	repo := repository.NewGormRepo()
+	now := time.Now()
+	oldToken, _, _ := repo.AcquireLease(services.SchedulerLease, "a", time.Minute, now)
+	// оренда a спливла (напр. довга пауза GC), і її захопив b
+	newToken, ok, _ := repo.AcquireLease(services.SchedulerLease, "b", time.Minute, now.Add(2*time.Minute))
+	if !ok || newToken == oldToken {
+		t.Fatalf("b must take over the lease with a new token, got ok=%v token=%d", ok, newToken)
+	}
+	stale := &repository.Fence{Name: services.SchedulerLease, Holder: "a", Token: oldToken}
+
+	fired := sub
+	fired.AlertState = models.AlertStateFired
+	saved, err := repo.SaveAlertState(&fired, models.AlertStateCleared, &models.OutboxMessage{Kind: notifier.KindAlert, Status: models.OutboxPending}, stale)
+	if err != nil || saved {
+		t.Errorf("stale holder must not save alert state, got saved=%v err=%v", saved, err)
+	}
+
+	msg := held
+	msg.Status = models.OutboxSent
+	if err := repo.SaveOutbox(&msg, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	msg.Status = models.OutboxDigested
+	digest := &models.OutboxMessage{Recipient: sub.Email, Kind: notifier.KindDigest, Status: models.OutboxPending}
+	if err := repo.SaveDigest(&pref, digest, []models.OutboxMessage{msg}, now, stale); !errors.Is(err, repository.ErrFenced) {
+		t.Errorf("want ErrFenced for a stale digest, got %v", err)
+	}
+
+	// лист підтвердження, надісланий новим власником, має лишитися чинним
+	exp := now.Add(time.Hour)
+	sent := models.SubscriptionToken{SubscriptionID: sub.ID, Purpose: models.TokenConfirm, Hash: "sent", ExpiresAt: &exp}
+	database.DB.Create(&sent)
+	retry := &models.SubscriptionToken{SubscriptionID: sub.ID, Purpose: models.TokenConfirm, Hash: "retry", ExpiresAt: &exp}
+	if err := repo.ReplaceToken(retry, stale); !errors.Is(err, repository.ErrFenced) {
+		t.Errorf("want ErrFenced for a stale token, got %v", err)
+	}
+	if tok, _ := repo.FindToken("sent"); tok == nil {
+		t.Error("stale holder must not revoke the token of the current one")
+	}
+
+	var got models.Subscription
+	database.DB.First(&got, sub.ID)
+	var msgs []models.OutboxMessage
+	database.DB.Order("id").Find(&msgs)
+	if got.AlertState == models.AlertStateFired || len(msgs) != 1 || msgs[0].Status != models.OutboxHeld {
+		t.Errorf("stale writes must leave the database unchanged, got state=%q outbox=%+v", got.AlertState, msgs)
+	}
+
+	// новий власник пише як звичайно
+	current := &repository.Fence{Name: services.SchedulerLease, Holder: "b", Token: newToken}
+	if saved, err := repo.SaveAlertState(&fired, models.AlertStateCleared, nil, current); err != nil || !saved {
+		t.Errorf("current holder must save alert state, got saved=%v err=%v", saved, err)
+	}
+	if err := repo.ReplaceToken(retry, current); err != nil {
+		t.Fatalf("current holder must replace the token, got %v", err)
+	}
+	if tok, _ := repo.FindToken("retry"); tok == nil || tok.ExpiresAt == nil {
+		t.Errorf("want the new token saved, got %+v", tok)
+	}
+	if tok, _ := repo.FindToken("sent"); tok != nil {
+		t.Error("previous token must be revoked")
+	}
+}
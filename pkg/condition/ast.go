@@ -0,0 +1,180 @@
+package condition
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field — поле погоди, на яке може посилатися умова
+type Field int
+
+const (
+	FieldTemp Field = iota
+	FieldHumidity
+	FieldCondition
+)
+
+var fieldNames = map[string]Field{
+	"temp":        FieldTemp,
+	"temperature": FieldTemp,
+	"humidity":    FieldHumidity,
+	"condition":   FieldCondition,
+}
+
+func (f Field) String() string {
+	switch f {
+	case FieldTemp:
+		return "temp"
+	case FieldHumidity:
+		return "humidity"
+	case FieldCondition:
+		return "condition"
+	}
+	return "unknown"
+}
+
+// Numeric повідомляє, чи поле порівнюється як число
+func (f Field) Numeric() bool {
+	return f == FieldTemp || f == FieldHumidity
+}
+
+// Op — оператор порівняння
+type Op string
+
+const (
+	OpLT Op = "<"
+	OpLE Op = "<="
+	OpGT Op = ">"
+	OpGE Op = ">="
+	OpEQ Op = "="
+	OpNE Op = "!="
+)
+
+var opNames = map[string]Op{
+	"<":  OpLT,
+	"<=": OpLE,
+	">":  OpGT,
+	">=": OpGE,
+	"=":  OpEQ,
+	"==": OpEQ,
+	"!=": OpNE,
+}
+
+// Expr — вузол дерева розбору умови
+type Expr interface {
+	String() string
+	expr()
+}
+
+// Comparison порівнює поле погоди з константою.
+// Для числових полів заповнено Num, для текстових — Str.
+type Comparison struct {
+	Field Field
+	Op    Op
+	Num   float64
+	Str   string
+}
+
+// And — кон'юнкція двох умов
+type And struct {
+	Left, Right Expr
+}
+
+// Or — диз'юнкція двох умов
+type Or struct {
+	Left, Right Expr
+}
+
+// Not — заперечення умови
+type Not struct {
+	X Expr
+}
+
+// Delta порівнює зміну числового поля за вікно Window з константою:
+// delta(temp, 6h) <= -10 — температура впала щонайменше на 10 за 6 годин.
+type Delta struct {
+	Field  Field
+	Window time.Duration
+	Op     Op
+	Num    float64
+}
+
+// Sustained вимагає, щоб умова X виконувалася безперервно протягом For
+type Sustained struct {
+	X   Expr
+	For time.Duration
+}
+
+func (*Comparison) expr() {}
+func (*And) expr()        {}
+func (*Or) expr()         {}
+func (*Not) expr()        {}
+func (*Sustained) expr()  {}
+func (*Delta) expr()      {}
+
+func (c *Comparison) String() string {
+	if c.Field.Numeric() {
+		return c.Field.String() + " " + string(c.Op) + " " + strconv.FormatFloat(c.Num, 'f', -1, 64)
+	}
+	v := c.Str
+	if strings.ContainsAny(v, " \t()") {
+		v = strconv.Quote(v)
+	}
+	return c.Field.String() + " " + string(c.Op) + " " + v
+}
+
+func (e *And) String() string { return "(" + e.Left.String() + " AND " + e.Right.String() + ")" }
+func (e *Or) String() string  { return "(" + e.Left.String() + " OR " + e.Right.String() + ")" }
+func (e *Not) String() string { return "NOT " + e.X.String() }
+func (e *Delta) String() string {
+	return "delta(" + e.Field.String() + ", " + formatDuration(e.Window) + ") " + string(e.Op) + " " + strconv.FormatFloat(e.Num, 'f', -1, 64)
+}
+func (e *Sustained) String() string {
+	return e.X.String() + " FOR " + formatDuration(e.For)
+}
+
+// formatDuration друкує тривалість без нульових хвостів: 3h, 90m → 1h30m
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// Fields повертає поля, на які посилається умова, у порядку першої появи
+func Fields(e Expr) []Field {
+	var out []Field
+	seen := map[Field]bool{}
+	var walk func(Expr)
+	walk = func(e Expr) {
+		switch n := e.(type) {
+		case *And:
+			walk(n.Left)
+			walk(n.Right)
+		case *Or:
+			walk(n.Left)
+			walk(n.Right)
+		case *Not:
+			walk(n.X)
+		case *Sustained:
+			walk(n.X)
+		case *Delta:
+			if !seen[n.Field] {
+				seen[n.Field] = true
+				out = append(out, n.Field)
+			}
+		case *Comparison:
+			if !seen[n.Field] {
+				seen[n.Field] = true
+				out = append(out, n.Field)
+			}
+		}
+	}
+	walk(e)
+	return out
+}
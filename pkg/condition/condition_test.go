@@ -0,0 +1,284 @@
+package condition_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"myapp/pkg/condition"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{"Simple", "temp<0", "temp < 0", false},
+		{"DoubleEq", "temp == 5", "temp = 5", false},
+		{"Negative", "temp <= -12.5", "temp <= -12.5", false},
+		{"Humidity", "humidity > 90", "humidity > 90", false},
+		{"ConditionIdent", "condition = Snow", "condition = Snow", false},
+		{"ConditionQuoted", `condition != "Light Rain"`, `condition != "Light Rain"`, false},
+		{"LegacyRain", "rain", "condition = Rain", false},
+		{"Precedence", "temp < 0 OR humidity > 90 AND condition = Snow", "(temp < 0 OR (humidity > 90 AND condition = Snow))", false},
+		{"Parens", "temp < 0 AND (condition = Snow OR humidity > 90)", "(temp < 0 AND (condition = Snow OR humidity > 90))", false},
+		{"Not", "NOT condition = Clear", "NOT condition = Clear", false},
+		{"LowercaseKeywords", "temp > 1 and not rain", "(temp > 1 AND NOT condition = Rain)", false},
+		{"For", "temp < 0 for 3h", "temp < 0 FOR 3h", false},
+		{"ForMinutes", "temp < 0 FOR 90m", "temp < 0 FOR 1h30m", false},
+		{"ForDays", "humidity > 90 FOR 2d", "humidity > 90 FOR 48h", false},
+		{"ForGroup", "(temp < 0 AND rain) FOR 1h OR humidity > 95", "((temp < 0 AND condition = Rain) FOR 1h OR humidity > 95)", false},
+
+		{"Empty", "", "", true},
+		{"UnknownField", "snow", "", true},
+		{"NumberForText", "temp < abc", "", true},
+		{"TextOpNotAllowed", "condition < Snow", "", true},
+		{"MissingValue", "temp <", "", true},
+		{"UnbalancedParen", "(temp < 0", "", true},
+		{"TrailingToken", "temp < 0 humidity", "", true},
+		{"BadChar", "temp < 0 & rain", "", true},
+		{"ForMissingDuration", "temp < 0 FOR", "", true},
+		{"ForBadDuration", "temp < 0 FOR 3x", "", true},
+		{"ForNested", "(temp < 0 FOR 1h) FOR 2h", "", true},
+		{"HumidityOutOfRange", "humidity > 120", "", true},
+		{"Unterminated", `condition = "Snow`, "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := condition.Parse(tc.src)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want err=%v, got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				var se *condition.SyntaxError
+				if !errors.As(err, &se) {
+					t.Errorf("expected *SyntaxError, got %T", err)
+				}
+				return
+			}
+			if got := e.String(); got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	snow := condition.Snapshot{Temperature: -3, Humidity: 80, Condition: "Snow"}
+	muggy := condition.Snapshot{Temperature: 25, Humidity: 95, Condition: "Clouds"}
+
+	tests := []struct {
+		name string
+		src  string
+		s    condition.Snapshot
+		want bool
+	}{
+		{"TempTrue", "temp < 0", snow, true},
+		{"TempFalse", "temp < 0", muggy, false},
+		{"HumidityTrue", "humidity > 90", muggy, true},
+		{"ConditionCaseInsensitive", "condition = snow", snow, true},
+		{"ConditionNotEqual", "condition != Snow", muggy, true},
+		{"AndOr", "temp < 0 AND (condition = Snow OR humidity > 90)", snow, true},
+		{"AndOrFalse", "temp < 0 AND (condition = Snow OR humidity > 90)", muggy, false},
+		{"OrSecond", "temp < 0 OR humidity >= 95", muggy, true},
+		{"Not", "NOT rain", snow, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := condition.Parse(tc.src)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := condition.Eval(e, condition.Static(tc.s))
+			if err != nil {
+				t.Fatalf("eval: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEvalWithMargin(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		temp   float64
+		margin float64
+		want   bool
+	}{
+		{"LessRelaxed", "temp < 0", 1.5, 2, true},
+		{"LessBeyondMargin", "temp < 0", 2.5, 2, false},
+		{"GreaterRelaxed", "temp > 30", 29, 2, true},
+		{"GreaterBeyondMargin", "temp > 30", 27, 2, false},
+		{"NotInvertsMargin", "NOT temp > 30", 31, 2, true},
+		{"NotBeyondMargin", "NOT temp > 30", 33, 2, false},
+		{"EqualIgnoresMargin", "temp = 5", 6, 2, false},
+		{"ZeroMargin", "temp < 0", 0, 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := condition.Parse(tc.src)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := condition.EvalWithMargin(e, condition.Static(condition.Snapshot{Temperature: tc.temp}), tc.margin)
+			if err != nil {
+				t.Fatalf("eval: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// histEnv — середовище з фіксованою історією для тестів FOR
+type histEnv struct {
+	now     time.Time
+	current condition.Snapshot
+	hist    []condition.Snapshot
+}
+
+func (e histEnv) Now() time.Time              { return e.now }
+func (e histEnv) Current() condition.Snapshot { return e.current }
+func (e histEnv) Window(from time.Time) ([]condition.Snapshot, error) {
+	var out []condition.Snapshot
+	for _, s := range e.hist {
+		if s.At.Before(from) {
+			out = []condition.Snapshot{s}
+			continue
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func TestEval_Sustained(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	at := func(h float64, temp float64) condition.Snapshot {
+		return condition.Snapshot{Temperature: temp, At: now.Add(-time.Duration(h * float64(time.Hour)))}
+	}
+
+	tests := []struct {
+		name string
+		hist []condition.Snapshot
+		want bool
+	}{
+		{"HeldWholeWindow", []condition.Snapshot{at(5, -1), at(2, -3), at(0.5, -2)}, true},
+		{"BriefDip", []condition.Snapshot{at(5, 2), at(1, -3)}, false},
+		{"WarmInMiddle", []condition.Snapshot{at(4, -1), at(2, 1), at(1, -1)}, false},
+		{"NotEnoughHistory", []condition.Snapshot{at(2, -3), at(1, -3)}, false},
+		{"SingleOldReading", []condition.Snapshot{at(6, -3)}, true},
+	}
+
+	e, err := condition.Parse("temp < 0 FOR 3h")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			env := histEnv{now: now, current: condition.Snapshot{Temperature: -2, At: now}, hist: tc.hist}
+			got, err := condition.Eval(e, env)
+			if err != nil {
+				t.Fatalf("eval: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEval_SustainedWithoutHistory(t *testing.T) {
+	e, _ := condition.Parse("temp < 0 FOR 3h")
+	_, err := condition.Eval(e, condition.Static(condition.Snapshot{Temperature: -5}))
+	if !errors.Is(err, condition.ErrNoHistory) {
+		t.Errorf("want ErrNoHistory, got %v", err)
+	}
+}
+
+func TestParse_Delta(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{"delta(temp, 6h) <= -10", "delta(temp, 6h) <= -10", false},
+		{"DELTA(humidity,1h)>20", "delta(humidity, 1h) > 20", false},
+		{"delta(temp, 90m) < -5 AND temp < 0", "(delta(temp, 1h30m) < -5 AND temp < 0)", false},
+		{"delta(temp, 1h) > 3 FOR 2h", "delta(temp, 1h) > 3 FOR 2h", false},
+
+		{"delta(condition, 1h) > 1", "", true},
+		{"delta(temp 1h) > 1", "", true},
+		{"delta(temp, 1) > 1", "", true},
+		{"delta(temp, 1h > 1", "", true},
+		{"delta(temp, 1h) > Snow", "", true},
+		{"delta(humidity, 1h) > 150", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.src, func(t *testing.T) {
+			e, err := condition.Parse(tc.src)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want err=%v, got %v", tc.wantErr, err)
+			}
+			if err == nil && e.String() != tc.want {
+				t.Errorf("want %q, got %q", tc.want, e.String())
+			}
+		})
+	}
+}
+
+func TestEval_Delta(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	at := func(h float64, temp float64) condition.Snapshot {
+		return condition.Snapshot{Temperature: temp, At: now.Add(-time.Duration(h * float64(time.Hour)))}
+	}
+	hist := []condition.Snapshot{at(8, 6), at(5, 4), at(2, -1)}
+
+	tests := []struct {
+		name    string
+		src     string
+		current float64
+		want    bool
+	}{
+		{"Dropped", "delta(temp, 6h) <= -10", -5, true},    // 6°C (8 год тому) → -5
+		{"NotEnough", "delta(temp, 6h) <= -10", -3, false}, // -9
+		{"Rise", "delta(temp, 1h) > 2", 2, true},           // -1 → 2
+		{"NoHistory", "delta(temp, 12h) < 0", -20, false},  // історія не сягає 12 год
+		{"SustainedDrop", "delta(temp, 3h) < 0 FOR 2h", -2, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := condition.Parse(tc.src)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			env := histEnv{now: now, current: condition.Snapshot{Temperature: tc.current, At: now}, hist: hist}
+			got, err := condition.Eval(e, env)
+			if err != nil {
+				t.Fatalf("eval: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	e, _ := condition.Parse("delta(temp, 6h) <= -10")
+	ds, err := condition.Deltas(e, histEnv{now: now, current: condition.Snapshot{Temperature: -5, At: now}, hist: hist})
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("want 1 delta, got %v, %v", ds, err)
+	}
+	if ds[0].Change() != -11 || ds[0].Before.Temperature != 6 {
+		t.Errorf("unexpected delta value: %+v", ds[0])
+	}
+}
@@ -0,0 +1,217 @@
+package condition
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNoHistory повертається, коли умові з FOR чи delta() потрібна історія, а її немає
+var ErrNoHistory = errors.New("condition: weather history is not available")
+
+// Snapshot — значення погоди, з якими порівнюється умова
+type Snapshot struct {
+	Temperature float64
+	Humidity    float64
+	Condition   string
+	At          time.Time
+}
+
+// Env — середовище обчислення: поточні показники та історія міста
+type Env interface {
+	Now() time.Time
+	Current() Snapshot
+	// Window повертає показник, чинний на момент from (останній до нього),
+	// і всі пізніші показники у порядку зростання часу
+	Window(from time.Time) ([]Snapshot, error)
+}
+
+// Static — середовище лише з поточними показниками, без історії
+func Static(s Snapshot) Env { return staticEnv{s} }
+
+type staticEnv struct{ s Snapshot }
+
+func (e staticEnv) Now() time.Time {
+	if e.s.At.IsZero() {
+		return time.Now()
+	}
+	return e.s.At
+}
+func (e staticEnv) Current() Snapshot                    { return e.s }
+func (e staticEnv) Window(time.Time) ([]Snapshot, error) { return nil, ErrNoHistory }
+
+// Eval обчислює розібрану умову в середовищі env
+func Eval(e Expr, env Env) (bool, error) {
+	return EvalWithMargin(e, env, 0)
+}
+
+// EvalWithMargin обчислює умову, послаблюючи числові пороги на margin.
+// Так умова, що вже спрацювала, залишається істинною, доки значення
+// не відійде від порогу більше ніж на margin (гістерезис).
+// Під NOT напрям послаблення змінюється на протилежний.
+func EvalWithMargin(e Expr, env Env, margin float64) (bool, error) {
+	switch n := e.(type) {
+	case *And:
+		l, err := EvalWithMargin(n.Left, env, margin)
+		if err != nil || !l {
+			return false, err
+		}
+		return EvalWithMargin(n.Right, env, margin)
+	case *Or:
+		l, err := EvalWithMargin(n.Left, env, margin)
+		if err != nil || l {
+			return l, err
+		}
+		return EvalWithMargin(n.Right, env, margin)
+	case *Not:
+		x, err := EvalWithMargin(n.X, env, -margin)
+		return !x && err == nil, err
+	case *Sustained:
+		return n.eval(env, margin)
+	case *Delta:
+		dv, ok, err := n.value(env)
+		if err != nil || !ok {
+			return false, err
+		}
+		return compare(dv.Change(), n.Op, n.Num, margin), nil
+	case *Comparison:
+		return n.match(env.Current(), margin), nil
+	}
+	return false, nil
+}
+
+// eval перевіряє, що X виконується зараз і в кожному показнику за останні For.
+// Якщо історія не покриває весь інтервал, умова вважається невиконаною.
+func (n *Sustained) eval(env Env, margin float64) (bool, error) {
+	ok, err := EvalWithMargin(n.X, env, margin)
+	if err != nil || !ok {
+		return false, err
+	}
+	from := env.Now().Add(-n.For)
+	win, err := env.Window(from)
+	if err != nil {
+		return false, err
+	}
+	if len(win) == 0 || win[0].At.After(from) {
+		return false, nil
+	}
+	for _, s := range win {
+		ok, err := EvalWithMargin(n.X, shiftedEnv{env, s}, margin)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// shiftedEnv — середовище на момент минулого показника s з тією самою історією
+type shiftedEnv struct {
+	Env
+	s Snapshot
+}
+
+func (e shiftedEnv) Now() time.Time    { return e.s.At }
+func (e shiftedEnv) Current() Snapshot { return e.s }
+
+// DeltaValue — значення поля на початку вікна (Before) і зараз (After)
+type DeltaValue struct {
+	Expr   *Delta
+	Before Snapshot
+	After  Snapshot
+}
+
+// Change повертає зміну поля за вікно
+func (d DeltaValue) Change() float64 {
+	b, _ := d.Before.Value(d.Expr.Field)
+	a, _ := d.After.Value(d.Expr.Field)
+	return a - b
+}
+
+// value знаходить показник, чинний на початку вікна. ok=false, якщо історія
+// не сягає так далеко.
+func (n *Delta) value(env Env) (DeltaValue, bool, error) {
+	from := env.Now().Add(-n.Window)
+	win, err := env.Window(from)
+	if err != nil {
+		return DeltaValue{}, false, err
+	}
+	if len(win) == 0 || win[0].At.After(from) {
+		return DeltaValue{}, false, nil
+	}
+	return DeltaValue{Expr: n, Before: win[0], After: env.Current()}, true, nil
+}
+
+// Deltas обчислює всі delta() умови в поточному середовищі — для тексту сповіщення.
+// Вирази без достатньої історії пропускаються.
+func Deltas(e Expr, env Env) ([]DeltaValue, error) {
+	var out []DeltaValue
+	var walk func(Expr) error
+	walk = func(e Expr) error {
+		switch n := e.(type) {
+		case *And:
+			if err := walk(n.Left); err != nil {
+				return err
+			}
+			return walk(n.Right)
+		case *Or:
+			if err := walk(n.Left); err != nil {
+				return err
+			}
+			return walk(n.Right)
+		case *Not:
+			return walk(n.X)
+		case *Sustained:
+			return walk(n.X)
+		case *Delta:
+			dv, ok, err := n.value(env)
+			if err != nil {
+				return err
+			}
+			if ok {
+				out = append(out, dv)
+			}
+		}
+		return nil
+	}
+	return out, walk(e)
+}
+
+// Value повертає значення поля зі знімка та чи є воно числовим
+func (s Snapshot) Value(f Field) (float64, bool) {
+	switch f {
+	case FieldTemp:
+		return s.Temperature, true
+	case FieldHumidity:
+		return s.Humidity, true
+	}
+	return 0, false
+}
+
+func (c *Comparison) match(s Snapshot, margin float64) bool {
+	if v, ok := s.Value(c.Field); ok {
+		return compare(v, c.Op, c.Num, margin)
+	}
+	eq := strings.EqualFold(strings.TrimSpace(s.Condition), c.Str)
+	if c.Op == OpNE {
+		return !eq
+	}
+	return eq
+}
+
+func compare(v float64, op Op, thr, margin float64) bool {
+	switch op {
+	case OpLT:
+		return v < thr+margin
+	case OpLE:
+		return v <= thr+margin
+	case OpGT:
+		return v > thr-margin
+	case OpGE:
+		return v >= thr-margin
+	case OpEQ:
+		return v == thr
+	case OpNE:
+		return v != thr
+	}
+	return false
+}
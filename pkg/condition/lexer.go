@@ -0,0 +1,157 @@
+package condition
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokFor
+	tokDuration
+	tokComma
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of input"
+	case tokIdent:
+		return "identifier"
+	case tokNumber:
+		return "number"
+	case tokString:
+		return "string"
+	case tokOp:
+		return "comparison operator"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	case tokFor:
+		return "FOR"
+	case tokDuration:
+		return "duration"
+	case tokComma:
+		return "','"
+	}
+	return "unknown token"
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // позиція першого символу (з нуля)
+}
+
+// lex розбиває вираз на токени. Ключові слова AND/OR/NOT нечутливі до регістру.
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	i := 0
+	for i < len(rs) {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+
+		case r == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+
+		case r == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+
+		case r == '<' || r == '>' || r == '=' || r == '!':
+			start := i
+			i++
+			if i < len(rs) && rs[i] == '=' {
+				i++
+			}
+			op := string(rs[start:i])
+			if op == "!" {
+				return nil, &SyntaxError{Pos: start, Msg: "unexpected '!'"}
+			}
+			toks = append(toks, token{tokOp, op, start})
+
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			for i < len(rs) && rs[i] != r {
+				i++
+			}
+			if i >= len(rs) {
+				return nil, &SyntaxError{Pos: start, Msg: "unterminated string"}
+			}
+			toks = append(toks, token{tokString, string(rs[start+1 : i]), start})
+			i++
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			i++
+			seenDot := false
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || (rs[i] == '.' && !seenDot)) {
+				if rs[i] == '.' {
+					seenDot = true
+				}
+				i++
+			}
+			// число з одиницею виміру ("3h", "1h30m", "2d") — тривалість
+			if i < len(rs) && unicode.IsLetter(rs[i]) {
+				for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '.') {
+					i++
+				}
+				toks = append(toks, token{tokDuration, string(rs[start:i]), start})
+				continue
+			}
+			toks = append(toks, token{tokNumber, string(rs[start:i]), start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			word := string(rs[start:i])
+			kind := tokIdent
+			switch strings.ToUpper(word) {
+			case "AND":
+				kind = tokAnd
+			case "OR":
+				kind = tokOr
+			case "NOT":
+				kind = tokNot
+			case "FOR":
+				kind = tokFor
+			}
+			toks = append(toks, token{kind, word, start})
+
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	toks = append(toks, token{tokEOF, "", len(rs)})
+	return toks, nil
+}
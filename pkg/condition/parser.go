@@ -0,0 +1,301 @@
+// Package condition реалізує мову умов для підписок:
+//
+//	temp < 0 AND (condition = Snow OR humidity > 90)
+//
+// Поля: temp, humidity, condition. Оператори порівняння: < <= > >= = == !=.
+// Логічні зв'язки: AND, OR, NOT та дужки. Суфікс FOR <тривалість>
+// (3h, 90m, 1h30m, 2d) вимагає, щоб умова трималася безперервно весь цей час:
+//
+//	temp < 0 FOR 3h
+//
+// Функція delta(<поле>, <вікно>) — зміна числового поля за вікно:
+//
+//	delta(temp, 6h) <= -10
+//
+// Для сумісності зі старими підписками голе слово "rain" означає "condition = Rain".
+package condition
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyntaxError описує помилку розбору умови.
+// Pos — позиція символу (з нуля), Expected — що очікував парсер, якщо відомо.
+type SyntaxError struct {
+	Pos      int
+	Expected string
+	Found    string
+	Msg      string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Expected != "" {
+		return fmt.Sprintf("position %d: expected %s, got %s", e.Pos, e.Expected, e.Found)
+	}
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func expected(t token, what string) *SyntaxError {
+	return &SyntaxError{Pos: t.pos, Expected: what, Found: describe(t)}
+}
+
+// Parse розбирає та перевіряє типи виразу умови
+func Parse(src string) (Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	if t := p.peek(); t.kind == tokEOF {
+		return nil, expected(t, "field name")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, expected(t, "AND, OR, FOR or end of input")
+	}
+	return e, nil
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// or := and (OR and)*
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// and := unary (AND unary)*
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// unary := NOT unary | primary [FOR duration]
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+
+	start := p.peek()
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokFor {
+		return x, nil
+	}
+	p.next()
+	dt := p.next()
+	if dt.kind != tokDuration {
+		return nil, expected(dt, "duration (e.g. 3h, 90m, 2d)")
+	}
+	d, err := parseDuration(dt.text)
+	if err != nil || d <= 0 {
+		return nil, &SyntaxError{Pos: dt.pos, Msg: fmt.Sprintf("invalid duration %q", dt.text)}
+	}
+	if hasSustained(x) {
+		return nil, &SyntaxError{Pos: start.pos, Msg: "FOR cannot be nested"}
+	}
+	return &Sustained{X: x, For: d}, nil
+}
+
+// primary := '(' or ')' | comparison
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokLParen:
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if r := p.next(); r.kind != tokRParen {
+			return nil, expected(r, "')'")
+		}
+		return e, nil
+
+	case tokIdent:
+		if strings.EqualFold(t.text, "delta") && p.toks[p.i+1].kind == tokLParen {
+			return p.parseDelta()
+		}
+		return p.parseComparison()
+	}
+	return nil, expected(t, "field name, NOT or '('")
+}
+
+// delta := "delta" '(' field ',' duration ')' op number
+func (p *parser) parseDelta() (Expr, error) {
+	p.next() // delta
+	p.next() // (
+
+	ft := p.next()
+	field, ok := fieldNames[strings.ToLower(ft.text)]
+	if ft.kind != tokIdent || !ok || !field.Numeric() {
+		return nil, expected(ft, "temp or humidity")
+	}
+	if t := p.next(); t.kind != tokComma {
+		return nil, expected(t, "','")
+	}
+	dt := p.next()
+	if dt.kind != tokDuration {
+		return nil, expected(dt, "duration (e.g. 6h, 30m)")
+	}
+	window, err := parseDuration(dt.text)
+	if err != nil || window <= 0 {
+		return nil, &SyntaxError{Pos: dt.pos, Msg: fmt.Sprintf("invalid duration %q", dt.text)}
+	}
+	if t := p.next(); t.kind != tokRParen {
+		return nil, expected(t, "')'")
+	}
+
+	ot := p.next()
+	if ot.kind != tokOp {
+		return nil, expected(ot, "comparison operator")
+	}
+	vt := p.next()
+	if vt.kind != tokNumber {
+		return nil, expected(vt, "number")
+	}
+	n, err := strconv.ParseFloat(vt.text, 64)
+	if err != nil {
+		return nil, &SyntaxError{Pos: vt.pos, Msg: fmt.Sprintf("invalid number %q", vt.text)}
+	}
+	if field == FieldHumidity && (n < -100 || n > 100) {
+		return nil, &SyntaxError{Pos: vt.pos, Msg: "humidity change must be between -100 and 100"}
+	}
+	return &Delta{Field: field, Window: window, Op: opNames[ot.text], Num: n}, nil
+}
+
+// parseDuration розуміє формат time.ParseDuration та додатково дні: "2d", "1d12h"
+func parseDuration(s string) (time.Duration, error) {
+	if i := strings.IndexByte(s, 'd'); i > 0 {
+		days, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, err
+		}
+		d := time.Duration(days) * 24 * time.Hour
+		if rest := s[i+1:]; rest != "" {
+			r, err := time.ParseDuration(rest)
+			if err != nil {
+				return 0, err
+			}
+			d += r
+		}
+		return d, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func hasSustained(e Expr) bool {
+	switch n := e.(type) {
+	case *Sustained:
+		return true
+	case *And:
+		return hasSustained(n.Left) || hasSustained(n.Right)
+	case *Or:
+		return hasSustained(n.Left) || hasSustained(n.Right)
+	case *Not:
+		return hasSustained(n.X)
+	}
+	return false
+}
+
+// comparison := field op value | "rain"
+func (p *parser) parseComparison() (Expr, error) {
+	ft := p.next()
+	name := strings.ToLower(ft.text)
+
+	if name == "rain" && p.peek().kind != tokOp {
+		return &Comparison{Field: FieldCondition, Op: OpEQ, Str: "Rain"}, nil
+	}
+
+	field, ok := fieldNames[name]
+	if !ok {
+		return nil, expected(ft, "temp, humidity or condition")
+	}
+
+	ot := p.next()
+	if ot.kind != tokOp {
+		return nil, expected(ot, "comparison operator")
+	}
+	op := opNames[ot.text]
+
+	vt := p.next()
+	c := &Comparison{Field: field, Op: op}
+	if field.Numeric() {
+		if vt.kind != tokNumber {
+			return nil, expected(vt, "number")
+		}
+		n, err := strconv.ParseFloat(vt.text, 64)
+		if err != nil {
+			return nil, &SyntaxError{Pos: vt.pos, Msg: fmt.Sprintf("invalid number %q", vt.text)}
+		}
+		if field == FieldHumidity && (n < 0 || n > 100) {
+			return nil, &SyntaxError{Pos: vt.pos, Msg: "humidity must be between 0 and 100"}
+		}
+		c.Num = n
+		return c, nil
+	}
+
+	if op != OpEQ && op != OpNE {
+		return nil, expected(ot, "'=' or '!=' for "+field.String())
+	}
+	if vt.kind != tokIdent && vt.kind != tokString {
+		return nil, expected(vt, "value for "+field.String())
+	}
+	c.Str = vt.text
+	return c, nil
+}
+
+func describe(t token) string {
+	if t.kind == tokEOF {
+		return t.kind.String()
+	}
+	return fmt.Sprintf("%q", t.text)
+}
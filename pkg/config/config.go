@@ -4,11 +4,50 @@ import (
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	DBUser, DBPass, DBHost, DBPort, DBName string
 	SMTPHost, SMTPPort, SMTPUser, SMTPPass string
+
+	// HistoryRetention — скільки зберігати історію погоди; 0 вимикає очищення
+	HistoryRetention time.Duration
+
+	// Зовнішнє джерело погоди (API, сумісне з OpenWeatherMap)
+	WeatherAPIURL, WeatherAPIKey string
+	// FetchSchedule — розклад оновлення погоди; порожній вимикає fetcher
+	FetchSchedule string
+
+	// BaseURL — публічна адреса сервісу для посилань у листах
+	BaseURL string
+	// AppSecret — ключ для підпису посилань (відписка тощо)
+	AppSecret string
+
+	// Outbox: максимум спроб доставки і базова затримка експоненційного backoff
+	OutboxMaxAttempts int
+	OutboxBackoff     time.Duration
+	// TemplateDir — каталог шаблонів листів, що перекриває вбудовані
+	TemplateDir string
+	// AdminToken — Bearer-токен для /admin/*; порожній вимикає адмінські ендпоінти
+	AdminToken string
+	// WebhookAllowPrivate дозволяє вебхуки на внутрішні адреси (лише для розробки)
+	WebhookAllowPrivate bool
+
+	// ResendInterval — найменший проміжок між листами підтвердження на одну адресу
+	ResendInterval time.Duration
+	// UnverifiedGrace — скільки після спливання токена зберігати непідтверджену
+	// підписку; 0 вимикає очищення
+	UnverifiedGrace time.Duration
+
+	// EvalWorkers — скільки міст обчислюється паралельно в запуску cron
+	EvalWorkers int
+	// EvalTimeout — дедлайн одного запуску обчислення підписок
+	EvalTimeout time.Duration
+	// LeaseTTL — строк оренди планувальника в БД: лише її власник серед реплік
+	// виконує такти; 0 вимикає оренду (один екземпляр)
+	LeaseTTL time.Duration
 }
 
 func NewConfig() Config {
@@ -25,5 +64,78 @@ func NewConfig() Config {
 		SMTPPort: os.Getenv("SMTP_PORT"),
 		SMTPUser: os.Getenv("SMTP_USER"),
 		SMTPPass: os.Getenv("SMTP_PASS"),
+
+		HistoryRetention: durationEnv("HISTORY_RETENTION", 30*24*time.Hour),
+
+		WeatherAPIURL: stringEnv("WEATHER_API_URL", "https://api.openweathermap.org"),
+		WeatherAPIKey: os.Getenv("WEATHER_API_KEY"),
+		FetchSchedule: os.Getenv("FETCH_SCHEDULE"),
+
+		BaseURL:   stringEnv("BASE_URL", "http://localhost:8080"),
+		AppSecret: os.Getenv("APP_SECRET"),
+
+		OutboxMaxAttempts: intEnv("OUTBOX_MAX_ATTEMPTS", 8),
+		OutboxBackoff:     durationEnv("OUTBOX_BACKOFF", 30*time.Second),
+		AdminToken:        os.Getenv("ADMIN_TOKEN"),
+		TemplateDir:       os.Getenv("TEMPLATE_DIR"),
+
+		WebhookAllowPrivate: boolEnv("WEBHOOK_ALLOW_PRIVATE", false),
+
+		ResendInterval:  durationEnv("RESEND_INTERVAL", 5*time.Minute),
+		UnverifiedGrace: durationEnv("UNVERIFIED_GRACE", 7*24*time.Hour),
+
+		EvalWorkers: intEnv("EVAL_WORKERS", 4),
+		EvalTimeout: durationEnv("EVAL_TIMEOUT", 5*time.Minute),
+		LeaseTTL:    durationEnv("LEASE_TTL", 30*time.Second),
+	}
+}
+
+// stringEnv читає змінну оточення або повертає def
+func stringEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// durationEnv читає тривалість зі змінної оточення або повертає def
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("⚠️  invalid %s=%q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// boolEnv читає булеве значення зі змінної оточення або повертає def
+func boolEnv(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("⚠️  invalid %s=%q, using %v", key, v, def)
+		return def
+	}
+	return b
+}
+
+// intEnv читає ціле число зі змінної оточення або повертає def
+func intEnv(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("⚠️  invalid %s=%q, using %d", key, v, def)
+		return def
 	}
+	return n
 }
@@ -19,8 +19,23 @@ func Connect(cfg config.Config) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	// Міграції
-	db.AutoMigrate(&models2.Subscription{}, &models2.Weather{})
-
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
+
+// Migrate створює й оновлює таблиці та переносить дані зі старих схем
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models2.Subscription{}, &models2.Weather{}, &models2.WeatherReading{}, &models2.OutboxMessage{}, &models2.DeliveryPreference{}, &models2.SubscriptionToken{}, &models2.Lease{}, &models2.SchedulerRun{}); err != nil {
+		return err
+	}
+
+	if err := migrateTokens(db); err != nil {
+		return fmt.Errorf("migrate verification tokens: %w", err)
+	}
+	if err := scrubConfirmations(db); err != nil {
+		return fmt.Errorf("scrub confirmation emails: %w", err)
+	}
+	return nil
+}
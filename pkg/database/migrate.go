@@ -0,0 +1,69 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	models2 "myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/tokens"
+)
+
+// legacyTokenColumns — колонки subscriptions, у яких раніше лежав відкритий токен підтвердження
+var legacyTokenColumns = []string{"verification_token", "token_expires_at"}
+
+// migrateTokens переносить токени підтвердження зі старих колонок subscriptions
+// у subscription_tokens як SHA-256 і видаляє колонки з відкритими токенами.
+// Посилання з уже надісланих листів лишаються чинними до свого строку.
+func migrateTokens(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasColumn(&models2.Subscription{}, legacyTokenColumns[0]) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID                uint
+			VerificationToken string
+			TokenExpiresAt    *time.Time
+		}
+		err := tx.
+			Table("subscriptions").
+			Select("id, verification_token, token_expires_at").
+			Where("verified = ? AND verification_token <> ''", false).
+			Scan(&rows).
+			Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			tok := models2.SubscriptionToken{
+				SubscriptionID: row.ID,
+				Purpose:        models2.TokenConfirm,
+				Hash:           tokens.Hash(row.VerificationToken),
+				ExpiresAt:      row.TokenExpiresAt,
+			}
+			if err := tx.Create(&tok).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, col := range legacyTokenColumns {
+			if err := tx.Migrator().DropColumn(&models2.Subscription{}, col); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scrubConfirmations стирає тіла листів підтвердження, які раніше зберігалися
+// в outbox разом із відкритим токеном. Лист, що ще чекає в черзі, диспетчер
+// відрендерить із новим токеном під час надсилання.
+func scrubConfirmations(db *gorm.DB) error {
+	return db.
+		Model(&models2.OutboxMessage{}).
+		Where("kind = ? AND (body <> '' OR html_body <> '')", notifier.KindConfirm).
+		Updates(map[string]interface{}{"body": "", "html_body": ""}).
+		Error
+}
@@ -0,0 +1,107 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"myapp/pkg/database"
+	models2 "myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/tokens"
+)
+
+// legacySubscription — схема subscriptions до хешування токенів
+type legacySubscription struct {
+	ID                uint   `gorm:"primaryKey"`
+	Email             string `gorm:"size:100"`
+	City              string `gorm:"size:100"`
+	Condition         string `gorm:"size:255;not null"`
+	Verified          bool
+	VerificationToken string `gorm:"size:64;index"`
+	TokenExpiresAt    *time.Time
+}
+
+func (legacySubscription) TableName() string { return "subscriptions" }
+
+func TestMigrate_HashesLegacyTokens(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&legacySubscription{}); err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	db.Create(&[]legacySubscription{
+		{Email: "a@b", City: "Kyiv", Condition: "temp < 0", VerificationToken: "pending-token", TokenExpiresAt: &exp},
+		{Email: "a@b", City: "Lviv", Condition: "temp < 0", Verified: true},
+	})
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, col := range []string{"verification_token", "token_expires_at"} {
+		if db.Migrator().HasColumn(&models2.Subscription{}, col) {
+			t.Errorf("column %s must be dropped", col)
+		}
+	}
+	var toks []models2.SubscriptionToken
+	db.Find(&toks)
+	if len(toks) != 1 {
+		t.Fatalf("want 1 migrated token, got %+v", toks)
+	}
+	tok := toks[0]
+	if tok.SubscriptionID != 1 || tok.Purpose != models2.TokenConfirm || tok.Hash != tokens.Hash("pending-token") {
+		t.Errorf("unexpected token: %+v", tok)
+	}
+	if tok.ExpiresAt == nil || !tok.ExpiresAt.Equal(exp) {
+		t.Errorf("expiry must be kept, got %v", tok.ExpiresAt)
+	}
+	var sub models2.Subscription
+	if err := db.First(&sub, 1).Error; err != nil || sub.Email != "a@b" {
+		t.Errorf("subscription must survive the migration: %+v, %v", sub, err)
+	}
+
+	// повторний запуск нічого не змінює
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.Model(&models2.SubscriptionToken{}).Count(&n)
+	if n != 1 {
+		t.Errorf("migration must be idempotent, got %d tokens", n)
+	}
+}
+
+func TestMigrate_ScrubsConfirmationBodies(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&[]models2.OutboxMessage{
+		{SubscriptionID: 1, Kind: notifier.KindConfirm, Subject: "Confirm", Body: "/subscriptions/confirm?token=raw", HTMLBody: "<a>raw</a>"},
+		{SubscriptionID: 1, Kind: notifier.KindAlert, Subject: "Alert", Body: "cold"},
+	})
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []models2.OutboxMessage
+	db.Order("id").Find(&msgs)
+	if len(msgs) != 2 {
+		t.Fatalf("want 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Body != "" || msgs[0].HTMLBody != "" || msgs[0].Subject != "Confirm" {
+		t.Errorf("confirmation body must be scrubbed, got %+v", msgs[0])
+	}
+	if msgs[1].Body != "cold" {
+		t.Errorf("other messages must be kept, got %+v", msgs[1])
+	}
+}
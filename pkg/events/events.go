@@ -0,0 +1,42 @@
+// Package events доставляє події всередині процесу: HTTP-обробники й fetcher
+// повідомляють про зміну погоди, а планувальник одразу обчислює підписки міста.
+package events
+
+import "sync"
+
+// WeatherChanged — погоду міста збережено чи оновлено
+type WeatherChanged struct {
+	City string
+}
+
+// Bus розсилає події всім підписникам у потоці того, хто публікує,
+// тож обробник не має блокувати: довгу роботу він ставить у власну чергу.
+// Нульовий *Bus — валідний і нікому нічого не доставляє.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []func(WeatherChanged)
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe додає обробник подій зміни погоди
+func (b *Bus) Subscribe(h func(WeatherChanged)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish доставляє подію всім обробникам
+func (b *Bus) Publish(e WeatherChanged) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(e)
+	}
+}
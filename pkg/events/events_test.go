@@ -0,0 +1,26 @@
+package events_test
+
+import (
+	"testing"
+
+	"myapp/pkg/events"
+)
+
+func TestBus_Publish(t *testing.T) {
+	bus := events.NewBus()
+	var a, b []string
+	bus.Subscribe(func(e events.WeatherChanged) { a = append(a, e.City) })
+	bus.Subscribe(func(e events.WeatherChanged) { b = append(b, e.City) })
+
+	bus.Publish(events.WeatherChanged{City: "Kyiv"})
+	bus.Publish(events.WeatherChanged{City: "Lviv"})
+
+	if len(a) != 2 || len(b) != 2 || a[1] != "Lviv" || b[0] != "Kyiv" {
+		t.Errorf("every handler must get every event, got %v and %v", a, b)
+	}
+}
+
+func TestBus_Nil(t *testing.T) {
+	var bus *events.Bus
+	bus.Publish(events.WeatherChanged{City: "Kyiv"}) // не панікує
+}
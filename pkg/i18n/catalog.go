@@ -0,0 +1,231 @@
+package i18n
+
+// Ключі повідомлень відповідей API
+const (
+	MsgInternal              = "internal_error"
+	MsgBadRequest            = "bad_request"
+	MsgInvalidRequest        = "invalid_request"
+	MsgInvalidCondition      = "invalid_condition"
+	MsgCityRequired          = "city_required"
+	MsgCityNotFound          = "city_not_found"
+	MsgCityNotFoundNamed     = "city_not_found_named"
+	MsgEmailRequired         = "email_required"
+	MsgTokenRequired         = "token_required"
+	MsgInvalidToken          = "invalid_token"
+	MsgTokenExpired          = "token_expired"
+	MsgSubscriptionExists    = "subscription_exists"
+	MsgSubscriptionNotFound  = "subscription_not_found"
+	MsgInvalidSubscriptionID = "invalid_subscription_id"
+	MsgInvalidChannel        = "invalid_channel"
+	MsgWebhookURLRequired    = "webhook_url_required"
+	MsgWebhookURLScheme      = "webhook_url_scheme"
+	MsgInvalidSchedule       = "invalid_schedule"
+	MsgInvalidTimezone       = "invalid_timezone"
+	MsgQuietHoursPair        = "quiet_hours_pair"
+	MsgInvalidQuietTime      = "invalid_quiet_time"
+	MsgInvalidSnooze         = "invalid_snooze"
+	MsgAlreadyVerified       = "already_verified"
+	MsgResendTooSoon         = "resend_too_soon"
+	MsgManageTooSoon         = "manage_too_soon"
+	MsgInvalidPage           = "invalid_page"
+	MsgInvalidPerPage        = "invalid_per_page"
+	MsgInvalidFrom           = "invalid_from"
+	MsgInvalidTo             = "invalid_to"
+	MsgInvalidStep           = "invalid_step"
+	MsgInvalidRange          = "invalid_range"
+	MsgRangeOrder            = "range_order"
+	MsgRangeTooManyPoints    = "range_too_many_points"
+	MsgAdminDisabled         = "admin_disabled"
+	MsgUnauthorized          = "auth_required"
+	MsgInvalidOutboxID       = "invalid_outbox_id"
+	MsgOutboxNotFound        = "outbox_not_found"
+	MsgInvalidOutboxStatus   = "invalid_outbox_status"
+	MsgUnknownOutboxStatus   = "unknown_outbox_status"
+	MsgOutboxNotDead         = "outbox_not_dead"
+	MsgInvalidDryRun         = "invalid_dry_run"
+	MsgSchedulerBusy         = "scheduler_busy"
+
+	MsgCheckEmail    = "check_email"
+	MsgEmailVerified = "email_verified"
+	MsgUnsubscribed  = "unsubscribed"
+	MsgSnoozed       = "snoozed"
+	MsgResumed       = "resumed"
+	MsgManageSent    = "manage_sent"
+)
+
+// Ключі текстів сповіщень (використовуються в шаблонах через {{t "..."}})
+const (
+	MsgReadingTemp      = "reading.temp"
+	MsgReadingHumidity  = "reading.humidity"
+	MsgReadingCondition = "reading.condition"
+	MsgReadingDelta     = "reading.delta"
+)
+
+var catalog = map[string]map[string]string{
+	EN: {
+		MsgInternal:              "internal server error",
+		MsgBadRequest:            "bad request",
+		MsgInvalidRequest:        "invalid request: %s",
+		MsgInvalidCondition:      "invalid condition %q: %v",
+		MsgCityRequired:          "city required",
+		MsgCityNotFound:          "city not found",
+		MsgCityNotFoundNamed:     "city not found: %q",
+		MsgEmailRequired:         "email required",
+		MsgTokenRequired:         "token required",
+		MsgInvalidToken:          "invalid token",
+		MsgTokenExpired:          "token expired",
+		MsgSubscriptionExists:    "subscription already exists",
+		MsgSubscriptionNotFound:  "subscription not found",
+		MsgInvalidSubscriptionID: "invalid subscription id",
+		MsgInvalidChannel:        "invalid notification channel",
+		MsgWebhookURLRequired:    "invalid notification channel: webhook_url is required for channel %q",
+		MsgWebhookURLScheme:      "invalid notification channel: webhook_url must be an http or https URL",
+		MsgInvalidSchedule:       "invalid delivery schedule",
+		MsgInvalidTimezone:       "invalid delivery schedule: unknown timezone %q",
+		MsgQuietHoursPair:        "invalid delivery schedule: quiet_start and quiet_end must be set together",
+		MsgInvalidQuietTime:      "invalid delivery schedule: %q is not a time of day (HH:MM)",
+		MsgInvalidSnooze:         "invalid for: expected a duration like 48h, at most %d days",
+		MsgAlreadyVerified:       "subscription already verified",
+		MsgResendTooSoon:         "confirmation email was sent recently, try again in %d s",
+		MsgManageTooSoon:         "manage link was sent recently, try again in %d s",
+		MsgInvalidPage:           "invalid page",
+		MsgInvalidPerPage:        "invalid per_page: expected 1..%d",
+		MsgInvalidFrom:           "invalid from: expected RFC3339",
+		MsgInvalidTo:             "invalid to: expected RFC3339",
+		MsgInvalidStep:           "invalid step: expected duration like 1h or 15m",
+		MsgInvalidRange:          "invalid history range",
+		MsgRangeOrder:            "invalid history range: from must be before to",
+		MsgRangeTooManyPoints:    "invalid history range: more than %d points, narrow the range or increase step",
+		MsgAdminDisabled:         "admin endpoints are disabled",
+		MsgUnauthorized:          "unauthorized",
+		MsgInvalidOutboxID:       "invalid outbox id",
+		MsgOutboxNotFound:        "outbox message not found",
+		MsgInvalidOutboxStatus:   "invalid outbox status",
+		MsgUnknownOutboxStatus:   "invalid outbox status: unknown status %q",
+		MsgOutboxNotDead:         "invalid outbox status: message is %s",
+		MsgInvalidDryRun:         "invalid dry_run: expected true or false",
+		MsgSchedulerBusy:         "the scheduler lease is held by another instance, try again later",
+
+		MsgCheckEmail:    "Check your email and click on the confirmation link.",
+		MsgEmailVerified: "Email verified",
+		MsgUnsubscribed:  "You have been unsubscribed",
+		MsgSnoozed:       "Alerts paused until %s",
+		MsgResumed:       "Alerts resumed",
+		MsgManageSent:    "If the address has subscriptions, a link to manage them is on its way.",
+
+		MsgReadingTemp:      "temp %.1f°C",
+		MsgReadingHumidity:  "humidity %d%%",
+		MsgReadingCondition: "condition %s",
+		MsgReadingDelta:     "%s: change %+.1f (%.1f%s at %s → %.1f%s now)",
+
+		"confirm.subject":   "Please confirm your subscription",
+		"confirm.heading":   "Confirm your subscription",
+		"confirm.intro":     "You asked for weather alerts for %s when",
+		"confirm.click":     "Click to confirm",
+		"confirm.button":    "Confirm subscription",
+		"confirm.expires":   "Expires at",
+		"confirm.link_till": "The link expires at %s.",
+		"manage.subject":    "Manage your weather alerts",
+		"manage.heading":    "Manage your weather alerts",
+		"manage.intro":      "Use the link below to view, change or delete the subscriptions of this address.",
+		"manage.click":      "Manage subscriptions",
+		"manage.button":     "Manage subscriptions",
+		"alert.subject":     "Weather Alert for %s",
+		"alert.heading":     "Weather alert for %s",
+		"alert.text":        "Condition %s met: current %s",
+		"alert.condition":   "Condition met:",
+		"clear.subject":     "All clear for %s",
+		"clear.heading":     "All clear for %s",
+		"clear.text":        "Condition %s no longer holds: current %s",
+		"clear.condition":   "Condition no longer holds:",
+		"digest.subject":    "Weather digest (%d)",
+		"digest.heading":    "Weather digest",
+		"digest.intro":      "Notifications since your last digest: %d",
+		"current":           "Current",
+		"unsubscribe":       "Unsubscribe",
+		"snooze":            "Pause alerts for 24 hours",
+	},
+	UK: {
+		MsgInternal:              "внутрішня помилка сервера",
+		MsgBadRequest:            "некоректний запит",
+		MsgInvalidRequest:        "некоректний запит: %s",
+		MsgInvalidCondition:      "некоректна умова %q: %v",
+		MsgCityRequired:          "потрібно вказати місто",
+		MsgCityNotFound:          "місто не знайдено",
+		MsgCityNotFoundNamed:     "місто %q не знайдено",
+		MsgEmailRequired:         "потрібно вказати email",
+		MsgTokenRequired:         "потрібен токен",
+		MsgInvalidToken:          "недійсний токен",
+		MsgTokenExpired:          "термін дії токена минув",
+		MsgSubscriptionExists:    "підписка вже існує",
+		MsgSubscriptionNotFound:  "підписку не знайдено",
+		MsgInvalidSubscriptionID: "некоректний id підписки",
+		MsgInvalidChannel:        "некоректний канал сповіщень",
+		MsgWebhookURLRequired:    "некоректний канал сповіщень: для каналу %q потрібен webhook_url",
+		MsgWebhookURLScheme:      "некоректний канал сповіщень: webhook_url має бути адресою http або https",
+		MsgInvalidSchedule:       "некоректний розклад доставки",
+		MsgInvalidTimezone:       "некоректний розклад доставки: невідомий часовий пояс %q",
+		MsgQuietHoursPair:        "некоректний розклад доставки: quiet_start і quiet_end задаються разом",
+		MsgInvalidQuietTime:      "некоректний розклад доставки: %q не є часом доби (ГГ:ХХ)",
+		MsgInvalidSnooze:         "некоректний for: очікується тривалість, напр. 48h, не більше %d днів",
+		MsgAlreadyVerified:       "підписку вже підтверджено",
+		MsgResendTooSoon:         "лист підтвердження надіслано нещодавно, спробуйте через %d с",
+		MsgManageTooSoon:         "посилання для керування надіслано нещодавно, спробуйте через %d с",
+		MsgInvalidPage:           "некоректний номер сторінки",
+		MsgInvalidPerPage:        "некоректний per_page: очікується 1..%d",
+		MsgInvalidFrom:           "некоректний from: очікується RFC3339",
+		MsgInvalidTo:             "некоректний to: очікується RFC3339",
+		MsgInvalidStep:           "некоректний step: очікується тривалість, напр. 1h або 15m",
+		MsgInvalidRange:          "некоректний діапазон історії",
+		MsgRangeOrder:            "некоректний діапазон історії: from має бути раніше за to",
+		MsgRangeTooManyPoints:    "некоректний діапазон історії: понад %d точок, звузьте інтервал або збільште step",
+		MsgAdminDisabled:         "адмінські ендпоінти вимкнено",
+		MsgUnauthorized:          "не авторизовано",
+		MsgInvalidOutboxID:       "некоректний id повідомлення outbox",
+		MsgOutboxNotFound:        "повідомлення outbox не знайдено",
+		MsgInvalidOutboxStatus:   "некоректний статус outbox",
+		MsgUnknownOutboxStatus:   "некоректний статус outbox: невідомий статус %q",
+		MsgOutboxNotDead:         "некоректний статус outbox: повідомлення має статус %s",
+		MsgInvalidDryRun:         "некоректний dry_run: очікується true або false",
+		MsgSchedulerBusy:         "оренду планувальника утримує інший екземпляр, спробуйте пізніше",
+
+		MsgCheckEmail:    "Перевірте пошту й перейдіть за посиланням для підтвердження.",
+		MsgEmailVerified: "Email підтверджено",
+		MsgUnsubscribed:  "Ви відписалися від сповіщень",
+		MsgSnoozed:       "Сповіщення призупинено до %s",
+		MsgResumed:       "Сповіщення відновлено",
+		MsgManageSent:    "Якщо для адреси є підписки, на неї надіслано посилання для керування ними.",
+
+		MsgReadingTemp:      "температура %.1f°C",
+		MsgReadingHumidity:  "вологість %d%%",
+		MsgReadingCondition: "погода %s",
+		MsgReadingDelta:     "%s: зміна %+.1f (%.1f%s о %s → %.1f%s зараз)",
+
+		"confirm.subject":   "Підтвердіть підписку",
+		"confirm.heading":   "Підтвердіть підписку",
+		"confirm.intro":     "Ви підписалися на погодні сповіщення для %s за умови",
+		"confirm.click":     "Підтвердити",
+		"confirm.button":    "Підтвердити підписку",
+		"confirm.expires":   "Дійсне до",
+		"confirm.link_till": "Посилання дійсне до %s.",
+		"manage.subject":    "Керування погодними сповіщеннями",
+		"manage.heading":    "Керування погодними сповіщеннями",
+		"manage.intro":      "За посиланням нижче можна переглянути, змінити чи видалити підписки цієї адреси.",
+		"manage.click":      "Керувати підписками",
+		"manage.button":     "Керувати підписками",
+		"alert.subject":     "Погодне сповіщення для %s",
+		"alert.heading":     "Погодне сповіщення для %s",
+		"alert.text":        "Умову %s виконано: зараз %s",
+		"alert.condition":   "Умову виконано:",
+		"clear.subject":     "Відбій для %s",
+		"clear.heading":     "Відбій для %s",
+		"clear.text":        "Умова %s більше не виконується: зараз %s",
+		"clear.condition":   "Умова більше не виконується:",
+		"digest.subject":    "Погодний дайджест (%d)",
+		"digest.heading":    "Погодний дайджест",
+		"digest.intro":      "Сповіщень від попереднього дайджесту: %d",
+		"current":           "Зараз",
+		"unsubscribe":       "Відписатися",
+		"snooze":            "Призупинити сповіщення на 24 години",
+	},
+}
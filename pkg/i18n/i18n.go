@@ -0,0 +1,106 @@
+// Package i18n — каталог повідомлень для листів і відповідей API
+// та вибір мови за заголовком Accept-Language.
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Підтримувані мови
+const (
+	EN = "en"
+	UK = "uk"
+)
+
+// Default використовується, коли мову не задано або вона не підтримується
+const Default = EN
+
+// Supported перелічує мови, для яких є каталог
+var Supported = []string{EN, UK}
+
+// T повертає переклад ключа key мовою lang, підставляючи args через fmt.Sprintf.
+// Відсутній переклад береться з Default, а відсутній ключ повертається як є.
+func T(lang, key string, args ...interface{}) string {
+	msg, ok := catalog[lang][key]
+	if !ok {
+		msg, ok = catalog[Default][key]
+	}
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Normalize зводить тег мови ("uk-UA", "EN") до підтримуваної мови; "" — якщо не підтримується
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := catalog[tag]; ok {
+		return tag
+	}
+	return ""
+}
+
+// Negotiate вибирає мову за значенням заголовка Accept-Language (RFC 9110, q-ваги).
+// Якщо жодна мова не підходить, повертає Default.
+func Negotiate(header string) string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if lang := Normalize(tag); lang != "" && q > 0 {
+			prefs = append(prefs, pref{lang, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	if len(prefs) == 0 {
+		return Default
+	}
+	return prefs[0].lang
+}
+
+// Error — помилка з ключем каталогу, щоб відповідь API могла її перекласти.
+// Error() повертає текст мовою Default, Unwrap — сигнальну помилку.
+type Error struct {
+	Err  error
+	Key  string
+	Args []interface{}
+}
+
+// Wrap обгортає сигнальну помилку err повідомленням з каталогу
+func Wrap(err error, key string, args ...interface{}) error {
+	return &Error{Err: err, Key: key, Args: args}
+}
+
+func (e *Error) Error() string { return T(Default, e.Key, e.Args...) }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Message перекладає err мовою lang, якщо це *Error; інакше повертає T(lang, fallback)
+func Message(lang string, err error, fallback string) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return T(lang, e.Key, e.Args...)
+	}
+	return T(lang, fallback)
+}
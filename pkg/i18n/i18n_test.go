@@ -0,0 +1,94 @@
+package i18n_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"myapp/pkg/i18n"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		header, want string
+	}{
+		{"", i18n.EN},
+		{"uk", i18n.UK},
+		{"uk-UA,uk;q=0.9,en;q=0.8", i18n.UK},
+		{"en-US,en;q=0.9,uk;q=0.8", i18n.EN},
+		{"de-DE,uk;q=0.5,en;q=0.3", i18n.UK},
+		{"en;q=0.2, UK;q=0.7", i18n.UK},
+		{"uk;q=0, en", i18n.EN},
+		{"fr, de", i18n.EN},
+		{"uk;q=abc, en;q=0.1", i18n.EN},
+	}
+	for _, tc := range cases {
+		if got := i18n.Negotiate(tc.header); got != tc.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestT_Fallbacks(t *testing.T) {
+	if got := i18n.T(i18n.UK, i18n.MsgCityNotFoundNamed, "Kyiv"); got != `місто "Kyiv" не знайдено` {
+		t.Errorf("uk: %q", got)
+	}
+	if got := i18n.T("de", i18n.MsgCityNotFound); got != "city not found" {
+		t.Errorf("unknown language must fall back to default, got %q", got)
+	}
+	if got := i18n.T(i18n.UK, "no.such.key"); got != "no.such.key" {
+		t.Errorf("unknown key must be returned as is, got %q", got)
+	}
+}
+
+// Кожен ключ, яким користуються контролери й шаблони, перекладено всіма мовами
+func TestCatalog_Complete(t *testing.T) {
+	keys := []string{
+		i18n.MsgInternal, i18n.MsgBadRequest, i18n.MsgInvalidRequest, i18n.MsgInvalidChannel,
+		i18n.MsgInvalidRange, i18n.MsgInvalidOutboxStatus, i18n.MsgInvalidSchedule, i18n.MsgInvalidTimezone,
+		i18n.MsgQuietHoursPair, i18n.MsgInvalidQuietTime, i18n.MsgInvalidCondition, i18n.MsgCityRequired,
+		i18n.MsgCityNotFound, i18n.MsgCityNotFoundNamed, i18n.MsgEmailRequired, i18n.MsgTokenRequired,
+		i18n.MsgInvalidToken, i18n.MsgTokenExpired, i18n.MsgSubscriptionExists, i18n.MsgSubscriptionNotFound,
+		i18n.MsgInvalidSubscriptionID, i18n.MsgWebhookURLRequired, i18n.MsgWebhookURLScheme, i18n.MsgInvalidPage, i18n.MsgInvalidPerPage,
+		i18n.MsgInvalidFrom, i18n.MsgInvalidTo, i18n.MsgInvalidStep, i18n.MsgRangeOrder, i18n.MsgRangeTooManyPoints,
+		i18n.MsgAdminDisabled, i18n.MsgUnauthorized, i18n.MsgInvalidOutboxID, i18n.MsgOutboxNotFound,
+		i18n.MsgUnknownOutboxStatus, i18n.MsgOutboxNotDead, i18n.MsgInvalidDryRun, i18n.MsgSchedulerBusy, i18n.MsgCheckEmail, i18n.MsgEmailVerified,
+		i18n.MsgUnsubscribed, i18n.MsgSnoozed, i18n.MsgResumed, i18n.MsgInvalidSnooze,
+		i18n.MsgAlreadyVerified, i18n.MsgResendTooSoon, i18n.MsgManageTooSoon, i18n.MsgManageSent,
+		i18n.MsgReadingTemp, i18n.MsgReadingHumidity, i18n.MsgReadingCondition, i18n.MsgReadingDelta,
+		"confirm.subject", "confirm.heading", "confirm.intro", "confirm.click", "confirm.button",
+		"confirm.expires", "confirm.link_till", "manage.subject", "manage.heading", "manage.intro",
+		"manage.click", "manage.button", "alert.subject", "alert.heading", "alert.text",
+		"alert.condition", "clear.subject", "clear.heading", "clear.text", "clear.condition",
+		"digest.subject", "digest.heading", "digest.intro", "current", "unsubscribe", "snooze",
+	}
+	for _, lang := range i18n.Supported {
+		for _, key := range keys {
+			if i18n.T(lang, key) == key {
+				t.Errorf("%s: missing %q", lang, key)
+			}
+		}
+	}
+	// мова без перекладу не повинна давати ключі замість тексту
+	if i18n.T(i18n.UK, "alert.subject") == i18n.T(i18n.EN, "alert.subject") {
+		t.Error("uk alert.subject falls back to en")
+	}
+}
+
+func TestError(t *testing.T) {
+	sentinel := errors.New("city not found")
+	err := i18n.Wrap(sentinel, i18n.MsgCityNotFoundNamed, "Lviv")
+	if !errors.Is(err, sentinel) {
+		t.Error("wrapped error must match the sentinel")
+	}
+	if err.Error() != `city not found: "Lviv"` {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	wrapped := fmt.Errorf("create: %w", err)
+	if got := i18n.Message(i18n.UK, wrapped, i18n.MsgInternal); got != `місто "Lviv" не знайдено` {
+		t.Errorf("Message = %q", got)
+	}
+	if got := i18n.Message(i18n.UK, sentinel, i18n.MsgCityNotFound); got != "місто не знайдено" {
+		t.Errorf("fallback Message = %q", got)
+	}
+}
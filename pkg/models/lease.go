@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Lease — оренда в БД: лише її власник (Holder) виконує такти планувальника, доки
+// не мине ExpiresAt. Token (fencing token) зростає з кожною зміною власника, тож
+// процес, що втратив оренду, бачить це за розбіжністю токена.
+type Lease struct {
+	Name      string    `gorm:"primaryKey;size:64" json:"name"`
+	Holder    string    `gorm:"size:128;not null;default:''" json:"holder"`
+	Token     uint64    `gorm:"not null;default:0" json:"token"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+}
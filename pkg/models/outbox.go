@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Статуси повідомлення в outbox
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // вичерпано спроби; видно через адмінський ендпоінт
+	// OutboxHeld — сповіщення чекає на дайджест адреси, диспетчер його не надсилає
+	OutboxHeld = "held"
+	// OutboxDigested — сповіщення увійшло до дайджесту DigestID
+	OutboxDigested = "digested"
+)
+
+// OutboxMessage — сповіщення, записане в одній транзакції зі зміною стану
+// і надіслане пізніше диспетчером. Рядки не видаляються після відправки,
+// тож outbox водночас є журналом доставки підписки.
+type OutboxMessage struct {
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint              `gorm:"index;not null" json:"subscription_id"`
+	Recipient      string            `gorm:"size:100" json:"recipient,omitempty"` // адреса дайджесту (SubscriptionID = 0)
+	DigestID       *uint             `gorm:"index" json:"digest_id,omitempty"`
+	Kind           string            `gorm:"size:16;not null" json:"kind"`
+	Channel        string            `gorm:"size:16" json:"channel"`
+	Condition      string            `gorm:"size:255" json:"condition,omitempty"`
+	Weather        *WeatherSnapshot  `gorm:"serializer:json;type:text" json:"weather,omitempty"`
+	Subject        string            `gorm:"size:255" json:"subject"`
+	Body           string            `gorm:"type:text" json:"body"`
+	HTMLBody       string            `gorm:"type:text" json:"-"`
+	Headers        map[string]string `gorm:"serializer:json;type:text" json:"headers,omitempty"`
+	Status         string            `gorm:"size:16;not null;default:pending;index:idx_status_next" json:"status"`
+	Attempts       int               `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time         `gorm:"index:idx_status_next" json:"next_attempt_at"`
+	LastError      string            `gorm:"size:1024" json:"last_error,omitempty"`
+	SentAt         *time.Time        `json:"sent_at"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// WeatherSnapshot — показники погоди, за якими сповіщення було створено
+type WeatherSnapshot struct {
+	Temperature float64   `json:"temperature"`
+	Humidity    int       `json:"humidity"`
+	Condition   string    `json:"condition"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Режими доставки сповіщень на адресу
+const (
+	DeliveryImmediate = "immediate"
+	DeliveryHourly    = "hourly"
+	DeliveryDaily     = "daily"
+)
+
+// DeliveryPreference — налаштування доставки для адреси email, спільне для всіх її підписок.
+// У режимах hourly/daily сповіщення email-каналу збираються в один дайджест.
+type DeliveryPreference struct {
+	ID           uint       `gorm:"primaryKey" json:"-"`
+	Email        string     `gorm:"size:100;not null;uniqueIndex" json:"email"    binding:"required,email"`
+	Delivery     string     `gorm:"size:16;not null;default:immediate" json:"delivery" binding:"required,oneof=immediate hourly daily"`
+	LastDigestAt *time.Time `json:"last_digest_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// DigestPeriod повертає інтервал між дайджестами; 0 — сповіщення надсилаються одразу
+func (p DeliveryPreference) DigestPeriod() time.Duration {
+	switch p.Delivery {
+	case DeliveryHourly:
+		return time.Hour
+	case DeliveryDaily:
+		return 24 * time.Hour
+	}
+	return 0
+}
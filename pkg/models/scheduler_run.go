@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Чим запущено обчислення підписок
+const (
+	RunCron   = "cron"
+	RunManual = "manual"
+)
+
+// SchedulerRun — журнал одного обчислення всіх підписок. Matched — підписки, умова
+// яких зараз виконується (сповіщення йде лише на переході стану), Sent — сповіщення,
+// поставлені в outbox (для DryRun — ті, що були б поставлені), Failed — підписки,
+// які не вдалося обчислити.
+type SchedulerRun struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Trigger    string     `gorm:"size:16;not null" json:"trigger"`
+	DryRun     bool       `gorm:"not null;default:false" json:"dry_run"`
+	StartedAt  time.Time  `gorm:"index" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Evaluated  int        `json:"evaluated"`
+	Matched    int        `json:"matched"`
+	Sent       int        `json:"sent"`
+	Failed     int        `json:"failed"`
+	Errors     []string   `gorm:"serializer:json;type:text" json:"errors,omitempty"`
+}
@@ -2,15 +2,57 @@ package models
 
 import "time"
 
+// Стани сповіщення підписки
+const (
+	AlertStateCleared = "cleared"
+	AlertStateFired   = "fired"
+)
+
+// Канали доставки сповіщень
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack" // вхідний вебхук Slack або Mattermost
+)
+
 type Subscription struct {
-	ID                uint       `gorm:"primaryKey" json:"id"`
-	Email             string     `gorm:"size:100;not null;uniqueIndex:idx_email_city" json:"email" binding:"required,email"`
-	City              string     `gorm:"size:100;not null;uniqueIndex:idx_email_city" json:"city"  binding:"required"`
-	Condition         string     `gorm:"size:255;not null"                json:"condition" binding:"required,condition"`
-	Verified          bool       `gorm:"default:false" json:"verified"`
-	VerificationToken string     `gorm:"size:64;index" json:"-"`
-	TokenExpiresAt    *time.Time `json:"-"`
-	LastSent          *time.Time `json:"last_sent"`
-	CreatedAt         time.Time  `json:"created_at"`
-	UpdatedAt         time.Time  `json:"updated_at"`
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	Email       string  `gorm:"size:100;not null;uniqueIndex:idx_email_city" json:"email" binding:"required,email"`
+	City        string  `gorm:"size:100;not null;uniqueIndex:idx_email_city" json:"city"  binding:"required"`
+	Condition   string  `gorm:"size:255;not null"                json:"condition" binding:"required,condition"`
+	Hysteresis  float64 `gorm:"default:0"     json:"hysteresis"   binding:"gte=0"`
+	NotifyClear bool    `gorm:"default:false" json:"notify_clear"`
+	Channel     string  `gorm:"size:16;default:email" json:"channel" binding:"omitempty,oneof=email webhook slack"`
+	WebhookURL  string  `gorm:"size:512"              json:"webhook_url,omitempty" binding:"omitempty,url"`
+	Language    string  `gorm:"size:8;default:en"     json:"language"              binding:"omitempty,oneof=en uk"`
+	// Часовий пояс IANA (напр. Europe/Kyiv) і тихі години "HH:MM" у ньому;
+	// сповіщення, що випали на тихі години, відкладаються до їх кінця
+	Timezone        string     `gorm:"size:64;default:UTC"   json:"timezone"`
+	QuietStart      string     `gorm:"size:5"                json:"quiet_start,omitempty"`
+	QuietEnd        string     `gorm:"size:5"                json:"quiet_end,omitempty"`
+	IntervalMinutes int        `gorm:"not null;default:1440" json:"interval_minutes" binding:"omitempty,min=1,max=10080"` // як часто обчислювати; 0 при створенні — раз на добу
+	CooldownMinutes int        `gorm:"not null;default:0"    json:"cooldown_minutes" binding:"gte=0,max=10080"`           // найменший проміжок між сповіщеннями; 0 — без обмеження
+	NextDueAt       *time.Time `gorm:"index"                 json:"next_due_at"`                                          // коли підписку знову обчислить планувальник
+	Verified        bool       `gorm:"default:false" json:"verified"`
+	AlertState      string     `gorm:"size:16;default:cleared" json:"alert_state"`
+	StateChangedAt  *time.Time `json:"state_changed_at"`
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at"`
+	LastValue       *float64   `json:"last_value"`
+	LastSent        *time.Time `json:"last_sent"`
+	LastAlertAt     *time.Time `json:"last_alert_at"`    // коли востаннє спрацювало сповіщення (не відбій); від нього рахується cooldown
+	WebhookSecret   string     `gorm:"size:64" json:"-"` // ключ HMAC підпису вебхуків; показується лише при створенні
+	UnsubscribedAt  *time.Time `json:"unsubscribed_at"`
+	PausedUntil     *time.Time `json:"paused_until"` // до цього моменту підписка призупинена (snooze)
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Interval повертає, як часто обчислювати підписку
+func (s Subscription) Interval() time.Duration {
+	return time.Duration(s.IntervalMinutes) * time.Minute
+}
+
+// Cooldown повертає найменший проміжок між сповіщеннями підписки
+func (s Subscription) Cooldown() time.Duration {
+	return time.Duration(s.CooldownMinutes) * time.Minute
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Призначення токенів підписки; кожне має власний строк дії
+const (
+	TokenConfirm = "confirm"
+	TokenManage  = "manage"
+)
+
+// SubscriptionToken — токен дії з підпискою з листа: підтвердження (одноразовий)
+// чи керування підписками адреси.
+// Зберігається лише SHA-256 від токена (див. pkg/tokens).
+type SubscriptionToken struct {
+	ID             uint       `gorm:"primaryKey"`
+	SubscriptionID uint       `gorm:"index;not null"`
+	Purpose        string     `gorm:"size:16;not null"`
+	Hash           string     `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt      *time.Time // nil — безстроковий
+	CreatedAt      time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// WeatherReading — незмінний запис історії погоди для міста
+type WeatherReading struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	City        string    `gorm:"size:100;not null;index:idx_city_recorded" json:"city"`
+	Temperature float64   `json:"temperature"`
+	Humidity    int       `json:"humidity"`
+	Condition   string    `json:"condition"`
+	RecordedAt  time.Time `gorm:"not null;index:idx_city_recorded" json:"recorded_at"`
+}
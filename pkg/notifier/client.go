@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress повертається, коли вебхук веде на внутрішню адресу:
+// webhook_url задає підписник, тож інакше сервіс можна було б змусити
+// звертатися до власної мережі (SSRF)
+var ErrPrivateAddress = errors.New("notifier: webhook address is not public")
+
+// newClient повертає HTTP-клієнт для вебхуків. Адресу перевіряємо під час
+// з'єднання, а не лише в URL: так не допомагають ні DNS-ім'я, що вказує
+// всередину, ні перенаправлення. allowPrivate знімає перевірку (тести, локальна розробка).
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !public(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// проксі з оточення обійшов би перевірку адреси призначення
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// deniedNets — непублічні діапазони, яких не розпізнають методи net.IP
+var deniedNets = parseCIDRs(
+	"0.0.0.0/8",      // «ця мережа»: у Linux з'єднання з 0.x веде на localhost
+	"100.64.0.0/10",  // спільний простір CGNAT (RFC 6598)
+	"192.0.0.0/24",   // службові призначення IETF
+	"198.18.0.0/15",  // тестування мереж
+	"240.0.0.0/4",    // зарезервовані й broadcast
+	"::ffff:0:0/96",  // IPv4-mapped; такі адреси public перевіряє як IPv4
+	"64:ff9b::/96",   // NAT64 (RFC 6052): шлюз веде на вбудовану IPv4-адресу
+	"64:ff9b:1::/48", // локальний NAT64 (RFC 8215)
+	"2002::/16",      // 6to4 теж вбудовує IPv4-адресу
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// public повідомляє, чи ip — звичайна публічна адреса
+func public(ip net.IP) bool {
+	// ::ffff:10.0.0.1 — це 10.0.0.1
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range deniedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
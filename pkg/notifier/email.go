@@ -0,0 +1,21 @@
+package notifier
+
+import (
+	"context"
+
+	"myapp/pkg/models"
+	"myapp/pkg/utils"
+)
+
+// Email надсилає повідомлення листом на адресу підписки
+type Email struct{}
+
+func (Email) Notify(_ context.Context, sub *models.Subscription, m Message) error {
+	return utils.SendMessage(utils.Email{
+		To:      sub.Email,
+		Subject: m.Subject,
+		Body:    m.Body,
+		HTML:    m.HTML,
+		Headers: m.Headers,
+	})
+}
@@ -0,0 +1,78 @@
+// Package notifier доставляє сповіщення підписникам різними каналами:
+// email, JSON-вебхук із підписом HMAC і вхідні вебхуки Slack/Mattermost.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+)
+
+// ErrUnknownChannel повертається для каналу, який не підтримується
+var ErrUnknownChannel = errors.New("notifier: unknown channel")
+
+// Види повідомлень
+const (
+	KindConfirm = "confirm"
+	KindAlert   = "alert"
+	KindClear   = "clear"
+	KindDigest  = "digest"
+	KindManage  = "manage"
+)
+
+// Message — повідомлення незалежно від каналу доставки.
+// HTML і Headers використовуються лише для email (напр. List-Unsubscribe);
+// решта каналів надсилає текстовий Body.
+type Message struct {
+	Kind    string
+	Subject string
+	Body    string
+	HTML    string
+	Headers map[string]string
+}
+
+// Notifier доставляє повідомлення підписнику
+type Notifier interface {
+	Notify(ctx context.Context, sub *models.Subscription, m Message) error
+}
+
+// Router вибирає канал за налаштуванням підписки.
+// Підтвердження завжди йде листом: воно перевіряє саме адресу email; дайджест — теж.
+type Router struct {
+	Email   Notifier
+	Webhook Notifier
+	Slack   Notifier
+}
+
+func NewRouter(cfg config.Config) *Router {
+	client := newClient(cfg.WebhookAllowPrivate)
+	return &Router{
+		Email:   Email{},
+		Webhook: &Webhook{Client: client},
+		Slack:   &Slack{Client: client},
+	}
+}
+
+func (r *Router) Notify(ctx context.Context, sub *models.Subscription, m Message) error {
+	switch ch := ChannelFor(sub, m); ch {
+	case models.ChannelEmail:
+		return r.Email.Notify(ctx, sub, m)
+	case models.ChannelWebhook:
+		return r.Webhook.Notify(ctx, sub, m)
+	case models.ChannelSlack:
+		return r.Slack.Notify(ctx, sub, m)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownChannel, ch)
+	}
+}
+
+// ChannelFor повертає канал, яким Router доставить повідомлення m підписці sub
+func ChannelFor(sub *models.Subscription, m Message) string {
+	if m.Kind == KindConfirm || m.Kind == KindManage || m.Kind == KindDigest || sub.Channel == "" {
+		return models.ChannelEmail
+	}
+	return sub.Channel
+}
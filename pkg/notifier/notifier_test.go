@@ -0,0 +1,169 @@
+package notifier_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/notifier/notifiertest"
+	"myapp/pkg/utils"
+)
+
+func TestWebhook_SignedPayload(t *testing.T) {
+	rcv := notifiertest.NewReceiver()
+	defer rcv.Close()
+
+	r := notifier.NewRouter(config.Config{WebhookAllowPrivate: true})
+	sub := &models.Subscription{ID: 5, City: "Kyiv", Condition: "temp < 0", Channel: models.ChannelWebhook, WebhookURL: rcv.URL + "/hook", WebhookSecret: "s3cret"}
+	if err := r.Notify(context.Background(), sub, notifier.Message{Kind: notifier.KindAlert, Subject: "Weather Alert for Kyiv", Body: "cold"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reqs := rcv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("want 1 request, got %d", len(reqs))
+	}
+	req := reqs[0]
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected content type %q", req.Header.Get("Content-Type"))
+	}
+	ts := req.Header.Get(notifier.HeaderTimestamp)
+	want := "sha256=" + notifier.Sign([]byte("s3cret"), ts, req.Body)
+	if ts == "" || req.Header.Get(notifier.HeaderSignature) != want {
+		t.Errorf("signature mismatch: ts=%q sig=%q", ts, req.Header.Get(notifier.HeaderSignature))
+	}
+
+	var p notifier.WebhookPayload
+	if err := json.Unmarshal(req.Body, &p); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if p.Event != notifier.KindAlert || p.SubscriptionID != 5 || p.City != "Kyiv" || p.Text != "cold" {
+		t.Errorf("unexpected payload: %+v", p)
+	}
+}
+
+// Вебхук на внутрішню адресу не надсилається: webhook_url задає підписник
+func TestWebhook_RejectsPrivateAddress(t *testing.T) {
+	rcv := notifiertest.NewReceiver()
+	defer rcv.Close()
+
+	r := notifier.NewRouter(config.Config{})
+	for _, ch := range []string{models.ChannelWebhook, models.ChannelSlack} {
+		sub := &models.Subscription{ID: 5, Channel: ch, WebhookURL: rcv.URL, WebhookSecret: "s3cret"}
+		if err := r.Notify(context.Background(), sub, notifier.Message{Kind: notifier.KindAlert}); !errors.Is(err, notifier.ErrPrivateAddress) {
+			t.Errorf("%s: want ErrPrivateAddress, got %v", ch, err)
+		}
+	}
+	if n := len(rcv.Requests()); n != 0 {
+		t.Errorf("want no requests, got %d", n)
+	}
+}
+
+// Непублічні діапазони, яких не знає net.IP, і IPv4-адреси в IPv6-формі теж
+// відхиляються ще до з'єднання
+func TestWebhook_RejectsReservedAddress(t *testing.T) {
+	r := notifier.NewRouter(config.Config{})
+	for _, host := range []string{
+		"100.64.0.1",
+		"0.0.0.1",
+		"[::ffff:127.0.0.1]",
+		"[::ffff:10.0.0.1]",
+		"[::ffff:100.64.0.1]",
+		"[64:ff9b::a00:1]",
+		"[2002:a00:1::1]",
+	} {
+		sub := &models.Subscription{ID: 5, Channel: models.ChannelWebhook, WebhookURL: "http://" + host + ":8080/hook", WebhookSecret: "s3cret"}
+		if err := r.Notify(context.Background(), sub, notifier.Message{Kind: notifier.KindAlert}); !errors.Is(err, notifier.ErrPrivateAddress) {
+			t.Errorf("%s: want ErrPrivateAddress, got %v", host, err)
+		}
+	}
+}
+
+// Без ключа підпису вебхук не надсилається зовсім
+func TestWebhook_RequiresSecret(t *testing.T) {
+	rcv := notifiertest.NewReceiver()
+	defer rcv.Close()
+
+	r := notifier.NewRouter(config.Config{WebhookAllowPrivate: true})
+	sub := &models.Subscription{ID: 5, Channel: models.ChannelWebhook, WebhookURL: rcv.URL}
+	if err := r.Notify(context.Background(), sub, notifier.Message{Kind: notifier.KindAlert}); !errors.Is(err, notifier.ErrNoWebhookSecret) {
+		t.Fatalf("want ErrNoWebhookSecret, got %v", err)
+	}
+	if n := len(rcv.Requests()); n != 0 {
+		t.Errorf("unsigned webhook must not be sent, got %d requests", n)
+	}
+}
+
+func TestSlack_Text(t *testing.T) {
+	rcv := notifiertest.NewReceiver()
+	defer rcv.Close()
+
+	r := notifier.NewRouter(config.Config{WebhookAllowPrivate: true})
+	sub := &models.Subscription{Channel: models.ChannelSlack, WebhookURL: rcv.URL}
+	if err := r.Notify(context.Background(), sub, notifier.Message{Kind: notifier.KindAlert, Subject: "Alert", Body: "line"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reqs := rcv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("want 1 request, got %d", len(reqs))
+	}
+	var body map[string]string
+	json.Unmarshal(reqs[0].Body, &body)
+	if body["text"] != "*Alert*\nline" {
+		t.Errorf("unexpected text: %q", body["text"])
+	}
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	rcv := notifiertest.NewReceiver()
+	defer rcv.Close()
+	rcv.SetStatus(http.StatusInternalServerError)
+
+	r := notifier.NewRouter(config.Config{WebhookAllowPrivate: true})
+	for _, ch := range []string{models.ChannelWebhook, models.ChannelSlack} {
+		sub := &models.Subscription{Channel: ch, WebhookURL: rcv.URL, WebhookSecret: "s3cret"}
+		err := r.Notify(context.Background(), sub, notifier.Message{Kind: notifier.KindAlert})
+		if err == nil || !strings.Contains(err.Error(), "500") {
+			t.Errorf("%s: want status error, got %v", ch, err)
+		}
+	}
+}
+
+func TestRouter_Channels(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	var mailed []string
+	utils.SendMessage = func(e utils.Email) error {
+		mailed = append(mailed, e.Subject)
+		return nil
+	}
+
+	rcv := notifiertest.NewReceiver()
+	defer rcv.Close()
+	r := notifier.NewRouter(config.Config{WebhookAllowPrivate: true})
+	hook := &models.Subscription{Email: "a@b", Channel: models.ChannelWebhook, WebhookURL: rcv.URL, WebhookSecret: "s3cret"}
+
+	// підтвердження завжди листом, сповіщення — каналом підписки
+	r.Notify(context.Background(), hook, notifier.Message{Kind: notifier.KindConfirm, Subject: "confirm"})
+	r.Notify(context.Background(), hook, notifier.Message{Kind: notifier.KindAlert, Subject: "alert"})
+	r.Notify(context.Background(), &models.Subscription{Email: "a@b"}, notifier.Message{Kind: notifier.KindAlert, Subject: "legacy"})
+
+	if strings.Join(mailed, ",") != "confirm,legacy" {
+		t.Errorf("unexpected emails: %v", mailed)
+	}
+	if len(rcv.Requests()) != 1 {
+		t.Errorf("want 1 webhook request, got %d", len(rcv.Requests()))
+	}
+
+	err := r.Notify(context.Background(), &models.Subscription{Channel: "pigeon"}, notifier.Message{Kind: notifier.KindAlert})
+	if !errors.Is(err, notifier.ErrUnknownChannel) {
+		t.Errorf("want ErrUnknownChannel, got %v", err)
+	}
+}
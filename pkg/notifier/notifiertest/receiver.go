@@ -0,0 +1,59 @@
+// Package notifiertest містить локальний отримувач вебхуків для тестів
+package notifiertest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Request — запит, отриманий заглушкою
+type Request struct {
+	Header http.Header
+	Body   []byte
+}
+
+// Receiver записує всі POST-запити і відповідає кодом Status (200 за замовчуванням)
+type Receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []Request
+}
+
+func NewReceiver() *Receiver {
+	r := &Receiver{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.handle))
+	return r
+}
+
+// SetStatus задає код відповіді на наступні запити
+func (r *Receiver) SetStatus(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = code
+}
+
+// Requests повертає копію отриманих запитів
+func (r *Receiver) Requests() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+func (r *Receiver) handle(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	r.requests = append(r.requests, Request{Header: req.Header.Clone(), Body: body})
+	code := r.status
+	r.mu.Unlock()
+
+	w.WriteHeader(code)
+}
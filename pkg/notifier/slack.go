@@ -0,0 +1,25 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"myapp/pkg/models"
+)
+
+// Slack надсилає повідомлення у вхідний вебхук Slack. Mattermost приймає
+// той самий формат, тож канал працює з обома.
+type Slack struct {
+	Client *http.Client
+}
+
+func (s *Slack) Notify(ctx context.Context, sub *models.Subscription, m Message) error {
+	body, err := json.Marshal(map[string]string{
+		"text": "*" + m.Subject + "*\n" + m.Body,
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.Client, sub.WebhookURL, body, nil)
+}
@@ -0,0 +1,100 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"myapp/pkg/models"
+)
+
+// Заголовки запиту вебхука
+const (
+	HeaderTimestamp = "X-Weather-Alert-Timestamp"
+	HeaderSignature = "X-Weather-Alert-Signature"
+)
+
+// WebhookPayload — тіло JSON-вебхука
+type WebhookPayload struct {
+	Event          string    `json:"event"`
+	SubscriptionID uint      `json:"subscription_id"`
+	City           string    `json:"city"`
+	Condition      string    `json:"condition"`
+	Subject        string    `json:"subject"`
+	Text           string    `json:"text"`
+	SentAt         time.Time `json:"sent_at"`
+}
+
+// ErrNoWebhookSecret — у підписки немає ключа підпису; непідписаний вебхук не надсилається
+var ErrNoWebhookSecret = errors.New("notifier: subscription has no webhook secret")
+
+// Webhook надсилає POST із JSON на WebhookURL підписки. Запит підписується ключем
+// підписки: HeaderSignature = "sha256=" + hex(HMAC(WebhookSecret, timestamp + "." + body)).
+type Webhook struct {
+	Client *http.Client
+}
+
+func (w *Webhook) Notify(ctx context.Context, sub *models.Subscription, m Message) error {
+	if sub.WebhookSecret == "" {
+		return ErrNoWebhookSecret
+	}
+	now := time.Now().UTC()
+	body, err := json.Marshal(WebhookPayload{
+		Event:          m.Kind,
+		SubscriptionID: sub.ID,
+		City:           sub.City,
+		Condition:      sub.Condition,
+		Subject:        m.Subject,
+		Text:           m.Body,
+		SentAt:         now,
+	})
+	if err != nil {
+		return err
+	}
+
+	ts := strconv.FormatInt(now.Unix(), 10)
+	headers := map[string]string{
+		HeaderTimestamp: ts,
+		HeaderSignature: "sha256=" + Sign([]byte(sub.WebhookSecret), ts, body),
+	}
+	return post(ctx, w.Client, sub.WebhookURL, body, headers)
+}
+
+// Sign обчислює підпис вебхука; отримувач має порівняти його зі своїм
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// post надсилає JSON і вважає успіхом лише відповідь 2xx
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notifier: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notifier: post to %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notifier: %s responded with status %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
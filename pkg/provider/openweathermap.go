@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+)
+
+// OpenWeatherMap — клієнт API, сумісного з OpenWeatherMap /data/2.5/weather
+type OpenWeatherMap struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+func NewOpenWeatherMap(cfg config.Config) *OpenWeatherMap {
+	return &OpenWeatherMap{
+		BaseURL: strings.TrimRight(cfg.WeatherAPIURL, "/"),
+		APIKey:  cfg.WeatherAPIKey,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// owmResponse — частина відповіді OpenWeatherMap, яку ми використовуємо
+type owmResponse struct {
+	Name string `json:"name"`
+	Main struct {
+		Temp     float64 `json:"temp"`
+		Humidity int     `json:"humidity"`
+	} `json:"main"`
+	Weather []struct {
+		Main string `json:"main"`
+	} `json:"weather"`
+}
+
+func (p *OpenWeatherMap) Current(ctx context.Context, city string) (models.Weather, error) {
+	q := url.Values{}
+	q.Set("q", city)
+	q.Set("units", "metric")
+	if p.APIKey != "" {
+		q.Set("appid", p.APIKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/data/2.5/weather?"+q.Encode(), nil)
+	if err != nil {
+		return models.Weather{}, err
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return models.Weather{}, fmt.Errorf("provider: request for %q: %w", city, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return models.Weather{}, fmt.Errorf("%w: %q", ErrUnknownCity, city)
+	case resp.StatusCode != http.StatusOK:
+		return models.Weather{}, fmt.Errorf("provider: unexpected status %d for %q", resp.StatusCode, city)
+	}
+
+	var body owmResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return models.Weather{}, fmt.Errorf("provider: decode response for %q: %w", city, err)
+	}
+
+	w := models.Weather{
+		City:        city,
+		Temperature: body.Main.Temp,
+		Humidity:    body.Main.Humidity,
+	}
+	if len(body.Weather) > 0 {
+		w.Condition = body.Weather[0].Main
+	}
+	return w, nil
+}
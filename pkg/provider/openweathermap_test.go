@@ -0,0 +1,58 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myapp/pkg/config"
+	"myapp/pkg/provider"
+	"myapp/pkg/provider/providertest"
+)
+
+func TestOpenWeatherMap_Current(t *testing.T) {
+	srv := providertest.NewServer()
+	defer srv.Close()
+	srv.Set("Kyiv", providertest.Reading{Temperature: -4.5, Humidity: 81, Condition: "Snow"})
+
+	p := provider.NewOpenWeatherMap(config.Config{WeatherAPIURL: srv.URL + "/", WeatherAPIKey: "k"})
+
+	w, err := p.Current(context.Background(), "kyiv")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.City != "kyiv" || w.Temperature != -4.5 || w.Humidity != 81 || w.Condition != "Snow" {
+		t.Errorf("unexpected weather: %+v", w)
+	}
+	if srv.Requests() != 1 {
+		t.Errorf("want 1 request, got %d", srv.Requests())
+	}
+}
+
+func TestOpenWeatherMap_UnknownCity(t *testing.T) {
+	srv := providertest.NewServer()
+	defer srv.Close()
+
+	p := provider.NewOpenWeatherMap(config.Config{WeatherAPIURL: srv.URL})
+	if _, err := p.Current(context.Background(), "Atlantis"); !errors.Is(err, provider.ErrUnknownCity) {
+		t.Errorf("want ErrUnknownCity, got %v", err)
+	}
+}
+
+func TestOpenWeatherMap_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("appid") != "secret" || r.URL.Query().Get("units") != "metric" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	p := provider.NewOpenWeatherMap(config.Config{WeatherAPIURL: srv.URL, WeatherAPIKey: "secret"})
+	_, err := p.Current(context.Background(), "Kyiv")
+	if err == nil || errors.Is(err, provider.ErrUnknownCity) {
+		t.Errorf("want status error, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+// Package provider отримує поточну погоду із зовнішніх джерел
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"myapp/pkg/models"
+)
+
+// ErrUnknownCity повертається, коли джерело не знає вказаного міста
+var ErrUnknownCity = errors.New("provider: unknown city")
+
+// WeatherProvider повертає поточну погоду для міста
+type WeatherProvider interface {
+	Current(ctx context.Context, city string) (models.Weather, error)
+}
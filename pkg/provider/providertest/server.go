@@ -0,0 +1,75 @@
+// Package providertest містить локальний сервер-заглушку OpenWeatherMap для тестів
+package providertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Reading — погода, яку заглушка повертає для міста
+type Reading struct {
+	Temperature float64
+	Humidity    int
+	Condition   string
+}
+
+// Server відповідає на GET /data/2.5/weather?q=<city> у форматі OpenWeatherMap.
+// Невідомі міста отримують 404, як і в справжньому API.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	readings map[string]Reading
+	requests int
+}
+
+func NewServer() *Server {
+	s := &Server{readings: map[string]Reading{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Set задає погоду для міста (назва нечутлива до регістру)
+func (s *Server) Set(city string, r Reading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readings[strings.ToLower(city)] = r
+}
+
+// Requests повертає кількість отриманих запитів погоди
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/data/2.5/weather" {
+		http.NotFound(w, r)
+		return
+	}
+	city := r.URL.Query().Get("q")
+
+	s.mu.Lock()
+	s.requests++
+	rd, ok := s.readings[strings.ToLower(city)]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"cod": "404", "message": "city not found"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name": city,
+		"main": map[string]interface{}{
+			"temp":     rd.Temperature,
+			"humidity": rd.Humidity,
+		},
+		"weather": []map[string]string{{"main": rd.Condition}},
+	})
+}
@@ -3,6 +3,11 @@ package repository
 import (
 	"myapp/pkg/database"
 	models2 "myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GormRepo struct{}
@@ -18,35 +23,590 @@ func (r *GormRepo) GetByCity(city string) (models2.Weather, error) {
 	return w, err
 }
 
+// Save зберігає поточну погоду і додає запис в історію в одній транзакції
 func (r *GormRepo) Save(w *models2.Weather) error {
-	return database.DB.Save(w).Error
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(w).Error; err != nil {
+			return err
+		}
+		return appendReading(tx, w)
+	})
 }
 
+// UpdateWeather оновлює поточну погоду міста і додає запис в історію
 func (r *GormRepo) UpdateWeather(city string, updates map[string]interface{}) error {
-	return database.DB.
-		Model(&models2.Weather{}).
-		Where("city = ?", city).
-		Updates(updates).
-		Error
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Model(&models2.Weather{}).
+			Where("city = ?", city).
+			Updates(updates).
+			Error
+		if err != nil {
+			return err
+		}
+		var w models2.Weather
+		if err := tx.First(&w, "city = ?", city).Error; err != nil {
+			// міста немає — нічого не оновлено, історію не чіпаємо
+			return nil
+		}
+		return appendReading(tx, &w)
+	})
+}
+
+func appendReading(tx *gorm.DB, w *models2.Weather) error {
+	return tx.Create(&models2.WeatherReading{
+		City:        w.City,
+		Temperature: w.Temperature,
+		Humidity:    w.Humidity,
+		Condition:   w.Condition,
+		RecordedAt:  time.Now(),
+	}).Error
+}
+
+// --- Weather history ---
+func (r *GormRepo) ReadingsSince(city string, since time.Time) ([]models2.WeatherReading, error) {
+	var out []models2.WeatherReading
+	err := database.DB.
+		Where("city = ? AND recorded_at >= ?", city, since).
+		Order("recorded_at").
+		Find(&out).Error
+	return out, err
+}
+
+func (r *GormRepo) LastReadingBefore(city string, t time.Time) (*models2.WeatherReading, error) {
+	var rs []models2.WeatherReading
+	err := database.DB.
+		Where("city = ? AND recorded_at < ?", city, t).
+		Order("recorded_at DESC").
+		Limit(1).
+		Find(&rs).Error
+	if err != nil || len(rs) == 0 {
+		return nil, err
+	}
+	return &rs[0], nil
+}
+
+func (r *GormRepo) ReadingsBetween(city string, from, to time.Time, limit int) ([]models2.WeatherReading, error) {
+	var out []models2.WeatherReading
+	q := database.DB.
+		Where("city = ? AND recorded_at >= ? AND recorded_at < ?", city, from, to).
+		Order("recorded_at")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Find(&out).Error
+	return out, err
+}
+
+func (r *GormRepo) DeleteReadingsBefore(t time.Time) (int64, error) {
+	res := database.DB.Where("recorded_at < ?", t).Delete(&models2.WeatherReading{})
+	return res.RowsAffected, res.Error
 }
 
 // --- Subscription ---
-func (r *GormRepo) Create(sub *models2.Subscription) error {
-	return database.DB.Create(sub).Error
+func (r *GormRepo) Create(sub *models2.Subscription, msg *models2.OutboxMessage) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sub).Error; err != nil {
+			return err
+		}
+		return enqueue(tx, sub.ID, msg)
+	})
 }
 
 func (r *GormRepo) FindAllVerified() ([]models2.Subscription, error) {
 	var subs []models2.Subscription
-	err := database.DB.Where("verified = ?", true).Find(&subs).Error
+	err := database.DB.Where("verified = ? AND unsubscribed_at IS NULL", true).Find(&subs).Error
+	return subs, err
+}
+
+func (r *GormRepo) FindDueVerified(now time.Time) ([]models2.Subscription, error) {
+	var subs []models2.Subscription
+	err := database.DB.
+		Where("verified = ? AND unsubscribed_at IS NULL", true).
+		Where("next_due_at IS NULL OR next_due_at <= ?", now).
+		Find(&subs).
+		Error
+	return subs, err
+}
+
+func (r *GormRepo) FindVerifiedByCity(city string) ([]models2.Subscription, error) {
+	var subs []models2.Subscription
+	err := database.DB.
+		Where("verified = ? AND unsubscribed_at IS NULL AND city = ?", true, city).
+		Find(&subs).
+		Error
 	return subs, err
 }
 
-func (r *GormRepo) FindByToken(token string) (models2.Subscription, error) {
+func (r *GormRepo) FindByID(id uint) (models2.Subscription, error) {
 	var sub models2.Subscription
-	err := database.DB.Where("verification_token = ?", token).First(&sub).Error
+	err := database.DB.First(&sub, id).Error
 	return sub, err
 }
 
-func (r *GormRepo) UpdateSubscription(sub *models2.Subscription) error {
-	return database.DB.Save(sub).Error
+func (r *GormRepo) FindByIDs(ids []uint) ([]models2.Subscription, error) {
+	var subs []models2.Subscription
+	if len(ids) == 0 {
+		return subs, nil
+	}
+	err := database.DB.Where("id IN ?", ids).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func (r *GormRepo) UnsubscribeEmail(email string, at time.Time) (int64, error) {
+	res := database.DB.
+		Model(&models2.Subscription{}).
+		Where("email = ? AND unsubscribed_at IS NULL", email).
+		Update("unsubscribed_at", at)
+	return res.RowsAffected, res.Error
+}
+
+func (r *GormRepo) FindByEmailCity(email, city string) (models2.Subscription, error) {
+	var sub models2.Subscription
+	err := database.DB.Where("email = ? AND city = ?", email, city).First(&sub).Error
+	return sub, err
+}
+
+func (r *GormRepo) FindByEmail(email string, offset, limit int) ([]models2.Subscription, int64, error) {
+	var total int64
+	if err := database.DB.Model(&models2.Subscription{}).Where("email = ?", email).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var subs []models2.Subscription
+	err := database.DB.
+		Where("email = ?", email).
+		Order("id").
+		Offset(offset).
+		Limit(limit).
+		Find(&subs).Error
+	return subs, total, err
+}
+
+func (r *GormRepo) Delete(id uint) (bool, error) {
+	var ok bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// токен керування діє для всієї адреси: переносимо його на іншу її підписку,
+		// щоб видалення однієї підписки не завершувало сеанс керування
+		var next []models2.Subscription
+		err := tx.
+			Select("id").
+			Where("email = (?) AND id <> ?", tx.Model(&models2.Subscription{}).Select("email").Where("id = ?", id), id).
+			Order("id").
+			Limit(1).
+			Find(&next).
+			Error
+		if err != nil {
+			return err
+		}
+		if len(next) > 0 {
+			err := tx.
+				Model(&models2.SubscriptionToken{}).
+				Where("subscription_id = ? AND purpose = ?", id, models2.TokenManage).
+				Update("subscription_id", next[0].ID).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("subscription_id = ?", id).Delete(&models2.SubscriptionToken{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&models2.Subscription{}, id)
+		ok = res.RowsAffected > 0
+		return res.Error
+	})
+	return ok, err
+}
+
+func (r *GormRepo) UpdateColumns(id uint, updates map[string]interface{}) error {
+	return database.DB.Model(&models2.Subscription{ID: id}).Updates(updates).Error
+}
+
+// SaveAlertState зберігає лише поля стану сповіщення, не чіпаючи налаштувань підписки.
+// Умова на alert_state не дає cron і обчисленню за подією надіслати один перехід двічі:
+// той, хто прочитав застарілий стан, не оновить жодного рядка.
+func (r *GormRepo) SaveAlertState(sub *models2.Subscription, prevState string, msg *models2.OutboxMessage, fence *Fence) (bool, error) {
+	saved := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.
+			Model(&models2.Subscription{ID: sub.ID}).
+			Scopes(fenced(fence)).
+			Where("alert_state = ?", prevState).
+			Select("alert_state", "state_changed_at", "last_evaluated_at", "last_value", "last_sent", "last_alert_at", "next_due_at").
+			Updates(sub)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		saved = true
+		return enqueue(tx, sub.ID, msg)
+	})
+	return saved, err
+}
+
+func (r *GormRepo) LastQueuedAt(email, kind string) (*time.Time, error) {
+	var msgs []models2.OutboxMessage
+	err := database.DB.
+		Joins("JOIN subscriptions ON subscriptions.id = outbox_messages.subscription_id").
+		Where("subscriptions.email = ? AND outbox_messages.kind = ?", email, kind).
+		Order("outbox_messages.id DESC").
+		Limit(1).
+		Find(&msgs).Error
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+	return &msgs[0].CreatedAt, nil
+}
+
+func (r *GormRepo) DeleteUnverified(t time.Time) (int64, error) {
+	var n int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		live := tx.
+			Model(&models2.SubscriptionToken{}).
+			Select("subscription_id").
+			Where("purpose = ? AND (expires_at IS NULL OR expires_at >= ?)", models2.TokenConfirm, t)
+		// токен для листа, що ще в черзі, диспетчер видасть під час надсилання
+		queued := tx.
+			Model(&models2.OutboxMessage{}).
+			Select("subscription_id").
+			Where("kind = ? AND status = ?", notifier.KindConfirm, models2.OutboxPending)
+		var ids []uint
+		err := tx.
+			Model(&models2.Subscription{}).
+			Where("verified = ? AND created_at < ? AND id NOT IN (?) AND id NOT IN (?)", false, t, live, queued).
+			Pluck("id", &ids).
+			Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := tx.Where("subscription_id IN ?", ids).Delete(&models2.SubscriptionToken{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&models2.Subscription{}, ids)
+		n = res.RowsAffected
+		return res.Error
+	})
+	return n, err
+}
+
+// enqueue додає повідомлення в outbox у межах транзакції tx
+func enqueue(tx *gorm.DB, subID uint, msg *models2.OutboxMessage) error {
+	if msg == nil {
+		return nil
+	}
+	msg.SubscriptionID = subID
+	if msg.Status == "" {
+		msg.Status = models2.OutboxPending
+	}
+	return tx.Create(msg).Error
+}
+
+func (r *GormRepo) SubscribedCities() ([]string, error) {
+	var cities []string
+	err := database.DB.
+		Model(&models2.Subscription{}).
+		Where("unsubscribed_at IS NULL").
+		Distinct("city").
+		Order("city").
+		Pluck("city", &cities).Error
+	return cities, err
+}
+
+// --- Tokens ---
+func (r *GormRepo) FindToken(hash string) (*models2.SubscriptionToken, error) {
+	var toks []models2.SubscriptionToken
+	if err := database.DB.Where("hash = ?", hash).Limit(1).Find(&toks).Error; err != nil || len(toks) == 0 {
+		return nil, err
+	}
+	return &toks[0], nil
+}
+
+func (r *GormRepo) ReplaceToken(tok *models2.SubscriptionToken, fence *Fence) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where("subscription_id = ? AND purpose = ?", tok.SubscriptionID, tok.Purpose).
+			Delete(&models2.SubscriptionToken{}).
+			Error
+		if err != nil {
+			return err
+		}
+		if fence == nil {
+			return tx.Create(tok).Error
+		}
+		// INSERT ... SELECT з рядка оренди вставляє токен, лише поки оренда чинна;
+		// інакше транзакція відкочує й видалення попередніх
+		now := time.Now()
+		res := tx.Exec(
+			"INSERT INTO subscription_tokens (subscription_id, purpose, hash, expires_at, created_at) "+
+				"SELECT ?, ?, ?, ?, ? FROM leases WHERE name = ? AND holder = ? AND token = ? AND expires_at > ?",
+			tok.SubscriptionID, tok.Purpose, tok.Hash, tok.ExpiresAt, now,
+			fence.Name, fence.Holder, fence.Token, now,
+		)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrFenced
+		}
+		tok.CreatedAt = now
+		return nil
+	})
+}
+
+func (r *GormRepo) RevokeTokens(subID uint, purpose string, msg *models2.OutboxMessage) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where("subscription_id = ? AND purpose = ?", subID, purpose).
+			Delete(&models2.SubscriptionToken{}).
+			Error
+		if err != nil {
+			return err
+		}
+		return enqueue(tx, subID, msg)
+	})
+}
+
+func (r *GormRepo) RedeemToken(tok *models2.SubscriptionToken, sub *models2.Subscription) (bool, error) {
+	var ok bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models2.SubscriptionToken{}, tok.ID)
+		if res.Error != nil || res.RowsAffected == 0 {
+			// токен тим часом використав інший запит
+			return res.Error
+		}
+		ok = true
+		if sub == nil {
+			return nil
+		}
+		return tx.Save(sub).Error
+	})
+	return ok, err
+}
+
+// --- Outbox ---
+func (r *GormRepo) DueOutbox(now time.Time, limit int) ([]models2.OutboxMessage, error) {
+	var out []models2.OutboxMessage
+	err := database.DB.
+		Where("status = ? AND next_attempt_at <= ?", models2.OutboxPending, now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&out).Error
+	return out, err
+}
+
+func (r *GormRepo) SaveOutbox(msg *models2.OutboxMessage, fence *Fence) error {
+	return database.DB.
+		Model(&models2.OutboxMessage{ID: msg.ID}).
+		Scopes(fenced(fence)).
+		Select("channel", "status", "attempts", "next_attempt_at", "last_error", "sent_at").
+		Updates(msg).
+		Error
+}
+
+func (r *GormRepo) FindOutboxByID(id uint) (models2.OutboxMessage, error) {
+	var msg models2.OutboxMessage
+	err := database.DB.First(&msg, id).Error
+	return msg, err
+}
+
+func (r *GormRepo) FindOutbox(status string, offset, limit int) ([]models2.OutboxMessage, int64, error) {
+	q := func() *gorm.DB {
+		db := database.DB.Model(&models2.OutboxMessage{})
+		if status != "" {
+			db = db.Where("status = ?", status)
+		}
+		return db
+	}
+	var total int64
+	if err := q().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []models2.OutboxMessage
+	err := q().Order("id DESC").Offset(offset).Limit(limit).Find(&out).Error
+	return out, total, err
+}
+
+func (r *GormRepo) FindOutboxBySubscription(subID uint, offset, limit int) ([]models2.OutboxMessage, int64, error) {
+	var total int64
+	err := database.DB.Model(&models2.OutboxMessage{}).Where("subscription_id = ?", subID).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	var out []models2.OutboxMessage
+	err = database.DB.
+		Where("subscription_id = ?", subID).
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&out).Error
+	return out, total, err
+}
+
+// --- Digest ---
+func (r *GormRepo) FindPreference(email string) (*models2.DeliveryPreference, error) {
+	var ps []models2.DeliveryPreference
+	err := database.DB.Where("email = ?", email).Limit(1).Find(&ps).Error
+	if err != nil || len(ps) == 0 {
+		return nil, err
+	}
+	return &ps[0], nil
+}
+
+func (r *GormRepo) SavePreference(p *models2.DeliveryPreference) error {
+	return database.DB.Save(p).Error
+}
+
+func (r *GormRepo) DigestPreferences() ([]models2.DeliveryPreference, error) {
+	var out []models2.DeliveryPreference
+	err := database.DB.
+		Where("delivery <> ?", models2.DeliveryImmediate).
+		Order("id").
+		Find(&out).Error
+	return out, err
+}
+
+func (r *GormRepo) HeldOutbox(email string) ([]models2.OutboxMessage, error) {
+	var out []models2.OutboxMessage
+	err := database.DB.
+		Joins("JOIN subscriptions ON subscriptions.id = outbox_messages.subscription_id").
+		Where("outbox_messages.status = ? AND subscriptions.email = ?", models2.OutboxHeld, email).
+		Order("outbox_messages.id").
+		Find(&out).Error
+	return out, err
+}
+
+func (r *GormRepo) ReleaseHeld(p *models2.DeliveryPreference, now time.Time) (int64, error) {
+	var n int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(p).Error; err != nil {
+			return err
+		}
+		res := tx.
+			Model(&models2.OutboxMessage{}).
+			Where("status = ? AND subscription_id IN (?)", models2.OutboxHeld,
+				tx.Model(&models2.Subscription{}).Select("id").Where("email = ?", p.Email)).
+			Updates(map[string]interface{}{"status": models2.OutboxPending, "next_attempt_at": now})
+		n = res.RowsAffected
+		return res.Error
+	})
+	return n, err
+}
+
+func (r *GormRepo) SaveDigest(
+	p *models2.DeliveryPreference,
+	digest *models2.OutboxMessage,
+	held []models2.OutboxMessage,
+	now time.Time,
+	fence *Fence,
+) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if digest != nil {
+			if err := enqueue(tx, 0, digest); err != nil {
+				return err
+			}
+		}
+		var subIDs []uint
+		for i := range held {
+			msg := &held[i]
+			if msg.Status == models2.OutboxHeld {
+				// лишається чекати (напр. підписка на паузі)
+				continue
+			}
+			if msg.Status == models2.OutboxDigested && digest != nil {
+				msg.DigestID = &digest.ID
+				subIDs = append(subIDs, msg.SubscriptionID)
+			}
+			res := tx.
+				Model(&models2.OutboxMessage{ID: msg.ID}).
+				Scopes(fenced(fence)).
+				Select("status", "digest_id", "last_error").
+				Updates(msg)
+			if res.Error != nil {
+				return res.Error
+			}
+			// статус held завжди змінюється, тож жодного рядка — лише за втраченої оренди;
+			// помилка відкочує і вже поставлений digest
+			if res.RowsAffected == 0 && fence != nil {
+				return ErrFenced
+			}
+		}
+		if len(subIDs) > 0 {
+			err := tx.
+				Model(&models2.Subscription{}).
+				Where("id IN ?", subIDs).
+				Update("last_sent", now).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+		p.LastDigestAt = &now
+		return tx.Save(p).Error
+	})
+}
+
+// --- Leases ---
+func (r *GormRepo) AcquireLease(name, holder string, ttl time.Duration, now time.Time) (uint64, bool, error) {
+	var lease models2.Lease
+	acquired := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// рядок оренди створюється вільним при першому зверненні
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models2.Lease{Name: name, ExpiresAt: now}).
+			Error
+		if err != nil {
+			return err
+		}
+		// Умовний UPDATE атомарний: з двох процесів, що побачили прострочену оренду,
+		// рядок оновить лише перший. Токен рахується до зміни holder — MySQL
+		// обчислює SET зліва направо.
+		res := tx.Exec(
+			"UPDATE leases SET token = CASE WHEN holder = ? THEN token ELSE token + 1 END, holder = ?, expires_at = ? "+
+				"WHERE name = ? AND (holder = ? OR expires_at <= ?)",
+			holder, holder, now.Add(ttl), name, holder, now,
+		)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		acquired = true
+		return tx.First(&lease, "name = ?", name).Error
+	})
+	return lease.Token, acquired, err
+}
+
+// fenced додає до UPDATE умову, що оренда fence досі чинна; з nil запит не змінюється.
+// Умова перевіряється тим самим запитом, що пише, тож між перевіркою і записом
+// оренду не можна перехопити.
+func fenced(fence *Fence) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if fence == nil {
+			return db
+		}
+		return db.Where(
+			"EXISTS (SELECT 1 FROM leases WHERE leases.name = ? AND leases.holder = ? AND leases.token = ? AND leases.expires_at > ?)",
+			fence.Name, fence.Holder, fence.Token, time.Now(),
+		)
+	}
+}
+
+func (r *GormRepo) FindLease(name string) (*models2.Lease, error) {
+	var leases []models2.Lease
+	if err := database.DB.Where("name = ?", name).Limit(1).Find(&leases).Error; err != nil || len(leases) == 0 {
+		return nil, err
+	}
+	return &leases[0], nil
+}
+
+// --- Scheduler runs ---
+func (r *GormRepo) SaveRun(run *models2.SchedulerRun) error {
+	return database.DB.Save(run).Error
+}
+
+func (r *GormRepo) FindRuns(offset, limit int) ([]models2.SchedulerRun, int64, error) {
+	var total int64
+	if err := database.DB.Model(&models2.SchedulerRun{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var out []models2.SchedulerRun
+	err := database.DB.Order("id DESC").Offset(offset).Limit(limit).Find(&out).Error
+	return out, total, err
 }
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"errors"
 	models2 "myapp/pkg/models"
+	"time"
 )
 
 // WeatherRepository описує операції з моделлю Weather
@@ -11,10 +13,127 @@ type WeatherRepository interface {
 	UpdateWeather(city string, updates map[string]interface{}) error
 }
 
+// WeatherHistoryRepository описує читання історії показників погоди
+type WeatherHistoryRepository interface {
+	// ReadingsSince повертає показники з моменту since (включно) за зростанням часу
+	ReadingsSince(city string, since time.Time) ([]models2.WeatherReading, error)
+	// LastReadingBefore повертає останній показник до t або nil, якщо його немає
+	LastReadingBefore(city string, t time.Time) (*models2.WeatherReading, error)
+	// ReadingsBetween повертає показники в інтервалі [from, to) за зростанням часу,
+	// не більше limit (0 — без обмеження)
+	ReadingsBetween(city string, from, to time.Time, limit int) ([]models2.WeatherReading, error)
+	// DeleteReadingsBefore видаляє показники, старші за t, і повертає їх кількість
+	DeleteReadingsBefore(t time.Time) (int64, error)
+}
+
 // SubscriptionRepository описує операції з моделлю Subscription
 type SubscriptionRepository interface {
-	Create(sub *models2.Subscription) error
+	// Create зберігає підписку і, якщо msg не nil, ставить його в outbox у тій самій транзакції
+	Create(sub *models2.Subscription, msg *models2.OutboxMessage) error
 	FindAllVerified() ([]models2.Subscription, error)
-	FindByToken(token string) (models2.Subscription, error)
-	UpdateSubscription(sub *models2.Subscription) error
+	// FindDueVerified повертає підтверджені активні підписки, час обчислення яких
+	// (next_due_at) настав до now або ще не призначався
+	FindDueVerified(now time.Time) ([]models2.Subscription, error)
+	// FindVerifiedByCity повертає підтверджені активні підписки міста
+	FindVerifiedByCity(city string) ([]models2.Subscription, error)
+	FindByID(id uint) (models2.Subscription, error)
+	// FindByIDs повертає підписки з переданими id; відсутніх id у результаті немає
+	FindByIDs(ids []uint) ([]models2.Subscription, error)
+	// UnsubscribeEmail вимикає всі ще активні підписки email; повертає їх кількість
+	UnsubscribeEmail(email string, at time.Time) (int64, error)
+	// FindByEmailCity повертає підписку за унікальною парою email і місто
+	FindByEmailCity(email, city string) (models2.Subscription, error)
+	// FindByEmail повертає сторінку підписок email (за зростанням id) і їх загальну кількість
+	FindByEmail(email string, offset, limit int) ([]models2.Subscription, int64, error)
+	// UpdateColumns записує в підписку id лише колонки з updates; решту, зокрема стан
+	// сповіщення, який паралельно пише планувальник, не перезаписує
+	UpdateColumns(id uint, updates map[string]interface{}) error
+	// SaveAlertState зберігає стан сповіщення й час наступного обчислення і, якщо msg не nil, ставить його в outbox атомарно,
+	// лише якщо в БД стан досі prevState і fence (якщо не nil) чинний; false — підписку вже обчислив
+	// хтось інший або оренду втрачено
+	SaveAlertState(sub *models2.Subscription, prevState string, msg *models2.OutboxMessage, fence *Fence) (bool, error)
+	// Delete видаляє підписку разом з її токенами, крім токенів керування: ті переходять
+	// до іншої підписки тієї ж адреси. Повертає false, якщо підписки не було
+	Delete(id uint) (bool, error)
+	// SubscribedCities повертає різні міста, на які є хоча б одна підписка
+	SubscribedCities() ([]string, error)
+	// FindToken повертає токен за хешем або nil, якщо його немає
+	FindToken(hash string) (*models2.SubscriptionToken, error)
+	// ReplaceToken замінює токени підписки з тим самим призначенням на tok. Якщо fence
+	// не nil і вже не чинний, нічого не змінює й повертає ErrFenced
+	ReplaceToken(tok *models2.SubscriptionToken, fence *Fence) error
+	// RevokeTokens видаляє токени підписки subID призначення purpose і, якщо msg
+	// не nil, ставить його в outbox атомарно
+	RevokeTokens(subID uint, purpose string, msg *models2.OutboxMessage) error
+	// RedeemToken видаляє використаний токен і, якщо sub не nil, зберігає sub
+	// в одній транзакції; повертає false, якщо токен уже використано
+	RedeemToken(tok *models2.SubscriptionToken, sub *models2.Subscription) (bool, error)
+	// LastQueuedAt повертає час останнього повідомлення kind для підписок email або nil
+	LastQueuedAt(email, kind string) (*time.Time, error)
+	// DeleteUnverified видаляє непідтверджені підписки без токена підтвердження, чинного
+	// на момент t, і без листа підтвердження, що чекає на надсилання; повертає їх кількість
+	DeleteUnverified(t time.Time) (int64, error)
+}
+
+// OutboxRepository описує черву вихідних сповіщень
+type OutboxRepository interface {
+	// DueOutbox повертає до limit повідомлень pending, час спроби яких настав
+	DueOutbox(now time.Time, limit int) ([]models2.OutboxMessage, error)
+	// SaveOutbox зберігає результат спроби доставки; з fence, що вже не чинний, нічого не пише
+	SaveOutbox(msg *models2.OutboxMessage, fence *Fence) error
+	FindOutboxByID(id uint) (models2.OutboxMessage, error)
+	// FindOutbox повертає сторінку повідомлень зі статусом status (усі, якщо порожній)
+	FindOutbox(status string, offset, limit int) ([]models2.OutboxMessage, int64, error)
+	// FindOutboxBySubscription повертає сторінку повідомлень підписки, новіші першими
+	FindOutboxBySubscription(subID uint, offset, limit int) ([]models2.OutboxMessage, int64, error)
+}
+
+// DigestRepository описує налаштування доставки адрес і збирання дайджестів
+type DigestRepository interface {
+	// FindPreference повертає налаштування адреси або nil, якщо їх не задано
+	FindPreference(email string) (*models2.DeliveryPreference, error)
+	// SavePreference створює або оновлює налаштування адреси
+	SavePreference(p *models2.DeliveryPreference) error
+	// DigestPreferences повертає налаштування адрес у режимі дайджесту
+	DigestPreferences() ([]models2.DeliveryPreference, error)
+	// HeldOutbox повертає сповіщення підписок email, що чекають на дайджест, за зростанням id
+	HeldOutbox(email string) ([]models2.OutboxMessage, error)
+	// ReleaseHeld в одній транзакції зберігає p і повертає в чергу (pending, до надсилання
+	// в now) сповіщення підписок адреси, що чекали на дайджест; повертає їх кількість
+	ReleaseHeld(p *models2.DeliveryPreference, now time.Time) (int64, error)
+	// SaveDigest в одній транзакції ставить digest в outbox (якщо не nil), зберігає новий
+	// статус кожного з held (digested — з посиланням на digest), оновлює last_sent
+	// їхніх підписок і час дайджесту в p. Якщо fence не nil і вже не чинний, нічого
+	// не зберігає й повертає ErrFenced
+	SaveDigest(p *models2.DeliveryPreference, digest *models2.OutboxMessage, held []models2.OutboxMessage, now time.Time, fence *Fence) error
+}
+
+// Fence — оренда, під якою процес пише в БД. Умовний запис із Fence виконується
+// в тому самому UPDATE, лише поки оренда Name належить Holder, не спливла і має
+// той самий fencing token, тож процес, що втратив оренду, не перезапише роботу нового.
+type Fence struct {
+	Name   string
+	Holder string
+	Token  uint64
+}
+
+// ErrFenced повертається, коли запис відхилено, бо оренду з Fence втрачено
+var ErrFenced = errors.New("write fenced off: lease lost")
+
+// LeaseRepository описує оренди, що не дають кільком процесам виконувати ту саму роботу
+type LeaseRepository interface {
+	// AcquireLease захоплює оренду name для holder до now+ttl, якщо вона вільна, прострочена
+	// чи вже належить holder (тоді продовжує її). Повертає fencing token і false, якщо
+	// оренду утримує інший процес.
+	AcquireLease(name, holder string, ttl time.Duration, now time.Time) (uint64, bool, error)
+	// FindLease повертає оренду name або nil, якщо її ще ніхто не брав
+	FindLease(name string) (*models2.Lease, error)
+}
+
+// RunRepository описує журнал запусків обчислення підписок
+type RunRepository interface {
+	// SaveRun створює або оновлює запис запуску
+	SaveRun(run *models2.SchedulerRun) error
+	// FindRuns повертає сторінку запусків, новіші першими, і їх загальну кількість
+	FindRuns(offset, limit int) ([]models2.SchedulerRun, int64, error)
 }
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"myapp/pkg/i18n"
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/repository"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
+)
+
+// DigestService зберігає налаштування доставки адрес і збирає відкладені
+// сповіщення адреси в один лист-дайджест
+type DigestService struct {
+	Repo  repository.DigestRepository
+	Subs  repository.SubscriptionRepository
+	Links *signedlink.Signer
+	Tmpl  *templates.Renderer
+}
+
+func NewDigestService(
+	repo repository.DigestRepository,
+	subs repository.SubscriptionRepository,
+	links *signedlink.Signer,
+	tmpl *templates.Renderer,
+) *DigestService {
+	return &DigestService{Repo: repo, Subs: subs, Links: links, Tmpl: tmpl}
+}
+
+// Preference повертає налаштування доставки адреси; якщо їх не задано — immediate
+func (s *DigestService) Preference(email string) (*models.DeliveryPreference, error) {
+	p, err := s.Repo.FindPreference(email)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return &models.DeliveryPreference{Email: email, Delivery: models.DeliveryImmediate}, nil
+	}
+	return p, nil
+}
+
+// SetPreference змінює режим доставки адреси. Адреса має мати хоча б одну підписку.
+// Після повернення до immediate дайджестів для адреси більше немає, тож уже
+// відкладені сповіщення повертаються в чергу і надсилаються як звичайні.
+func (s *DigestService) SetPreference(email, delivery string) (*models.DeliveryPreference, error) {
+	if _, total, err := s.Subs.FindByEmail(email, 0, 1); err != nil {
+		return nil, err
+	} else if total == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+	p, err := s.Preference(email)
+	if err != nil {
+		return nil, err
+	}
+	p.Delivery = delivery
+	if p.DigestPeriod() == 0 {
+		n, err := s.Repo.ReleaseHeld(p, time.Now())
+		if err != nil {
+			log.Printf("SetPreference: failed to save preference for email=%s, err=%v", email, err)
+			return nil, err
+		}
+		if n > 0 {
+			log.Printf("SetPreference: %d held notifications of email=%s released", n, email)
+		}
+	} else if err := s.Repo.SavePreference(p); err != nil {
+		log.Printf("SetPreference: failed to save preference for email=%s, err=%v", email, err)
+		return nil, err
+	}
+	log.Printf("SetPreference: delivery for email=%s set to %s", email, delivery)
+	return p, nil
+}
+
+// holds повідомляє, чи має сповіщення email-каналу для адреси чекати на дайджест
+func (s *DigestService) holds(email string) bool {
+	p, err := s.Preference(email)
+	if err != nil {
+		log.Printf("Digest: preference lookup failed for email=%s, sending immediately, err=%v", email, err)
+		return false
+	}
+	return p.DigestPeriod() > 0
+}
+
+// SendDigests ставить у outbox по дайджесту для кожної адреси, період якої минув.
+// Адреса в тихих годинах (див. quiet) чекає до наступного запуску.
+// Якщо ctx отримано з LeaseService.Do, а оренду втрачено, зупиняється з ErrLeaseLost.
+// Повертає кількість поставлених дайджестів.
+func (s *DigestService) SendDigests(ctx context.Context, now time.Time) (int, error) {
+	prefs, err := s.Repo.DigestPreferences()
+	if err != nil {
+		return 0, err
+	}
+	queued := 0
+	for i := range prefs {
+		p := &prefs[i]
+		if p.LastDigestAt != nil && now.Sub(*p.LastDigestAt) < p.DigestPeriod() {
+			continue
+		}
+		ok, err := s.digest(p, now, fenceOf(ctx))
+		if errors.Is(err, repository.ErrFenced) {
+			return queued, ErrLeaseLost
+		}
+		if err != nil {
+			log.Printf("SendDigests: digest for email=%s failed, err=%v", p.Email, err)
+			continue
+		}
+		if ok {
+			queued++
+		}
+	}
+	if queued > 0 {
+		log.Printf("SendDigests: %d digests queued", queued)
+	}
+	return queued, nil
+}
+
+// quiet повідомляє, чи в адреси тихі години. Дайджест один на всі підписки адреси,
+// тож вони тривають, поки тихі години має хоча б одна її активна підписка, кожна
+// у своєму часовому поясі.
+func (s *DigestService) quiet(email string, now time.Time) (bool, error) {
+	for offset := 0; ; offset += MaxPerPage {
+		subs, total, err := s.Subs.FindByEmail(email, offset, MaxPerPage)
+		if err != nil {
+			return false, err
+		}
+		for i := range subs {
+			if subs[i].UnsubscribedAt != nil {
+				continue
+			}
+			if _, quiet := QuietUntil(&subs[i], now); quiet {
+				return true, nil
+			}
+		}
+		if len(subs) == 0 || int64(offset+len(subs)) >= total {
+			return false, nil
+		}
+	}
+}
+
+// digest збирає відкладені сповіщення адреси в дайджест; false — якщо надсилати нічого
+func (s *DigestService) digest(p *models.DeliveryPreference, now time.Time, fence *repository.Fence) (bool, error) {
+	if quiet, err := s.quiet(p.Email, now); err != nil || quiet {
+		return false, err
+	}
+
+	held, err := s.Repo.HeldOutbox(p.Email)
+	if err != nil {
+		return false, err
+	}
+	// підписки завантажуємо саме за відкладеними сповіщеннями: у адреси їх може
+	// бути більше, ніж вміщує сторінка FindByEmail
+	var ids []uint
+	seen := map[uint]bool{}
+	for _, msg := range held {
+		if !seen[msg.SubscriptionID] {
+			seen[msg.SubscriptionID] = true
+			ids = append(ids, msg.SubscriptionID)
+		}
+	}
+	subs, err := s.Subs.FindByIDs(ids)
+	if err != nil {
+		return false, err
+	}
+	byID := make(map[uint]models.Subscription, len(subs))
+	for _, sub := range subs {
+		byID[sub.ID] = sub
+	}
+
+	lang := i18n.Default
+	var anchor uint
+	data := templates.DigestData{}
+	section := map[string]int{}
+	for i := range held {
+		msg := &held[i]
+		sub, ok := byID[msg.SubscriptionID]
+		if !ok || sub.UnsubscribedAt != nil {
+			msg.Status = models.OutboxDead
+			msg.LastError = errSubscriptionGone.Error()
+			continue
+		}
+		if _, paused := SnoozedUntil(&sub, now); paused {
+			// лишається held до кінця паузи
+			continue
+		}
+		msg.Status = models.OutboxDigested
+		if data.Count == 0 {
+			lang, anchor = sub.Language, sub.ID
+		}
+		data.Count++
+		idx, ok := section[sub.City]
+		if !ok {
+			idx = len(data.Sections)
+			section[sub.City] = idx
+			data.Sections = append(data.Sections, templates.DigestSection{City: sub.City})
+		}
+		data.Sections[idx].Items = append(data.Sections[idx].Items, templates.DigestItem{
+			Subject: msg.Subject,
+			Text:    strings.TrimSpace(msg.Body),
+		})
+	}
+
+	var out *models.OutboxMessage
+	if data.Count > 0 {
+		r, err := s.Tmpl.Render(lang, templates.Digest, data)
+		if err != nil {
+			return false, fmt.Errorf("render digest: %w", err)
+		}
+		// дайджест охоплює кілька підписок, тож відписка в один клік (RFC 8058)
+		// вимикає всі підписки адреси; окремі посилання лишаються в тексті
+		link := s.Links.URL(UnsubscribeAllPath, LinkUnsubscribeAll, anchor, 0)
+		out = &models.OutboxMessage{
+			Recipient: p.Email,
+			Kind:      notifier.KindDigest,
+			Channel:   models.ChannelEmail,
+			Subject:   r.Subject,
+			Body:      r.Text,
+			HTMLBody:  r.HTML,
+			Headers: map[string]string{
+				"List-Unsubscribe":      "<" + link + ">",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+			Status:        models.OutboxPending,
+			NextAttemptAt: now,
+		}
+	}
+	if err := s.Repo.SaveDigest(p, out, held, now, fence); err != nil {
+		return false, err
+	}
+	if out != nil {
+		log.Printf("Digest: queued outbox id=%d for email=%s with %d notifications", out.ID, p.Email, data.Count)
+	}
+	return out != nil, nil
+}
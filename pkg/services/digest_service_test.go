@@ -0,0 +1,309 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/repository"
+	"myapp/pkg/services"
+	"myapp/pkg/utils"
+)
+
+// memDigest — DigestRepository поверх підписок і outbox з mockSubRepo
+type memDigest struct {
+	subs     *mockSubRepo
+	prefs    map[string]*models.DeliveryPreference
+	lastSent map[uint]time.Time
+}
+
+func newMemDigest(subs *mockSubRepo) *memDigest {
+	return &memDigest{subs: subs, prefs: map[string]*models.DeliveryPreference{}, lastSent: map[uint]time.Time{}}
+}
+
+func (m *memDigest) FindPreference(email string) (*models.DeliveryPreference, error) {
+	if p, ok := m.prefs[email]; ok {
+		cp := *p
+		return &cp, nil
+	}
+	return nil, nil
+}
+func (m *memDigest) SavePreference(p *models.DeliveryPreference) error {
+	cp := *p
+	m.prefs[p.Email] = &cp
+	return nil
+}
+func (m *memDigest) DigestPreferences() ([]models.DeliveryPreference, error) {
+	var out []models.DeliveryPreference
+	for _, p := range m.prefs {
+		if p.Delivery != models.DeliveryImmediate {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+func (m *memDigest) HeldOutbox(email string) ([]models.OutboxMessage, error) {
+	var out []models.OutboxMessage
+	for _, msg := range m.subs.queued {
+		for _, sub := range m.subs.verifiedList {
+			if sub.ID == msg.SubscriptionID && sub.Email == email && msg.Status == models.OutboxHeld {
+				out = append(out, *msg)
+			}
+		}
+	}
+	return out, nil
+}
+func (m *memDigest) ReleaseHeld(p *models.DeliveryPreference, now time.Time) (int64, error) {
+	held, _ := m.HeldOutbox(p.Email)
+	for _, h := range held {
+		for _, stored := range m.subs.queued {
+			if stored.ID == h.ID {
+				stored.Status, stored.NextAttemptAt = models.OutboxPending, now
+			}
+		}
+	}
+	return int64(len(held)), m.SavePreference(p)
+}
+func (m *memDigest) SaveDigest(p *models.DeliveryPreference, digest *models.OutboxMessage, held []models.OutboxMessage, now time.Time, _ *repository.Fence) error {
+	if digest != nil {
+		digest.ID = uint(len(m.subs.queued) + 1)
+		m.subs.queued = append(m.subs.queued, digest)
+	}
+	for _, h := range held {
+		for _, stored := range m.subs.queued {
+			if stored.ID != h.ID {
+				continue
+			}
+			stored.Status, stored.LastError = h.Status, h.LastError
+			if h.Status == models.OutboxDigested {
+				stored.DigestID = &digest.ID
+				m.lastSent[h.SubscriptionID] = now
+			}
+		}
+	}
+	p.LastDigestAt = &now
+	return m.SavePreference(p)
+}
+
+// Сповіщення адреси в режимі дайджесту чекають і надсилаються одним листом
+// з розділом на кожне місто; решта надсилається як звичайно
+func TestDigest_CollectsAlertsPerRecipient(t *testing.T) {
+	subs := &mockSubRepo{verifiedList: []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0", Channel: models.ChannelEmail},
+		{ID: 2, Email: "a@b", City: "Lviv", Condition: "temp < 0", Channel: models.ChannelEmail},
+		{ID: 3, Email: "a@b", City: "Odesa", Condition: "temp < 0", Channel: models.ChannelWebhook, WebhookURL: "http://hook.test"},
+		{ID: 4, Email: "c@d", City: "Kyiv", Condition: "temp < 0", Channel: models.ChannelEmail},
+	}}
+	repo := newMemDigest(subs)
+	digests := services.NewDigestService(repo, subs, testLinks, testTmpl)
+	if _, err := digests.SetPreference("a@b", models.DeliveryHourly); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, digests)
+	for i := range subs.verifiedList {
+		sub := subs.verifiedList[i]
+		if _, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -5}); err != nil {
+			t.Fatal(err)
+		}
+		if sub.Email == "a@b" && sub.Channel == models.ChannelEmail && sub.LastSent != nil {
+			t.Errorf("sub %d: LastSent must wait for the digest", sub.ID)
+		}
+	}
+	statuses := []string{}
+	for _, msg := range subs.queued {
+		statuses = append(statuses, msg.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "held,held,pending,pending" {
+		t.Fatalf("unexpected statuses: %s", got)
+	}
+
+	now := time.Now()
+	n, err := digests.SendDigests(context.Background(), now)
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 digest, got %d, %v", n, err)
+	}
+	digest := subs.lastQueued()
+	if digest.Kind != notifier.KindDigest || digest.Recipient != "a@b" || digest.Status != models.OutboxPending {
+		t.Fatalf("unexpected digest: %+v", digest)
+	}
+	if !strings.Contains(digest.Body, "== Kyiv ==") || !strings.Contains(digest.Body, "== Lviv ==") ||
+		strings.Contains(digest.Body, "Odesa") || digest.Subject != "Weather digest (2)" {
+		t.Errorf("unexpected digest body:\n%s", digest.Body)
+	}
+	link := strings.Trim(digest.Headers["List-Unsubscribe"], "<>")
+	u, err := url.Parse(link)
+	if err != nil || u.Path != services.UnsubscribeAllPath || digest.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Fatalf("digest must carry one-click List-Unsubscribe headers, got %v", digest.Headers)
+	}
+	if id, err := testLinks.Verify(services.LinkUnsubscribeAll, u.Query().Get("token")); err != nil || id != 1 {
+		t.Errorf("unsubscribe link must name the first digested subscription, got %d, %v", id, err)
+	}
+	for _, id := range []uint{1, 2} {
+		msg := subs.queued[id-1]
+		if msg.Status != models.OutboxDigested || msg.DigestID == nil || *msg.DigestID != digest.ID {
+			t.Errorf("held message %d not linked to digest: %+v", id, msg)
+		}
+		if !repo.lastSent[id].Equal(now) {
+			t.Errorf("LastSent of subscription %d not updated", id)
+		}
+	}
+
+	// до кінця періоду наступний дайджест не збирається
+	if n, _ := digests.SendDigests(context.Background(), now.Add(30*time.Minute)); n != 0 {
+		t.Errorf("digest sent before the period elapsed")
+	}
+
+	// дайджест іде листом на адресу через звичайний диспетчер
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	var got []string
+	utils.SendMessage = func(e utils.Email) error {
+		got = append(got, e.To+":"+e.Subject)
+		return nil
+	}
+	subs.byID = map[uint]models.Subscription{4: subs.verifiedList[3]}
+	svc, _ := newTestOutbox(subs, 3)
+	if _, err := svc.DispatchDue(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "c@d:Weather Alert for Kyiv,a@b:Weather digest (2)" {
+		t.Errorf("unexpected deliveries: %v", got)
+	}
+}
+
+// Сповіщення відписаної підписки до дайджесту не потрапляє
+func TestDigest_SkipsUnsubscribed(t *testing.T) {
+	gone := time.Now()
+	subs := &mockSubRepo{verifiedList: []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", UnsubscribedAt: &gone},
+	}}
+	subs.queued = []*models.OutboxMessage{{ID: 1, SubscriptionID: 1, Kind: notifier.KindAlert, Status: models.OutboxHeld}}
+	repo := newMemDigest(subs)
+	repo.prefs["a@b"] = &models.DeliveryPreference{Email: "a@b", Delivery: models.DeliveryDaily}
+
+	n, err := services.NewDigestService(repo, subs, testLinks, testTmpl).SendDigests(context.Background(), time.Now())
+	if err != nil || n != 0 {
+		t.Fatalf("want no digest, got %d, %v", n, err)
+	}
+	if subs.queued[0].Status != models.OutboxDead || len(subs.queued) != 1 {
+		t.Errorf("held message must be dead: %+v", subs.queued[0])
+	}
+	if repo.prefs["a@b"].LastDigestAt == nil {
+		t.Error("digest time must advance even without a digest")
+	}
+}
+
+func TestDigest_SetPreferenceRequiresSubscription(t *testing.T) {
+	svc := services.NewDigestService(newMemDigest(&mockSubRepo{}), &mockSubRepo{}, testLinks, testTmpl)
+	if _, err := svc.SetPreference("x@y", models.DeliveryDaily); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Errorf("want ErrSubscriptionNotFound, got %v", err)
+	}
+	p, err := svc.Preference("x@y")
+	if err != nil || p.Delivery != models.DeliveryImmediate {
+		t.Errorf("default delivery must be immediate, got %+v, %v", p, err)
+	}
+}
+
+// Після повернення до immediate відкладені сповіщення не чекають на дайджест,
+// якого вже не буде, а надсилаються диспетчером як звичайні
+func TestDigest_ImmediateReleasesHeld(t *testing.T) {
+	subs := &mockSubRepo{verifiedList: []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0", Channel: models.ChannelEmail},
+	}}
+	subs.byID = map[uint]models.Subscription{1: subs.verifiedList[0]}
+	repo := newMemDigest(subs)
+	digests := services.NewDigestService(repo, subs, testLinks, testTmpl)
+	if _, err := digests.SetPreference("a@b", models.DeliveryDaily); err != nil {
+		t.Fatal(err)
+	}
+	sub := subs.verifiedList[0]
+	if _, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, digests).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -5}); err != nil {
+		t.Fatal(err)
+	}
+	if subs.queued[0].Status != models.OutboxHeld {
+		t.Fatalf("alert must be held, got %s", subs.queued[0].Status)
+	}
+
+	if _, err := digests.SetPreference("a@b", models.DeliveryImmediate); err != nil {
+		t.Fatal(err)
+	}
+	if msg := subs.queued[0]; msg.Status != models.OutboxPending || msg.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("held alert must be released to pending, got %+v", msg)
+	}
+
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	var got []string
+	utils.SendMessage = func(e utils.Email) error {
+		got = append(got, e.To+":"+e.Subject)
+		return nil
+	}
+	svc, _ := newTestOutbox(subs, 3)
+	if _, err := svc.DispatchDue(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "a@b:Weather Alert for Kyiv" {
+		t.Errorf("unexpected deliveries: %v", got)
+	}
+	if n, _ := digests.SendDigests(context.Background(), time.Now().Add(48*time.Hour)); n != 0 {
+		t.Errorf("no digest expected after switching to immediate, got %d", n)
+	}
+}
+
+// Дайджест збирає сповіщення всіх підписок адреси, навіть якщо їх більше,
+// ніж вміщує одна сторінка списку підписок
+func TestDigest_BeyondFirstPage(t *testing.T) {
+	subs := &mockSubRepo{}
+	for i := 1; i <= services.MaxPerPage+1; i++ {
+		subs.verifiedList = append(subs.verifiedList, models.Subscription{ID: uint(i), Email: "a@b", City: fmt.Sprintf("City%d", i)})
+	}
+	last := uint(services.MaxPerPage + 1)
+	subs.queued = []*models.OutboxMessage{{ID: 1, SubscriptionID: last, Kind: notifier.KindAlert, Subject: "Weather Alert", Status: models.OutboxHeld}}
+	repo := newMemDigest(subs)
+	repo.prefs["a@b"] = &models.DeliveryPreference{Email: "a@b", Delivery: models.DeliveryDaily}
+
+	n, err := services.NewDigestService(repo, subs, testLinks, testTmpl).SendDigests(context.Background(), time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 digest, got %d, %v", n, err)
+	}
+	if subs.queued[0].Status != models.OutboxDigested || !strings.Contains(subs.lastQueued().Body, fmt.Sprintf("City%d", last)) {
+		t.Errorf("alert of subscription %d must be digested, got %+v", last, subs.queued[0])
+	}
+}
+
+// Дайджест однієї адреси чекає, поки тихі години триває хоча б в одній її
+// підписці, у власному часовому поясі кожної, а не лише в першій
+func TestDigest_QuietHoursOfAllSubscriptions(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	subs := &mockSubRepo{verifiedList: []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Timezone: "Europe/Kyiv", QuietStart: "22:00", QuietEnd: "07:00"},
+		{ID: 2, Email: "a@b", City: "New York", Timezone: "America/New_York", QuietStart: "22:00", QuietEnd: "07:00"},
+	}}
+	subs.queued = []*models.OutboxMessage{{ID: 1, SubscriptionID: 1, Kind: notifier.KindAlert, Subject: "Weather Alert", Status: models.OutboxHeld}}
+	repo := newMemDigest(subs)
+	repo.prefs["a@b"] = &models.DeliveryPreference{Email: "a@b", Delivery: models.DeliveryDaily}
+	svc := services.NewDigestService(repo, subs, testLinks, testTmpl)
+
+	// 07:00 у Києві, але північ у Нью-Йорку
+	night := time.Date(2026, 1, 15, 5, 0, 0, 0, time.UTC)
+	if n, err := svc.SendDigests(context.Background(), night); err != nil || n != 0 {
+		t.Fatalf("digest must wait for the quiet hours in New York, got %d, %v", n, err)
+	}
+	if subs.queued[0].Status != models.OutboxHeld {
+		t.Fatalf("alert must stay held, got %s", subs.queued[0].Status)
+	}
+
+	// 15:00 у Києві, 08:00 у Нью-Йорку
+	if n, err := svc.SendDigests(context.Background(), night.Add(8*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("want 1 digest after the quiet hours, got %d, %v", n, err)
+	}
+}
@@ -13,3 +13,36 @@ var ErrTokenNotFound = errors.New("token not found")
 
 // ErrTokenExpired повертається, коли токен підтвердження прострочено
 var ErrTokenExpired = errors.New("token expired")
+
+// ErrInvalidRange повертається, коли параметри запиту історії некоректні
+var ErrInvalidRange = errors.New("invalid history range")
+
+// ErrSubscriptionNotFound повертається, коли підписку з таким id не знайдено
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// ErrInvalidChannel повертається, коли канал доставки налаштовано неповністю
+var ErrInvalidChannel = errors.New("invalid notification channel")
+
+// ErrOutboxNotFound повертається, коли повідомлення outbox не знайдено
+var ErrOutboxNotFound = errors.New("outbox message not found")
+
+// ErrInvalidOutboxStatus повертається для невідомого статусу або недопустимого переходу
+var ErrInvalidOutboxStatus = errors.New("invalid outbox status")
+
+// ErrInvalidSchedule повертається для невідомого часового поясу чи некоректних тихих годин
+var ErrInvalidSchedule = errors.New("invalid delivery schedule")
+
+// ErrAlreadyVerified повертається, коли підтверджувати підписку вже не потрібно
+var ErrAlreadyVerified = errors.New("subscription already verified")
+
+// ErrResendTooSoon повертається, коли лист підтвердження на адресу надсилали нещодавно
+var ErrResendTooSoon = errors.New("confirmation resent too soon")
+
+// ErrInvalidSnooze повертається для від'ємної чи надто довгої паузи підписки
+var ErrInvalidSnooze = errors.New("invalid snooze duration")
+
+// ErrLeaseLost повертається, коли оренду, під якою виконується такт, перехопив інший процес
+var ErrLeaseLost = errors.New("lease lost")
+
+// ErrLeaseHeld повертається, коли роботу під орендою не почато, бо її утримує інший процес
+var ErrLeaseHeld = errors.New("lease held by another process")
@@ -1,24 +1,27 @@
 package services_test
 
 import (
+	"context"
 	"errors"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"myapp/pkg/config"
 	"myapp/pkg/models"
 	"myapp/pkg/services"
-	"myapp/pkg/utils"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
 )
 
 func TestEvaluateAndNotify(t *testing.T) {
-	orig := utils.SendEmail
-	defer func() { utils.SendEmail = orig }()
-
 	tests := []struct {
 		name        string
 		condition   string
 		temp        float64
 		weatherCond string
-		emailErr    error
+		saveErr     error
 		wantSent    bool
 		wantErr     bool
 	}{
@@ -31,31 +34,278 @@ func TestEvaluateAndNotify(t *testing.T) {
 		{"RainFalse", "rain", 20, "Clear", nil, false, false},
 		{"InvalidThreshold", "temp < abc", 5, "", nil, false, true},
 		{"UnknownCondition", "snow", 0, "", nil, false, true},
-		{"EmailError", "temp > 0", 10, "Sunny", errors.New("fail"), false, true},
+		{"SaveError", "temp > 0", 10, "Sunny", errors.New("fail"), false, true},
 	}
 
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			called := false
-			utils.SendEmail = func(_, _, _ string) error {
-				called = true
-				return tc.emailErr
-			}
-
+			subs := &mockSubRepo{updateErr: tc.saveErr}
 			sub := models.Subscription{Condition: tc.condition, Email: "a@b", City: "C"}
 			w := models.Weather{Temperature: tc.temp, Condition: tc.weatherCond}
 
-			sent, err := services.EvaluateAndNotify(sub, w)
+			sent, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, w)
 			if (err != nil) != tc.wantErr {
 				t.Fatalf("want err=%v, got %v", tc.wantErr, err)
 			}
 			if sent != tc.wantSent {
 				t.Errorf("want sent=%v, got %v", tc.wantSent, sent)
 			}
-			if tc.wantSent && !called {
-				t.Error("expected SendEmail to be called")
+			if tc.wantSent && len(subs.queued) != 1 {
+				t.Errorf("expected 1 queued message, got %d", len(subs.queued))
+			}
+		})
+	}
+}
+
+func TestEvaluateAndNotify_Humidity(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		humidity  int
+		wantSent  bool
+		wantBody  string
+	}{
+		{"LessThan", "humidity < 30", 20, true, "current humidity 20%"},
+		{"LessEq", "humidity <= 30", 30, true, "current humidity 30%"},
+		{"GreaterThan", "humidity > 90", 95, true, "current humidity 95%"},
+		{"GreaterFalse", "humidity > 90", 90, false, ""},
+		{"GreaterEq", "humidity >= 90", 90, true, "current humidity 90%"},
+		{"Eq", "humidity == 50", 50, true, "current humidity 50%"},
+		{"NotEq", "humidity != 50", 51, true, "current humidity 51%"},
+		{"WithTemp", "temp < 0 AND humidity > 80", 85, true, "current temp -1.0°C, humidity 85%"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			subs := &mockSubRepo{}
+			sub := models.Subscription{Condition: tc.condition, Email: "a@b", City: "C"}
+			w := models.Weather{Temperature: -1, Humidity: tc.humidity, Condition: "Fog"}
+
+			sent, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, w)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sent != tc.wantSent {
+				t.Fatalf("want sent=%v, got %v", tc.wantSent, sent)
+			}
+			if tc.wantSent && !strings.Contains(subs.lastQueued().Body, tc.wantBody) {
+				t.Errorf("body %q does not contain %q", subs.lastQueued().Body, tc.wantBody)
+			}
+		})
+	}
+}
+
+// Сповіщення надсилається лише на переході cleared→fired, «відбій» — на fired→cleared
+func TestEvaluateAndNotify_EdgeTriggered(t *testing.T) {
+	subs := &mockSubRepo{}
+	sub := models.Subscription{Condition: "temp < 0", Email: "a@b", City: "C", Hysteresis: 2, NotifyClear: true}
+	steps := []struct {
+		temp      float64
+		wantSent  bool
+		wantState string
+	}{
+		{3, false, ""},                        // умова не виконується
+		{-1, true, models.AlertStateFired},    // перехід false→true
+		{-5, false, models.AlertStateFired},   // холод триває — без повторів
+		{1, false, models.AlertStateFired},    // у межах гістерезису (поріг 0+2)
+		{-0.5, false, models.AlertStateFired}, // знову нижче нуля — все ще fired
+		{2.5, true, models.AlertStateCleared}, // вийшли за гістерезис — «відбій»
+		{1, false, models.AlertStateCleared},  // без гістерезису в стані cleared
+		{-0.1, true, models.AlertStateFired},  // нове спрацювання
+	}
+
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	for i, st := range steps {
+		sent, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: st.temp})
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if sent != st.wantSent {
+			t.Errorf("step %d (temp %.1f): want sent=%v, got %v", i, st.temp, st.wantSent, sent)
+		}
+		if sub.AlertState != st.wantState {
+			t.Errorf("step %d (temp %.1f): want state %q, got %q", i, st.temp, st.wantState, sub.AlertState)
+		}
+		if sub.LastValue == nil || *sub.LastValue != st.temp {
+			t.Errorf("step %d: want LastValue %.1f, got %v", i, st.temp, sub.LastValue)
+		}
+	}
+
+	var subjects []string
+	for _, m := range subs.queued {
+		subjects = append(subjects, m.Subject)
+	}
+	want := []string{"Weather Alert for C", "All clear for C", "Weather Alert for C"}
+	if strings.Join(subjects, "|") != strings.Join(want, "|") {
+		t.Errorf("want messages %v, got %v", want, subjects)
+	}
+	if subs.saves != len(steps) {
+		t.Errorf("state must be saved on every run, got %d saves", subs.saves)
+	}
+}
+
+func TestEvaluateAndNotify_ClearWithoutNotification(t *testing.T) {
+	subs := &mockSubRepo{}
+	sub := models.Subscription{Condition: "rain", Email: "a@b", City: "C", AlertState: models.AlertStateFired}
+	sent, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Condition: "Clear"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent || len(subs.queued) != 0 {
+		t.Errorf("expected no message, sent=%v queued=%d", sent, len(subs.queued))
+	}
+	if sub.AlertState != models.AlertStateCleared {
+		t.Errorf("want state cleared, got %q", sub.AlertState)
+	}
+}
+
+// Якщо стан не вдалося зберегти, сповіщення не ставиться в чергу окремо від нього
+func TestEvaluateAndNotify_SaveFailureQueuesNothing(t *testing.T) {
+	subs := &mockSubRepo{updateErr: errors.New("db down")}
+	sub := models.Subscription{Condition: "temp < 0", Email: "a@b", City: "C"}
+	if _, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -3}); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(subs.queued) != 0 {
+		t.Errorf("message must not be queued without the state change, got %d", len(subs.queued))
+	}
+}
+
+func TestEvaluateAndNotify_Sustained(t *testing.T) {
+	now := time.Now()
+	ago := func(h float64) time.Time { return now.Add(-time.Duration(h * float64(time.Hour))) }
+
+	tests := []struct {
+		name     string
+		readings []models.WeatherReading
+		wantSent bool
+	}{
+		{"HeldFor3h", []models.WeatherReading{
+			{City: "C", Temperature: -1, RecordedAt: ago(4)},
+			{City: "C", Temperature: -2, RecordedAt: ago(2)},
+		}, true},
+		{"BriefDip", []models.WeatherReading{
+			{City: "C", Temperature: 3, RecordedAt: ago(4)},
+			{City: "C", Temperature: -2, RecordedAt: ago(1)},
+		}, false},
+		{"OtherCityIgnored", []models.WeatherReading{
+			{City: "X", Temperature: -5, RecordedAt: ago(5)},
+			{City: "C", Temperature: -2, RecordedAt: ago(1)},
+		}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := services.NewNotifyService(&fakeHistory{readings: tc.readings}, &mockSubRepo{}, testLinks, testTmpl, nil)
+			sub := models.Subscription{Condition: "temp < 0 FOR 3h", Email: "a@b", City: "C"}
+
+			sent, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{City: "C", Temperature: -3, UpdatedAt: now})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sent != tc.wantSent {
+				t.Errorf("want sent=%v, got %v", tc.wantSent, sent)
 			}
 		})
 	}
 }
+
+func TestEvaluateAndNotify_Delta(t *testing.T) {
+	subs := &mockSubRepo{}
+
+	now := time.Now()
+	hist := &fakeHistory{readings: []models.WeatherReading{
+		{City: "C", Temperature: 8, Humidity: 40, RecordedAt: now.Add(-7 * time.Hour)},
+		{City: "C", Temperature: 1, Humidity: 70, RecordedAt: now.Add(-30 * time.Minute)},
+	}}
+	ns := services.NewNotifyService(hist, subs, testLinks, testTmpl, nil)
+	sub := models.Subscription{Condition: "delta(temp, 6h) <= -10", Email: "a@b", City: "C"}
+
+	sent, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{City: "C", Temperature: -3, Humidity: 75, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !sent {
+		t.Fatal("expected alert for an 11° drop")
+	}
+	for _, want := range []string{"change -11.0", "8.0°C at", "-3.0°C now"} {
+		if !strings.Contains(subs.lastQueued().Body, want) {
+			t.Errorf("body %q does not contain %q", subs.lastQueued().Body, want)
+		}
+	}
+
+	sub = models.Subscription{Condition: "delta(humidity, 1h) > 20", Email: "a@b", City: "C"}
+	sent, err = ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{City: "C", Temperature: -3, Humidity: 95, UpdatedAt: now})
+	if err != nil || !sent {
+		t.Fatalf("expected humidity delta alert, sent=%v err=%v", sent, err)
+	}
+	if !strings.Contains(subs.lastQueued().Body, "change +55.0") {
+		t.Errorf("unexpected body: %q", subs.lastQueued().Body)
+	}
+}
+
+var testLinks = func() *signedlink.Signer {
+	s, err := signedlink.NewSigner(config.Config{AppSecret: "test", BaseURL: "http://alerts.test"})
+	if err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+var testTmpl = func() *templates.Renderer {
+	r, err := templates.New(config.Config{})
+	if err != nil {
+		panic(err)
+	}
+	return r
+}()
+
+func TestEvaluateAndNotify_UnsubscribeLink(t *testing.T) {
+	subs := &mockSubRepo{}
+	sub := models.Subscription{ID: 17, Condition: "temp < 0", Email: "a@b", City: "C"}
+	if _, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := subs.lastQueued()
+
+	lu := got.Headers["List-Unsubscribe"]
+	if !strings.HasPrefix(lu, "<http://alerts.test/subscriptions/unsubscribe?token=") || !strings.HasSuffix(lu, ">") {
+		t.Errorf("unexpected List-Unsubscribe: %q", lu)
+	}
+	if got.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected List-Unsubscribe-Post: %q", got.Headers["List-Unsubscribe-Post"])
+	}
+	link := strings.Trim(lu, "<>")
+	if !strings.Contains(got.Body, "Unsubscribe: "+link) {
+		t.Errorf("body does not contain unsubscribe link: %q", got.Body)
+	}
+	if !strings.Contains(got.HTMLBody, `href="`+link+`"`) {
+		t.Errorf("html body does not link to unsubscribe: %q", got.HTMLBody)
+	}
+
+	u, _ := url.Parse(link)
+	id, err := testLinks.Verify(services.LinkUnsubscribe, u.Query().Get("token"))
+	if err != nil || id != 17 {
+		t.Errorf("link token must verify to id 17, got %d, %v", id, err)
+	}
+}
+
+// Сповіщення рендериться мовою підписки, включно з описом показників
+func TestEvaluateAndNotify_Language(t *testing.T) {
+	subs := &mockSubRepo{}
+	sub := models.Subscription{ID: 3, Condition: "temp < 0", Email: "a@b", City: "Kyiv", Language: "uk"}
+	if _, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := subs.lastQueued()
+	if got.Subject != "Погодне сповіщення для Kyiv" {
+		t.Errorf("unexpected subject: %q", got.Subject)
+	}
+	if !strings.Contains(got.Body, "Умову temp < 0 виконано: зараз температура -2.0°C") {
+		t.Errorf("unexpected body: %q", got.Body)
+	}
+	if !strings.Contains(got.HTMLBody, `lang="uk"`) {
+		t.Errorf("html must be rendered in uk: %q", got.HTMLBody)
+	}
+}
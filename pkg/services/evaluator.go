@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"myapp/pkg/events"
+	"myapp/pkg/models"
+	"myapp/pkg/repository"
+	"sync"
+	"time"
+)
+
+// cityQueueSize — скільки різних міст може чекати на обчислення одночасно
+const cityQueueSize = 256
+
+// dueSlack — наскільки раніше призначеного часу підписку можна обчислити. Запуск
+// починається трохи пізніше за такт, тож без запасу підписка з інтервалом, кратним
+// періоду такту, щоразу чекала б ще один такт.
+const dueSlack = time.Minute
+
+// CityEvaluator обчислює підписки міста одразу після зміни його погоди,
+// не чекаючи на cron. Події про місто, яке вже стоїть у черзі, зливаються
+// в одну; повторне сповіщення відсікає умова в SaveAlertState.
+type CityEvaluator struct {
+	Weather *WeatherService
+	Subs    repository.SubscriptionRepository
+	Notify  *NotifyService
+
+	mu      sync.Mutex
+	pending map[string]bool
+	queue   chan string
+}
+
+func NewCityEvaluator(weather *WeatherService, subs repository.SubscriptionRepository, notify *NotifyService) *CityEvaluator {
+	return &CityEvaluator{
+		Weather: weather,
+		Subs:    subs,
+		Notify:  notify,
+		pending: map[string]bool{},
+		queue:   make(chan string, cityQueueSize),
+	}
+}
+
+// Enqueue — обробник events.WeatherChanged: ставить місто в чергу і не блокує
+// того, хто публікує. Якщо черга повна, місто обчислить наступний запуск cron.
+func (e *CityEvaluator) Enqueue(ev events.WeatherChanged) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending[ev.City] {
+		return
+	}
+	select {
+	case e.queue <- ev.City:
+		e.pending[ev.City] = true
+	default:
+		log.Printf("CityEvaluator: queue is full, city=%q left to the cron run", ev.City)
+	}
+}
+
+// Run обчислює міста з черги, доки не скасовано ctx. Кожне місто обчислюється під
+// орендою lease, як і такт cron, тож записи стану умовні на неї; поки оренду тримає
+// інший процес, місто лишається його cron.
+func (e *CityEvaluator) Run(ctx context.Context, lease *LeaseService) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case city := <-e.queue:
+			// знімаємо позначку до обчислення: нова зміна погоди під час
+			// обчислення має поставити місто в чергу ще раз
+			e.mu.Lock()
+			delete(e.pending, city)
+			e.mu.Unlock()
+			var err error
+			ran, lerr := lease.Do(ctx, func(ctx context.Context) {
+				_, err = e.EvaluateCity(ctx, city)
+			})
+			switch {
+			case lerr != nil:
+				log.Printf("CityEvaluator: city=%q: acquire lease: %v", city, lerr)
+			case !ran:
+				log.Printf("CityEvaluator: lease is held by another instance, city=%q left to its cron run", city)
+			case err != nil:
+				log.Printf("CityEvaluator: city=%q: %v", city, err)
+			}
+		}
+	}
+}
+
+// maxRunErrors — скільки помилок одного обчислення зберігається в EvalStats
+const maxRunErrors = 20
+
+// EvalStats — підсумок обчислення підписок (значення полів — як у models.SchedulerRun)
+type EvalStats struct {
+	Evaluated int
+	Matched   int
+	Sent      int
+	Failed    int
+	Errors    []string
+}
+
+func (st *EvalStats) addError(err error) {
+	if len(st.Errors) < maxRunErrors {
+		st.Errors = append(st.Errors, err.Error())
+	}
+}
+
+func (st *EvalStats) add(o EvalStats) {
+	st.Evaluated += o.Evaluated
+	st.Matched += o.Matched
+	st.Sent += o.Sent
+	st.Failed += o.Failed
+	for _, e := range o.Errors {
+		if len(st.Errors) == maxRunErrors {
+			break
+		}
+		st.Errors = append(st.Errors, e)
+	}
+}
+
+// EvaluateCity обчислює підтверджені підписки міста за його поточною погодою
+func (e *CityEvaluator) EvaluateCity(ctx context.Context, city string) (EvalStats, error) {
+	subs, err := e.Subs.FindVerifiedByCity(city)
+	if err != nil {
+		return EvalStats{}, fmt.Errorf("find subscriptions: %w", err)
+	}
+	return e.evaluate(ctx, city, subs, false), ctx.Err()
+}
+
+// EvaluateDue обчислює підтверджені підписки, час обчислення яких настав (next_due_at,
+// див. IntervalMinutes) — так працює такт cron. Решта — як у EvaluateAll.
+func (e *CityEvaluator) EvaluateDue(ctx context.Context, workers int, dryRun bool) (EvalStats, error) {
+	subs, err := e.Subs.FindDueVerified(time.Now().Add(dueSlack))
+	if err != nil {
+		return EvalStats{}, fmt.Errorf("find subscriptions: %w", err)
+	}
+	return e.evaluateSubs(ctx, workers, subs, dryRun)
+}
+
+// EvaluateAll обчислює всі підтверджені підписки незалежно від next_due_at (запуск
+// на вимогу): погода кожного міста читається
+// один раз, а міста розподіляються між workers паралельними обробниками (щонайменше
+// одним). Підписки одного міста обчислює один обробник, тож вони не змагаються
+// між собою. У режимі dryRun нічого не зберігається і не ставиться в outbox.
+// Після скасування ctx (зокрема за дедлайном запуску) нові міста й підписки
+// не беруться; тоді повертається ctx.Err() разом із підсумком уже зробленого.
+func (e *CityEvaluator) EvaluateAll(ctx context.Context, workers int, dryRun bool) (EvalStats, error) {
+	subs, err := e.Subs.FindAllVerified()
+	if err != nil {
+		return EvalStats{}, fmt.Errorf("find subscriptions: %w", err)
+	}
+	return e.evaluateSubs(ctx, workers, subs, dryRun)
+}
+
+// evaluateSubs розподіляє subs за містами між workers обробниками
+func (e *CityEvaluator) evaluateSubs(ctx context.Context, workers int, subs []models.Subscription, dryRun bool) (EvalStats, error) {
+	var cities []string
+	byCity := map[string][]models.Subscription{}
+	for _, sub := range subs {
+		if _, ok := byCity[sub.City]; !ok {
+			cities = append(cities, sub.City)
+		}
+		byCity[sub.City] = append(byCity[sub.City], sub)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		mu    sync.Mutex
+		total EvalStats
+		wg    sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for city := range jobs {
+				st := e.evaluate(ctx, city, byCity[city], dryRun)
+				mu.Lock()
+				total.add(st)
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, city := range cities {
+		select {
+		case jobs <- city:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	log.Printf("CityEvaluator: evaluated %d of %d subscriptions in %d cities, matched=%d sent=%d failed=%d dry_run=%v",
+		total.Evaluated, len(subs), len(cities), total.Matched, total.Sent, total.Failed, dryRun)
+	return total, ctx.Err()
+}
+
+// evaluate обчислює підписки subs міста city за його поточною погодою;
+// помилки окремих підписок не зупиняють решту і потрапляють у підсумок
+func (e *CityEvaluator) evaluate(ctx context.Context, city string, subs []models.Subscription, dryRun bool) EvalStats {
+	var st EvalStats
+	w, err := e.Weather.GetCurrentWeather(city)
+	if err != nil {
+		st.Failed = len(subs)
+		st.addError(fmt.Errorf("city %q: get weather: %w", city, err))
+		log.Printf("CityEvaluator: city=%q: get weather: %v", city, err)
+		return st
+	}
+	for i := range subs {
+		if ctx.Err() != nil {
+			return st
+		}
+		sub := &subs[i]
+		var sent bool
+		if dryRun {
+			sent, err = e.Notify.Preview(sub, w)
+		} else {
+			sent, err = e.Notify.EvaluateAndNotify(ctx, sub, w)
+		}
+		st.Evaluated++
+		if err != nil {
+			st.Failed++
+			st.addError(fmt.Errorf("subscription id=%d: %w", sub.ID, err))
+			log.Printf("CityEvaluator: subscription id=%d: %v", sub.ID, err)
+			continue
+		}
+		if sub.AlertState == models.AlertStateFired {
+			st.Matched++
+		}
+		if sent {
+			st.Sent++
+			if !dryRun {
+				log.Printf("alert queued for subscription id=%d state=%s", sub.ID, sub.AlertState)
+			}
+		}
+	}
+	return st
+}
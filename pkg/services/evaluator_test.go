@@ -0,0 +1,211 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"myapp/pkg/events"
+	"myapp/pkg/models"
+	"myapp/pkg/repository"
+	"myapp/pkg/services"
+)
+
+func newCityEvaluator(subs *mockSubRepo, w models.Weather) *services.CityEvaluator {
+	ws := services.NewWeatherService(&spyRepo{returnWeather: w}, nil)
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	return services.NewCityEvaluator(ws, subs, ns)
+}
+
+func TestCityEvaluator_EvaluatesOnlyThatCity(t *testing.T) {
+	kyiv := models.Subscription{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"}
+	lviv := models.Subscription{ID: 2, Email: "a@b", City: "Lviv", Condition: "temp < 0"}
+	subs := &mockSubRepo{verifiedList: []models.Subscription{kyiv, lviv}}
+
+	st, err := newCityEvaluator(subs, models.Weather{City: "Kyiv", Temperature: -3}).EvaluateCity(context.Background(), "Kyiv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Sent != 1 || len(subs.queued) != 1 || subs.lastQueued().SubscriptionID != 1 {
+		t.Fatalf("want one alert for subscription 1, got %+v queued=%+v", st, subs.queued)
+	}
+	if subs.saves != 1 {
+		t.Errorf("only Kyiv must be evaluated, got %d saves", subs.saves)
+	}
+}
+
+// Cron і обробник події прочитали підписку до того, як будь-хто з них зберіг
+// стан: сповіщення має піти лише один раз.
+func TestCityEvaluator_NoDoubleSendWithCron(t *testing.T) {
+	sub := models.Subscription{ID: 7, Email: "a@b", City: "Kyiv", Condition: "temp < 0",
+		AlertState: models.AlertStateCleared}
+	subs := &mockSubRepo{
+		verifiedList: []models.Subscription{sub},
+		byID:         map[uint]models.Subscription{sub.ID: sub},
+	}
+	w := models.Weather{City: "Kyiv", Temperature: -3}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+
+	cronCopy := sub
+	if _, err := newCityEvaluator(subs, w).EvaluateCity(context.Background(), "Kyiv"); err != nil {
+		t.Fatal(err)
+	}
+	sent, err := ns.EvaluateAndNotify(context.Background(), &cronCopy, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent {
+		t.Error("stale evaluation must not queue an alert")
+	}
+	if len(subs.queued) != 1 {
+		t.Errorf("want exactly 1 queued alert, got %d", len(subs.queued))
+	}
+}
+
+func TestCityEvaluator_RunConsumesEvents(t *testing.T) {
+	sub := models.Subscription{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"}
+	subs := &mockSubRepo{verifiedList: []models.Subscription{sub}}
+	ev := newCityEvaluator(subs, models.Weather{City: "Kyiv", Temperature: -3})
+
+	bus := events.NewBus()
+	bus.Subscribe(ev.Enqueue)
+	bus.Publish(events.WeatherChanged{City: "Kyiv"})
+	bus.Publish(events.WeatherChanged{City: "Kyiv"}) // зливається з першою
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ev.Run(ctx, &services.LeaseService{})
+
+	if subs.saves != 1 {
+		t.Errorf("duplicate events must coalesce into one evaluation, got %d", subs.saves)
+	}
+}
+
+// Обчислення за подією пише стан лише під орендою планувальника, як і такт cron
+func TestCityEvaluator_RunUnderLease(t *testing.T) {
+	sub := models.Subscription{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"}
+	leases := &memLease{leases: map[string]*models.Lease{}}
+	a, b := newLease(leases, "a"), newLease(leases, "b")
+	if ran, err := b.Do(context.Background(), func(context.Context) {}); !ran || err != nil {
+		t.Fatalf("b must take the lease, got %v, %v", ran, err)
+	}
+
+	run := func() *mockSubRepo {
+		subs := &mockSubRepo{verifiedList: []models.Subscription{sub}}
+		ev := newCityEvaluator(subs, models.Weather{City: "Kyiv", Temperature: -3})
+		ev.Enqueue(events.WeatherChanged{City: "Kyiv"})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		ev.Run(ctx, a)
+		return subs
+	}
+
+	if subs := run(); subs.saves != 0 {
+		t.Errorf("city must be left to the lease holder, got %d saves", subs.saves)
+	}
+
+	leases.leases[services.SchedulerLease].ExpiresAt = time.Now()
+	subs := run()
+	if subs.saves != 1 || subs.lastFence == nil || subs.lastFence.Holder != "a" {
+		t.Errorf("want one fenced save by a, got %d saves, fence %+v", subs.saves, subs.lastFence)
+	}
+}
+
+// lockedSubRepo робить mockSubRepo безпечним для паралельних обробників
+type lockedSubRepo struct {
+	*mockSubRepo
+	mu sync.Mutex
+}
+
+func (m *lockedSubRepo) SaveAlertState(sub *models.Subscription, prevState string, msg *models.OutboxMessage, fence *repository.Fence) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mockSubRepo.SaveAlertState(sub, prevState, msg, fence)
+}
+
+// countingWeatherRepo рахує читання погоди по містах
+type countingWeatherRepo struct {
+	mu    sync.Mutex
+	reads map[string]int
+	temp  float64
+}
+
+func (r *countingWeatherRepo) GetByCity(city string) (models.Weather, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reads[city]++
+	return models.Weather{City: city, Temperature: r.temp}, nil
+}
+func (r *countingWeatherRepo) Save(*models.Weather) error { return nil }
+func (r *countingWeatherRepo) UpdateWeather(string, map[string]interface{}) error {
+	return nil
+}
+
+func TestCityEvaluator_EvaluateAll(t *testing.T) {
+	var list []models.Subscription
+	for i := 1; i <= 30; i++ {
+		city := []string{"Kyiv", "Lviv", "Odesa"}[i%3]
+		list = append(list, models.Subscription{ID: uint(i), Email: "a@b", City: city, Condition: "temp < 0"})
+	}
+	subs := &lockedSubRepo{mockSubRepo: &mockSubRepo{verifiedList: list}}
+	weather := &countingWeatherRepo{reads: map[string]int{}, temp: -5}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	ev := services.NewCityEvaluator(services.NewWeatherService(weather, nil), subs, ns)
+
+	st, err := ev.EvaluateAll(context.Background(), 4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Evaluated != 30 || st.Matched != 30 || st.Sent != 30 || len(subs.queued) != 30 {
+		t.Errorf("want 30 alerts, got %+v queued=%d", st, len(subs.queued))
+	}
+	for city, reads := range weather.reads {
+		if reads != 1 {
+			t.Errorf("weather for %s must be read once per run, got %d", city, reads)
+		}
+	}
+}
+
+func TestCityEvaluator_EvaluateAllCancelled(t *testing.T) {
+	list := []models.Subscription{{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"}}
+	subs := &lockedSubRepo{mockSubRepo: &mockSubRepo{verifiedList: list}}
+	weather := &countingWeatherRepo{reads: map[string]int{}, temp: -5}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	ev := services.NewCityEvaluator(services.NewWeatherService(weather, nil), subs, ns)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ev.EvaluateAll(ctx, 2, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if subs.saves != 0 {
+		t.Errorf("cancelled run must not evaluate subscriptions, got %d saves", subs.saves)
+	}
+}
+
+func TestCityEvaluator_DryRun(t *testing.T) {
+	list := []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"},
+		{ID: 2, Email: "a@b", City: "Kyiv", Condition: "temp < 0", AlertState: models.AlertStateFired},
+		{ID: 3, Email: "a@b", City: "Kyiv", Condition: "temp > 0"},
+		{ID: 4, Email: "a@b", City: "Kyiv", Condition: "snow"},
+	}
+	subs := &lockedSubRepo{mockSubRepo: &mockSubRepo{verifiedList: list}}
+	weather := &countingWeatherRepo{reads: map[string]int{}, temp: -5}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	ev := services.NewCityEvaluator(services.NewWeatherService(weather, nil), subs, ns)
+
+	st, err := ev.EvaluateAll(context.Background(), 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 — новий перехід, 2 — уже сповіщена, 3 — умова не виконується, 4 — некоректна умова
+	if st.Evaluated != 4 || st.Matched != 2 || st.Sent != 1 || st.Failed != 1 || len(st.Errors) != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if subs.saves != 0 || len(subs.queued) != 0 {
+		t.Errorf("dry run must not save or queue, got %d saves, %d queued", subs.saves, len(subs.queued))
+	}
+}
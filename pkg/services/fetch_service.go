@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"log"
+	"myapp/pkg/provider"
+	"myapp/pkg/repository"
+)
+
+// FetchService оновлює погоду міст із підписками із зовнішнього джерела
+type FetchService struct {
+	Provider provider.WeatherProvider
+	Weather  *WeatherService
+	SubRepo  repository.SubscriptionRepository
+}
+
+func NewFetchService(
+	p provider.WeatherProvider,
+	weather *WeatherService,
+	subRepo repository.SubscriptionRepository,
+) *FetchService {
+	return &FetchService{Provider: p, Weather: weather, SubRepo: subRepo}
+}
+
+// RefreshSubscribedCities запитує погоду для кожного міста з підписками і зберігає її
+// (разом із записом в історію). Помилка одного міста не зупиняє інші.
+// Повертає кількість оновлених міст.
+func (s *FetchService) RefreshSubscribedCities(ctx context.Context) (int, error) {
+	cities, err := s.SubRepo.SubscribedCities()
+	if err != nil {
+		log.Printf("Fetch: subscribed cities error: %v", err)
+		return 0, err
+	}
+
+	updated := 0
+	for _, city := range cities {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+		w, err := s.Provider.Current(ctx, city)
+		if err != nil {
+			log.Printf("Fetch: provider error for city=%q: %v", city, err)
+			continue
+		}
+		// зберігаємо під назвою з підписки, а не тією, що повернуло джерело
+		w.City = city
+		if err := s.Weather.SaveWeather(&w); err != nil {
+			log.Printf("Fetch: save error for city=%q: %v", city, err)
+			continue
+		}
+		updated++
+	}
+	log.Printf("Fetch: refreshed %d of %d cities", updated, len(cities))
+	return updated, nil
+}
@@ -0,0 +1,103 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/provider"
+	"myapp/pkg/provider/providertest"
+	"myapp/pkg/services"
+	"myapp/pkg/utils"
+)
+
+// memWeatherRepo зберігає погоду в пам'яті за назвою міста
+type memWeatherRepo struct {
+	byCity map[string]models.Weather
+}
+
+func (m *memWeatherRepo) GetByCity(city string) (models.Weather, error) {
+	w, ok := m.byCity[city]
+	if !ok {
+		return models.Weather{}, errors.New("not found")
+	}
+	return w, nil
+}
+func (m *memWeatherRepo) Save(w *models.Weather) error {
+	m.byCity[w.City] = *w
+	return nil
+}
+func (m *memWeatherRepo) UpdateWeather(city string, updates map[string]interface{}) error {
+	return nil
+}
+
+// Повний офлайн-потік: заглушка API → fetcher → сховище → обчислення умови → лист
+func TestFetchService_RefreshAndNotify(t *testing.T) {
+	srv := providertest.NewServer()
+	defer srv.Close()
+	srv.Set("Kyiv", providertest.Reading{Temperature: -7, Humidity: 85, Condition: "Snow"})
+	srv.Set("Lviv", providertest.Reading{Temperature: 3, Humidity: 60, Condition: "Clouds"})
+
+	repo := &memWeatherRepo{byCity: map[string]models.Weather{}}
+	subRepo := &mockSubRepo{cities: []string{"Kyiv", "Lviv", "Atlantis"}}
+	fs := services.NewFetchService(
+		provider.NewOpenWeatherMap(config.Config{WeatherAPIURL: srv.URL}),
+		services.NewWeatherService(repo, nil),
+		subRepo,
+	)
+
+	n, err := fs.RefreshSubscribedCities(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("want 2 cities refreshed, got %d", n)
+	}
+	if srv.Requests() != 3 {
+		t.Errorf("want 3 provider requests, got %d", srv.Requests())
+	}
+	if _, err := repo.GetByCity("Atlantis"); err == nil {
+		t.Error("unknown city must not be saved")
+	}
+
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	var sentTo []string
+	utils.SendMessage = func(e utils.Email) error {
+		sentTo = append(sentTo, e.To)
+		return nil
+	}
+
+	subs := []models.Subscription{
+		{ID: 1, Email: "kyiv@x", City: "Kyiv", Condition: "temp < 0 AND condition = Snow"},
+		{ID: 2, Email: "lviv@x", City: "Lviv", Condition: "temp < 0"},
+	}
+	subRepo.byID = map[uint]models.Subscription{1: subs[0], 2: subs[1]}
+	ns := services.NewNotifyService(nil, subRepo, testLinks, testTmpl, nil)
+	for _, sub := range subs {
+		w, err := repo.GetByCity(sub.City)
+		if err != nil {
+			t.Fatalf("weather for %s not saved: %v", sub.City, err)
+		}
+		if _, err := ns.EvaluateAndNotify(context.Background(), &sub, w); err != nil {
+			t.Fatalf("evaluate %s: %v", sub.City, err)
+		}
+	}
+	outbox, _ := newTestOutbox(subRepo, 3)
+	if _, err := outbox.DispatchDue(context.Background(), time.Now()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if len(sentTo) != 1 || sentTo[0] != "kyiv@x" {
+		t.Errorf("want one alert to kyiv@x, got %v", sentTo)
+	}
+}
+
+func TestFetchService_CitiesError(t *testing.T) {
+	fs := services.NewFetchService(nil, nil, &mockSubRepo{listErr: errors.New("db down")})
+	if _, err := fs.RefreshSubscribedCities(context.Background()); err == nil {
+		t.Error("expected error")
+	}
+}
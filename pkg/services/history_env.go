@@ -0,0 +1,50 @@
+package services
+
+import (
+	"myapp/pkg/condition"
+	models2 "myapp/pkg/models"
+	"myapp/pkg/repository"
+	"time"
+)
+
+// historyEnv — середовище обчислення умови з доступом до історії міста
+type historyEnv struct {
+	repo    repository.WeatherHistoryRepository
+	city    string
+	now     time.Time
+	current condition.Snapshot
+}
+
+func (e *historyEnv) Now() time.Time              { return e.now }
+func (e *historyEnv) Current() condition.Snapshot { return e.current }
+
+func (e *historyEnv) Window(from time.Time) ([]condition.Snapshot, error) {
+	if e.repo == nil {
+		return nil, condition.ErrNoHistory
+	}
+	var out []condition.Snapshot
+	prev, err := e.repo.LastReadingBefore(e.city, from)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		out = append(out, readingSnapshot(*prev))
+	}
+	rs, err := e.repo.ReadingsSince(e.city, from)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rs {
+		out = append(out, readingSnapshot(r))
+	}
+	return out, nil
+}
+
+func readingSnapshot(r models2.WeatherReading) condition.Snapshot {
+	return condition.Snapshot{
+		Temperature: r.Temperature,
+		Humidity:    float64(r.Humidity),
+		Condition:   r.Condition,
+		At:          r.RecordedAt,
+	}
+}
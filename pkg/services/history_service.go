@@ -0,0 +1,150 @@
+package services
+
+import (
+	"log"
+	"myapp/pkg/config"
+	"myapp/pkg/i18n"
+	"myapp/pkg/models"
+	"myapp/pkg/repository"
+	"time"
+)
+
+// maxHistoryPoints обмежує кількість інтервалів чи сирих показників в одній відповіді
+const maxHistoryPoints = 5000
+
+type HistoryService struct {
+	History     repository.WeatherHistoryRepository
+	WeatherRepo repository.WeatherRepository
+	Retention   time.Duration
+}
+
+func NewHistoryService(
+	history repository.WeatherHistoryRepository,
+	weatherRepo repository.WeatherRepository,
+	cfg config.Config,
+) *HistoryService {
+	return &HistoryService{
+		History:     history,
+		WeatherRepo: weatherRepo,
+		Retention:   cfg.HistoryRetention,
+	}
+}
+
+// HistoryQuery — параметри запиту історії. Step == 0 означає сирі показники.
+type HistoryQuery struct {
+	City     string
+	From, To time.Time
+	Step     time.Duration
+}
+
+// Aggregate — мінімум, максимум і середнє значення в інтервалі
+type Aggregate struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+// HistoryPoint — агреговані показники за інтервал [Start, Start+Step)
+type HistoryPoint struct {
+	Start       time.Time `json:"start"`
+	Count       int       `json:"count"`
+	Temperature Aggregate `json:"temperature"`
+	Humidity    Aggregate `json:"humidity"`
+}
+
+type HistoryResult struct {
+	City     string                  `json:"city"`
+	From     time.Time               `json:"from"`
+	To       time.Time               `json:"to"`
+	Step     string                  `json:"step,omitempty"`
+	Points   []HistoryPoint          `json:"points,omitempty"`
+	Readings []models.WeatherReading `json:"readings,omitempty"`
+}
+
+// Query повертає історію міста за інтервал, за потреби згруповану по Step
+func (s *HistoryService) Query(q HistoryQuery) (HistoryResult, error) {
+	if !q.From.Before(q.To) {
+		return HistoryResult{}, i18n.Wrap(ErrInvalidRange, i18n.MsgRangeOrder)
+	}
+	if q.Step < 0 || (q.Step > 0 && q.To.Sub(q.From)/q.Step > maxHistoryPoints) {
+		return HistoryResult{}, i18n.Wrap(ErrInvalidRange, i18n.MsgRangeTooManyPoints, maxHistoryPoints)
+	}
+	if _, err := s.WeatherRepo.GetByCity(q.City); err != nil {
+		return HistoryResult{}, ErrCityNotFound
+	}
+
+	// для групування потрібні всі показники; сирі ж читаємо з одним зайвим,
+	// щоб помітити, що ліміт перевищено
+	limit := 0
+	if q.Step == 0 {
+		limit = maxHistoryPoints + 1
+	}
+	rs, err := s.History.ReadingsBetween(q.City, q.From, q.To, limit)
+	if err != nil {
+		log.Printf("History.Query error for city=%q: %v", q.City, err)
+		return HistoryResult{}, err
+	}
+
+	res := HistoryResult{City: q.City, From: q.From, To: q.To}
+	if q.Step == 0 {
+		if len(rs) > maxHistoryPoints {
+			return HistoryResult{}, i18n.Wrap(ErrInvalidRange, i18n.MsgRangeTooManyPoints, maxHistoryPoints)
+		}
+		res.Readings = rs
+		return res, nil
+	}
+	res.Step = q.Step.String()
+	res.Points = downsample(rs, q.From, q.Step)
+	return res, nil
+}
+
+// downsample групує показники (відсортовані за часом) в інтервали довжиною step від from.
+// Порожні інтервали пропускаються.
+func downsample(rs []models.WeatherReading, from time.Time, step time.Duration) []HistoryPoint {
+	var out []HistoryPoint
+	var cur *HistoryPoint
+	var tSum, hSum float64
+	flush := func() {
+		if cur != nil {
+			cur.Temperature.Avg = tSum / float64(cur.Count)
+			cur.Humidity.Avg = hSum / float64(cur.Count)
+			out = append(out, *cur)
+		}
+	}
+	for _, r := range rs {
+		start := from.Add(r.RecordedAt.Sub(from) / step * step)
+		t, h := r.Temperature, float64(r.Humidity)
+		if cur == nil || !cur.Start.Equal(start) {
+			flush()
+			cur = &HistoryPoint{
+				Start:       start,
+				Temperature: Aggregate{Min: t, Max: t},
+				Humidity:    Aggregate{Min: h, Max: h},
+			}
+			tSum, hSum = 0, 0
+		}
+		cur.Count++
+		tSum += t
+		hSum += h
+		cur.Temperature.Min = min(cur.Temperature.Min, t)
+		cur.Temperature.Max = max(cur.Temperature.Max, t)
+		cur.Humidity.Min = min(cur.Humidity.Min, h)
+		cur.Humidity.Max = max(cur.Humidity.Max, h)
+	}
+	flush()
+	return out
+}
+
+// Prune видаляє показники, старші за період зберігання
+func (s *HistoryService) Prune(now time.Time) (int64, error) {
+	if s.Retention <= 0 {
+		return 0, nil
+	}
+	n, err := s.History.DeleteReadingsBefore(now.Add(-s.Retention))
+	if err != nil {
+		log.Printf("History.Prune error: %v", err)
+		return 0, err
+	}
+	log.Printf("History.Prune removed %d readings older than %s", n, s.Retention)
+	return n, nil
+}
@@ -0,0 +1,181 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/services"
+)
+
+// fakeHistory повертає показники з пам'яті за тими ж правилами, що й GormRepo
+type fakeHistory struct {
+	readings     []models.WeatherReading
+	deleteBefore time.Time
+}
+
+func (f *fakeHistory) ReadingsSince(city string, since time.Time) ([]models.WeatherReading, error) {
+	var out []models.WeatherReading
+	for _, r := range f.readings {
+		if r.City == city && !r.RecordedAt.Before(since) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeHistory) LastReadingBefore(city string, t time.Time) (*models.WeatherReading, error) {
+	var last *models.WeatherReading
+	for i, r := range f.readings {
+		if r.City == city && r.RecordedAt.Before(t) {
+			last = &f.readings[i]
+		}
+	}
+	return last, nil
+}
+
+func (f *fakeHistory) ReadingsBetween(city string, from, to time.Time, limit int) ([]models.WeatherReading, error) {
+	var out []models.WeatherReading
+	for _, r := range f.readings {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if r.City == city && !r.RecordedAt.Before(from) && r.RecordedAt.Before(to) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeHistory) DeleteReadingsBefore(t time.Time) (int64, error) {
+	f.deleteBefore = t
+	var kept []models.WeatherReading
+	for _, r := range f.readings {
+		if !r.RecordedAt.Before(t) {
+			kept = append(kept, r)
+		}
+	}
+	n := int64(len(f.readings) - len(kept))
+	f.readings = kept
+	return n, nil
+}
+
+func TestHistoryService_QueryDownsample(t *testing.T) {
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(min int, temp float64, hum int) models.WeatherReading {
+		return models.WeatherReading{City: "C", Temperature: temp, Humidity: hum, RecordedAt: from.Add(time.Duration(min) * time.Minute)}
+	}
+	hist := &fakeHistory{readings: []models.WeatherReading{
+		at(0, 1, 50), at(20, 3, 60), at(40, 5, 70), // 00:00
+		at(130, -2, 90), // 02:00, 01:00 порожня
+		{City: "X", Temperature: 99, RecordedAt: from},
+	}}
+	svc := services.NewHistoryService(hist, &mockWeatherRepo{exists: true}, config.Config{})
+
+	res, err := svc.Query(services.HistoryQuery{City: "C", From: from, To: from.Add(3 * time.Hour), Step: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Points) != 2 {
+		t.Fatalf("want 2 points, got %d: %+v", len(res.Points), res.Points)
+	}
+	p := res.Points[0]
+	if !p.Start.Equal(from) || p.Count != 3 {
+		t.Errorf("unexpected first point: %+v", p)
+	}
+	if p.Temperature != (services.Aggregate{Min: 1, Max: 5, Avg: 3}) {
+		t.Errorf("unexpected temperature aggregate: %+v", p.Temperature)
+	}
+	if p.Humidity != (services.Aggregate{Min: 50, Max: 70, Avg: 60}) {
+		t.Errorf("unexpected humidity aggregate: %+v", p.Humidity)
+	}
+	if !res.Points[1].Start.Equal(from.Add(2*time.Hour)) || res.Points[1].Count != 1 {
+		t.Errorf("unexpected second point: %+v", res.Points[1])
+	}
+}
+
+func TestHistoryService_QueryRaw(t *testing.T) {
+	now := time.Now()
+	hist := &fakeHistory{readings: []models.WeatherReading{
+		{City: "C", Temperature: 1, RecordedAt: now.Add(-2 * time.Hour)},
+		{City: "C", Temperature: 2, RecordedAt: now.Add(-time.Hour)},
+	}}
+	svc := services.NewHistoryService(hist, &mockWeatherRepo{exists: true}, config.Config{})
+
+	res, err := svc.Query(services.HistoryQuery{City: "C", From: now.Add(-90 * time.Minute), To: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Readings) != 1 || res.Readings[0].Temperature != 2 || res.Points != nil {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+// Сирі показники обмежені так само, як і інтервали
+func TestHistoryService_QueryRawTooMany(t *testing.T) {
+	now := time.Now()
+	hist := &fakeHistory{}
+	for i := 5001; i > 0; i-- {
+		hist.readings = append(hist.readings, models.WeatherReading{City: "C", RecordedAt: now.Add(-time.Duration(i) * time.Second)})
+	}
+	svc := services.NewHistoryService(hist, &mockWeatherRepo{exists: true}, config.Config{})
+
+	if _, err := svc.Query(services.HistoryQuery{City: "C", From: now.Add(-2 * time.Hour), To: now}); !errors.Is(err, services.ErrInvalidRange) {
+		t.Fatalf("want ErrInvalidRange, got %v", err)
+	}
+	hist.readings = hist.readings[1:]
+	res, err := svc.Query(services.HistoryQuery{City: "C", From: now.Add(-2 * time.Hour), To: now})
+	if err != nil || len(res.Readings) != 5000 {
+		t.Errorf("want 5000 readings, got %d, %v", len(res.Readings), err)
+	}
+}
+
+func TestHistoryService_QueryErrors(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name    string
+		exists  bool
+		q       services.HistoryQuery
+		wantErr error
+	}{
+		{"FromAfterTo", true, services.HistoryQuery{City: "C", From: now, To: now.Add(-time.Hour)}, services.ErrInvalidRange},
+		{"TooManyPoints", true, services.HistoryQuery{City: "C", From: now.Add(-24 * time.Hour), To: now, Step: time.Second}, services.ErrInvalidRange},
+		{"CityNotFound", false, services.HistoryQuery{City: "C", From: now.Add(-time.Hour), To: now}, services.ErrCityNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := services.NewHistoryService(&fakeHistory{}, &mockWeatherRepo{exists: tc.exists, err: errors.New("nf")}, config.Config{})
+			if _, err := svc.Query(tc.q); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHistoryService_Prune(t *testing.T) {
+	now := time.Now()
+	hist := &fakeHistory{readings: []models.WeatherReading{
+		{City: "C", RecordedAt: now.Add(-48 * time.Hour)},
+		{City: "C", RecordedAt: now.Add(-time.Hour)},
+	}}
+	svc := services.NewHistoryService(hist, nil, config.Config{HistoryRetention: 24 * time.Hour})
+
+	n, err := svc.Prune(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 || len(hist.readings) != 1 {
+		t.Errorf("want 1 pruned and 1 kept, got pruned=%d kept=%d", n, len(hist.readings))
+	}
+	if !hist.deleteBefore.Equal(now.Add(-24 * time.Hour)) {
+		t.Errorf("unexpected cutoff: %v", hist.deleteBefore)
+	}
+
+	disabled := services.NewHistoryService(hist, nil, config.Config{})
+	if n, _ := disabled.Prune(now); n != 0 {
+		t.Errorf("retention 0 must not prune, got %d", n)
+	}
+}
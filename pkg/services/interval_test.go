@@ -0,0 +1,154 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/services"
+)
+
+func TestEvaluateAndNotify_SchedulesNextCheck(t *testing.T) {
+	subs := &mockSubRepo{}
+	sub := models.Subscription{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0", IntervalMinutes: 15}
+
+	before := time.Now()
+	if _, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if sub.NextDueAt == nil || sub.NextDueAt.Before(before.Add(15*time.Minute)) || sub.NextDueAt.After(time.Now().Add(15*time.Minute)) {
+		t.Errorf("want next check in 15 minutes, got %v", sub.NextDueAt)
+	}
+}
+
+func TestEvaluateAndNotify_Cooldown(t *testing.T) {
+	cases := []struct {
+		name     string
+		lastSent time.Duration // скільки тому спрацювало останнє сповіщення
+		cooldown int
+		wantSent bool
+	}{
+		{"NoCooldown", 5 * time.Minute, 0, true},
+		{"InCooldown", 5 * time.Minute, 60, false},
+		{"CooldownPassed", 2 * time.Hour, 60, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			subs := &mockSubRepo{}
+			last := time.Now().Add(-tc.lastSent)
+			sub := models.Subscription{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0",
+				AlertState: models.AlertStateCleared, LastAlertAt: &last, CooldownMinutes: tc.cooldown}
+
+			sent, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent != tc.wantSent {
+				t.Errorf("want sent=%v, got %v", tc.wantSent, sent)
+			}
+			// у cooldown перехід відкладається, щоб сповіщення пішло, щойно він мине
+			wantState := models.AlertStateCleared
+			if tc.wantSent {
+				wantState = models.AlertStateFired
+			}
+			if sub.AlertState != wantState {
+				t.Errorf("want state %s, got %s", wantState, sub.AlertState)
+			}
+		})
+	}
+}
+
+// Відбій не продовжує cooldown: він рахується від останнього спрацювання
+func TestEvaluateAndNotify_CooldownAfterClear(t *testing.T) {
+	subs := &mockSubRepo{}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	alertAt := time.Now().Add(-90 * time.Minute)
+	sub := models.Subscription{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0", NotifyClear: true,
+		AlertState: models.AlertStateFired, LastSent: &alertAt, LastAlertAt: &alertAt, CooldownMinutes: 60}
+
+	if sent, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: 5}); err != nil || !sent {
+		t.Fatalf("want clear notice sent, got %v, %v", sent, err)
+	}
+	if !sub.LastAlertAt.Equal(alertAt) {
+		t.Errorf("clear notice must not move the last alert time, got %v", sub.LastAlertAt)
+	}
+	sent, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -5})
+	if err != nil || !sent {
+		t.Errorf("alert 90m after the last one must pass a 60m cooldown, got %v, %v", sent, err)
+	}
+}
+
+// Сповіщення, відкладене для дайджесту, теж починає cooldown, хоча LastSent
+// оновиться лише зі збиранням дайджесту
+func TestEvaluateAndNotify_CooldownDigestHeld(t *testing.T) {
+	subs := &mockSubRepo{verifiedList: []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0", Channel: models.ChannelEmail, CooldownMinutes: 60},
+	}}
+	subs.byID = map[uint]models.Subscription{1: subs.verifiedList[0]}
+	digests := services.NewDigestService(newMemDigest(subs), subs, testLinks, testTmpl)
+	if _, err := digests.SetPreference("a@b", models.DeliveryDaily); err != nil {
+		t.Fatal(err)
+	}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, digests)
+	sub := subs.verifiedList[0]
+
+	for i, temp := range []float64{-5, 5, -5} {
+		if _, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: temp}); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	if len(subs.queued) != 1 || subs.queued[0].Status != models.OutboxHeld {
+		t.Fatalf("want one held alert, got %d messages", len(subs.queued))
+	}
+	if sub.LastSent != nil || sub.LastAlertAt == nil {
+		t.Errorf("held alert must set only the last alert time, got last_sent=%v last_alert_at=%v", sub.LastSent, sub.LastAlertAt)
+	}
+	if sub.AlertState != models.AlertStateCleared {
+		t.Errorf("second alert within the cooldown must be postponed, got state %s", sub.AlertState)
+	}
+}
+
+func TestCityEvaluator_EvaluatesOnlyDue(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+	list := []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"},
+		{ID: 2, Email: "a@b", City: "Kyiv", Condition: "temp < 0", NextDueAt: &past},
+		{ID: 3, Email: "a@b", City: "Kyiv", Condition: "temp < 0", NextDueAt: &later},
+	}
+	subs := &mockSubRepo{verifiedList: list}
+
+	st, err := newCityEvaluator(subs, models.Weather{City: "Kyiv", Temperature: -3}).EvaluateDue(context.Background(), 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Evaluated != 2 || subs.saves != 2 {
+		t.Errorf("want only the 2 due subscriptions evaluated, got %+v saves=%d", st, subs.saves)
+	}
+}
+
+func TestSubscriptionService_Interval(t *testing.T) {
+	mSub := &mockSubRepo{}
+	svc := services.NewSubscriptionService(mSub, &mockWeatherRepo{exists: true}, testLinks, testTmpl, config.Config{})
+	sub := &models.Subscription{Email: "e@e", City: "C", Condition: "temp < 0"}
+	if err := svc.Create(sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.IntervalMinutes != services.DefaultIntervalMinutes {
+		t.Errorf("want default interval %d, got %d", services.DefaultIntervalMinutes, sub.IntervalMinutes)
+	}
+
+	due := time.Now().Add(time.Hour)
+	mSub.byID = map[uint]models.Subscription{1: {ID: 1, Email: "e@e", City: "C", Condition: "temp < 0",
+		IntervalMinutes: 60, NextDueAt: &due}}
+	every := 15
+	got, err := svc.Update("e@e", 1, services.SubscriptionPatch{IntervalMinutes: &every})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IntervalMinutes != 15 || got.NextDueAt != nil {
+		t.Errorf("new interval must apply on the next tick, got interval=%d next=%v", got.IntervalMinutes, got.NextDueAt)
+	}
+}
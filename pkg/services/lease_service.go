@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"myapp/pkg/config"
+	"myapp/pkg/repository"
+	"os"
+	"time"
+)
+
+// SchedulerLease — назва оренди, під якою виконуються такти планувальника
+const SchedulerLease = "scheduler"
+
+// LeaseService утримує оренду в БД від імені цього процесу, щоб із кількох
+// реплік роботу виконувала одна
+type LeaseService struct {
+	Repo   repository.LeaseRepository
+	Name   string
+	Holder string
+	// TTL — строк оренди; 0 вимикає її, і Do виконує роботу завжди
+	TTL time.Duration
+}
+
+func NewLeaseService(repo repository.LeaseRepository, cfg config.Config) *LeaseService {
+	return &LeaseService{Repo: repo, Name: SchedulerLease, Holder: newHolderID(), TTL: cfg.LeaseTTL}
+}
+
+// newHolderID повертає ідентифікатор процесу, унікальний і між рестартами на тому самому хості
+func newHolderID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%x", host, os.Getpid(), b)
+}
+
+type fenceKey struct{}
+
+// fence — оренда і fencing token, під якими виконується робота
+type fence struct {
+	lease *LeaseService
+	token uint64
+}
+
+// Do виконує fn, лише якщо процес захопив або продовжив оренду; інакше повертає false.
+// Поки fn працює, оренда продовжується кожну третину TTL, а якщо її втрачено,
+// ctx у fn скасовується. Записи fn передають у репозиторій fenceOf(ctx), тож
+// після втрати оренди вони не виконуються; CheckFence дозволяє зупинитися раніше.
+func (l *LeaseService) Do(ctx context.Context, fn func(ctx context.Context)) (bool, error) {
+	if l.TTL <= 0 {
+		fn(ctx)
+		return true, nil
+	}
+	token, ok, err := l.Repo.AcquireLease(l.Name, l.Holder, l.TTL, time.Now())
+	if err != nil || !ok {
+		return false, err
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, fenceKey{}, fence{lease: l, token: token}))
+	defer cancel()
+	go l.keepAlive(ctx, cancel, token)
+	fn(ctx)
+	return true, nil
+}
+
+// keepAlive продовжує оренду, доки не скасовано ctx, і скасовує його, якщо оренду втрачено
+func (l *LeaseService) keepAlive(ctx context.Context, cancel context.CancelFunc, token uint64) {
+	t := time.NewTicker(l.TTL / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			got, ok, err := l.Repo.AcquireLease(l.Name, l.Holder, l.TTL, time.Now())
+			if err != nil {
+				// ще спробуємо; якщо оренда встигне спливти, її зловить CheckFence
+				log.Printf("Lease: renew %q failed: %v", l.Name, err)
+				continue
+			}
+			if !ok || got != token {
+				log.Printf("Lease: %q lost by %s", l.Name, l.Holder)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// fenceOf повертає оренду, під якою виконується ctx, для умовних записів у репозиторії;
+// nil — ctx отримано не з Do, і записи виконуються без умови
+func fenceOf(ctx context.Context) *repository.Fence {
+	f, ok := ctx.Value(fenceKey{}).(fence)
+	if !ok {
+		return nil
+	}
+	return &repository.Fence{Name: f.lease.Name, Holder: f.lease.Holder, Token: f.token}
+}
+
+// CheckFence повертає ErrLeaseLost, якщо ctx отримано з Do, а оренда відтоді
+// прострочена чи перейшла до іншого процесу (змінився fencing token).
+// Для ctx не з Do завжди повертає nil. Це лише рання перевірка перед зовнішньою
+// дією (напр. надсиланням): між нею і записом оренду можна втратити, тож самі
+// записи захищає умова fenceOf(ctx) в UPDATE.
+func CheckFence(ctx context.Context) error {
+	f, ok := ctx.Value(fenceKey{}).(fence)
+	if !ok {
+		return nil
+	}
+	lease, err := f.lease.Repo.FindLease(f.lease.Name)
+	if err != nil {
+		return fmt.Errorf("check lease: %w", err)
+	}
+	if lease == nil || lease.Holder != f.lease.Holder || lease.Token != f.token || !time.Now().Before(lease.ExpiresAt) {
+		return ErrLeaseLost
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"myapp/pkg/models"
+	"myapp/pkg/services"
+)
+
+// memLease — LeaseRepository з тією ж семантикою, що й умовний UPDATE у GormRepo
+type memLease struct {
+	mu     sync.Mutex
+	leases map[string]*models.Lease
+}
+
+func (m *memLease) AcquireLease(name, holder string, ttl time.Duration, now time.Time) (uint64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.leases[name]
+	if !ok {
+		l = &models.Lease{Name: name, ExpiresAt: now}
+		m.leases[name] = l
+	}
+	if l.Holder != holder && now.Before(l.ExpiresAt) {
+		return 0, false, nil
+	}
+	if l.Holder != holder {
+		l.Token++
+	}
+	l.Holder, l.ExpiresAt = holder, now.Add(ttl)
+	return l.Token, true, nil
+}
+
+func (m *memLease) FindLease(name string) (*models.Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.leases[name]; ok {
+		cp := *l
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func newLease(repo *memLease, holder string) *services.LeaseService {
+	return &services.LeaseService{Repo: repo, Name: services.SchedulerLease, Holder: holder, TTL: time.Minute}
+}
+
+func TestLease_OnlyHolderRuns(t *testing.T) {
+	repo := &memLease{leases: map[string]*models.Lease{}}
+	a, b := newLease(repo, "a"), newLease(repo, "b")
+
+	runs := map[string]int{}
+	for i := 0; i < 3; i++ {
+		for _, l := range []*services.LeaseService{a, b} {
+			ran, err := l.Do(context.Background(), func(ctx context.Context) {
+				if err := services.CheckFence(ctx); err != nil {
+					t.Errorf("holder must pass the fence, got %v", err)
+				}
+				runs[l.Holder]++
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ran != (l == a) {
+				t.Errorf("holder %s: want ran=%v, got %v", l.Holder, l == a, ran)
+			}
+		}
+	}
+	if runs["a"] != 3 || runs["b"] != 0 {
+		t.Errorf("want only a to run, got %v", runs)
+	}
+}
+
+func TestLease_FenceAfterTakeover(t *testing.T) {
+	repo := &memLease{leases: map[string]*models.Lease{}}
+	a, b := newLease(repo, "a"), newLease(repo, "b")
+
+	var fenceErr error
+	a.Do(context.Background(), func(ctx context.Context) {
+		// оренда a спливла (напр. довга пауза GC), і її захопив b
+		repo.mu.Lock()
+		repo.leases[services.SchedulerLease].ExpiresAt = time.Now().Add(-time.Second)
+		repo.mu.Unlock()
+		if ran, _ := b.Do(context.Background(), func(context.Context) {}); !ran {
+			t.Fatal("b must take over an expired lease")
+		}
+		fenceErr = services.CheckFence(ctx)
+	})
+	if !errors.Is(fenceErr, services.ErrLeaseLost) {
+		t.Errorf("want ErrLeaseLost for the old holder, got %v", fenceErr)
+	}
+	if l, _ := repo.FindLease(services.SchedulerLease); l.Token != 2 {
+		t.Errorf("fencing token must grow with every new holder, got %d", l.Token)
+	}
+}
+
+func TestLease_Disabled(t *testing.T) {
+	l := &services.LeaseService{Repo: &memLease{}, Name: services.SchedulerLease}
+	ran, err := l.Do(context.Background(), func(ctx context.Context) {
+		if err := services.CheckFence(ctx); err != nil {
+			t.Errorf("no fence without a lease, got %v", err)
+		}
+	})
+	if !ran || err != nil {
+		t.Errorf("TTL 0 must run the job, got ran=%v err=%v", ran, err)
+	}
+}
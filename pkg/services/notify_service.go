@@ -1,59 +1,267 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"myapp/pkg/condition"
+	"myapp/pkg/i18n"
 	models2 "myapp/pkg/models"
-	"myapp/pkg/utils"
-	"regexp"
-	"strconv"
+	"myapp/pkg/notifier"
+	"myapp/pkg/repository"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
 	"strings"
+	"time"
 )
 
-var tempCondRe = regexp.MustCompile(
-	`^temp\s*(<=|>=|<|>|==|=|!=)\s*([0-9]+(?:\.[0-9]+)?)$`,
+// Підписані посилання в листах
+const (
+	LinkUnsubscribe = "unsubscribe"
+	UnsubscribePath = "/subscriptions/unsubscribe"
+	LinkSnooze      = "snooze"
+	SnoozePath      = "/subscriptions/snooze"
+	// LinkUnsubscribeAll — відписка всієї адреси з дайджесту; id — будь-яка її підписка
+	LinkUnsubscribeAll = "unsubscribe-all"
+	UnsubscribeAllPath = "/subscriptions/unsubscribe-all"
 )
 
-func EvaluateAndNotify(sub models2.Subscription, weather models2.Weather) (bool, error) {
-	cond := strings.TrimSpace(sub.Condition)
-	var shouldSend bool
-
-	if m := tempCondRe.FindStringSubmatch(cond); len(m) == 3 {
-		op, thr := m[1], m[2]
-		threshold, err := strconv.ParseFloat(thr, 64)
-		if err != nil {
-			return false, fmt.Errorf("invalid threshold %q: %v", thr, err)
-		}
-		switch op {
-		case "<":
-			shouldSend = weather.Temperature < threshold
-		case "<=":
-			shouldSend = weather.Temperature <= threshold
-		case ">":
-			shouldSend = weather.Temperature > threshold
-		case ">=":
-			shouldSend = weather.Temperature >= threshold
-		case "=", "==":
-			shouldSend = weather.Temperature == threshold
-		case "!=":
-			shouldSend = weather.Temperature != threshold
-		default:
-			// Теоретично сюди не зайде — regexp вже обмежує список
-			return false, fmt.Errorf("unsupported operator %q", op)
-		}
-	} else if strings.EqualFold(cond, "rain") {
-		shouldSend = strings.EqualFold(weather.Condition, "Rain")
-	} else {
-		return false, fmt.Errorf("unknown condition %q", cond)
-	}
-
-	if !shouldSend {
+// snoozeLinkTTL — скільки діє посилання на паузу з листа
+const snoozeLinkTTL = 7 * 24 * time.Hour
+
+// NotifyService обчислює умови підписок і ставить сповіщення в outbox
+type NotifyService struct {
+	History repository.WeatherHistoryRepository
+	Subs    repository.SubscriptionRepository
+	Links   *signedlink.Signer
+	Tmpl    *templates.Renderer
+	// Digests — налаштування дайджестів адрес; nil — усе надсилається одразу
+	Digests *DigestService
+}
+
+func NewNotifyService(
+	history repository.WeatherHistoryRepository,
+	subs repository.SubscriptionRepository,
+	links *signedlink.Signer,
+	tmpl *templates.Renderer,
+	digests *DigestService,
+) *NotifyService {
+	return &NotifyService{History: history, Subs: subs, Links: links, Tmpl: tmpl, Digests: digests}
+}
+
+// EvaluateAndNotify обчислює умову підписки і зберігає новий стан разом
+// зі сповіщенням (якщо воно є) в одній транзакції; надсилає його диспетчер
+// outbox, у тихі години підписки — після їх кінця, а для адрес у режимі
+// дайджесту сповіщення email-каналу чекає на найближчий дайджест.
+// Призупинену підписку не обчислюємо взагалі, доки не мине PausedUntil; її
+// наступне обчислення переноситься на кінець паузи.
+// Наступне обчислення планувальником призначається через IntervalMinutes.
+// Якщо стан у БД змінився після читання sub (його вже обчислив cron чи
+// обробник події), результат відкидається і нічого не ставиться в чергу.
+// Якщо ctx отримано з LeaseService.Do, стан зберігається лише під чинною
+// орендою, інакше повертається ErrLeaseLost.
+// Повертає true, якщо сповіщення поставлено в чергу.
+func (s *NotifyService) EvaluateAndNotify(ctx context.Context, sub *models2.Subscription, weather models2.Weather) (bool, error) {
+	if until, ok := SnoozedUntil(sub, time.Now()); ok {
+		// інакше планувальник вибирав би підписку на кожному такті всієї паузи
+		if sub.NextDueAt == nil || sub.NextDueAt.Before(until) {
+			sub.NextDueAt = &until
+			if _, err := s.Subs.SaveAlertState(sub, sub.AlertState, nil, fenceOf(ctx)); err != nil {
+				return false, fmt.Errorf("save next due: %w", err)
+			}
+		}
+		log.Printf("EvaluateAndNotify: subscription id=%d is snoozed until %s, skipped",
+			sub.ID, until.Format(time.RFC3339))
 		return false, nil
 	}
-
-	subject := fmt.Sprintf("Weather Alert for %s", sub.City)
-	body := fmt.Sprintf("Condition %s met: current temp %.1f°C", cond, weather.Temperature)
-	if err := utils.SendEmail(sub.Email, subject, body); err != nil {
+	lastSent, prevState := sub.LastSent, sub.AlertState
+	msg, err := s.Evaluate(sub, weather)
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	if sub.IntervalMinutes > 0 {
+		next := sub.LastEvaluatedAt.Add(sub.Interval())
+		sub.NextDueAt = &next
+	}
+	var out *models2.OutboxMessage
+	if msg != nil {
+		now := time.Now()
+		out = newOutboxMessage(sub, *msg, &weather, now)
+		switch until, quiet := QuietUntil(sub, now); {
+		case out.Channel == models2.ChannelEmail && s.Digests != nil && s.Digests.holds(sub.Email):
+			// адреса отримує дайджест — сповіщення чекає на нього,
+			// а LastSent оновиться, коли дайджест буде зібрано; cooldown
+			// уже рахується від LastAlertAt
+			out.Status = models2.OutboxHeld
+			sub.LastSent = lastSent
+		case quiet:
+			// у тихі години сповіщення чекає в outbox до їх кінця
+			out.NextAttemptAt = until
+			log.Printf("EvaluateAndNotify: subscription id=%d is in quiet hours, %s deferred until %s",
+				sub.ID, msg.Kind, until.Format(time.RFC3339))
+		}
+	}
+	saved, err := s.Subs.SaveAlertState(sub, prevState, out, fenceOf(ctx))
+	if err != nil {
+		return false, fmt.Errorf("save alert state: %w", err)
+	}
+	if !saved {
+		if err := CheckFence(ctx); err != nil {
+			return false, err
+		}
+		log.Printf("EvaluateAndNotify: subscription id=%d changed state concurrently, result dropped", sub.ID)
+		return false, nil
+	}
+	return msg != nil, nil
+}
+
+// Preview обчислює підписку, як EvaluateAndNotify, але нічого не зберігає і не ставить
+// у чергу (пробний запуск); поля стану оновлюються лише в sub. Повертає true,
+// якщо сповіщення було б поставлено в чергу.
+func (s *NotifyService) Preview(sub *models2.Subscription, weather models2.Weather) (bool, error) {
+	if _, ok := SnoozedUntil(sub, time.Now()); ok {
+		return false, nil
+	}
+	msg, err := s.Evaluate(sub, weather)
+	return msg != nil, err
+}
+
+// Evaluate обчислює умову підписки й оновлює поля стану в sub на місці.
+// Повідомлення повертається лише на переході стану: cleared→fired (сповіщення)
+// та, якщо увімкнено NotifyClear, fired→cleared («відбій»); інакше nil.
+// Поки від останнього спрацювання (LastAlertAt) не минуло CooldownMinutes,
+// перехід у fired відкладається.
+func (s *NotifyService) Evaluate(sub *models2.Subscription, weather models2.Weather) (*notifier.Message, error) {
+	cond := strings.TrimSpace(sub.Condition)
+
+	expr, err := condition.Parse(cond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", cond, err)
+	}
+
+	fired := sub.AlertState == models2.AlertStateFired
+	margin := 0.0
+	if fired {
+		margin = sub.Hysteresis
+	}
+	now := time.Now()
+	snap := snapshotOf(weather)
+	env := &historyEnv{repo: s.History, city: sub.City, now: now, current: snap}
+	holds, err := condition.EvalWithMargin(expr, env, margin)
+	if err != nil {
+		return nil, fmt.Errorf("evaluate condition %q: %w", cond, err)
+	}
+
+	sub.LastEvaluatedAt = &now
+	sub.LastValue = primaryValue(expr, snap)
+
+	switch {
+	case holds && !fired:
+		if until, ok := cooldownUntil(sub, now); ok {
+			// стан лишається cleared: якщо умова ще виконуватиметься, сповіщення
+			// піде на першому обчисленні після кінця cooldown
+			log.Printf("Evaluate: subscription id=%d is in cooldown until %s, alert postponed",
+				sub.ID, until.Format(time.RFC3339))
+			return nil, nil
+		}
+		sub.AlertState = models2.AlertStateFired
+		sub.StateChangedAt = &now
+		sub.LastSent = &now
+		sub.LastAlertAt = &now
+		return s.message(sub, notifier.KindAlert, cond, describeReadings(sub.Language, expr, env, weather))
+
+	case !holds && fired:
+		sub.AlertState = models2.AlertStateCleared
+		sub.StateChangedAt = &now
+		if !sub.NotifyClear {
+			return nil, nil
+		}
+		sub.LastSent = &now
+		return s.message(sub, notifier.KindClear, cond, describeReadings(sub.Language, expr, env, weather))
+	}
+	return nil, nil
+}
+
+// message рендерить шаблон kind із посиланнями для відписки й паузи; для листів додає
+// заголовки List-Unsubscribe / List-Unsubscribe-Post (RFC 8058)
+func (s *NotifyService) message(sub *models2.Subscription, kind, cond, current string) (*notifier.Message, error) {
+	link := s.Links.URL(UnsubscribePath, LinkUnsubscribe, sub.ID, 0)
+	r, err := s.Tmpl.Render(sub.Language, kind, templates.AlertData{
+		City:           sub.City,
+		Condition:      cond,
+		Readings:       current,
+		UnsubscribeURL: link,
+		SnoozeURL:      s.Links.URL(SnoozePath, LinkSnooze, sub.ID, snoozeLinkTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render %s message: %w", kind, err)
+	}
+	return &notifier.Message{
+		Kind:    kind,
+		Subject: r.Subject,
+		Body:    r.Text,
+		HTML:    r.HTML,
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + link + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
+
+// snapshotOf перетворює модель погоди на значення для обчислення умови
+func snapshotOf(w models2.Weather) condition.Snapshot {
+	return condition.Snapshot{
+		Temperature: w.Temperature,
+		Humidity:    float64(w.Humidity),
+		Condition:   w.Condition,
+		At:          w.UpdatedAt,
+	}
+}
+
+// primaryValue повертає значення першого числового поля умови (для LastValue)
+func primaryValue(expr condition.Expr, s condition.Snapshot) *float64 {
+	for _, f := range condition.Fields(expr) {
+		if v, ok := s.Value(f); ok {
+			return &v
+		}
+	}
+	return nil
+}
+
+// describeReadings додає до поточних показників значення «до» і «після» для кожного delta()
+func describeReadings(lang string, expr condition.Expr, env condition.Env, w models2.Weather) string {
+	out := readings(lang, expr, w)
+	deltas, err := condition.Deltas(expr, env)
+	if err != nil {
+		return out
+	}
+	for _, d := range deltas {
+		before, _ := d.Before.Value(d.Expr.Field)
+		after, _ := d.After.Value(d.Expr.Field)
+		unit := "°C"
+		if d.Expr.Field == condition.FieldHumidity {
+			unit = "%"
+		}
+		out += "; " + i18n.T(lang, i18n.MsgReadingDelta,
+			d.Expr, d.Change(),
+			before, unit, d.Before.At.Format("2006-01-02 15:04"),
+			after, unit)
+	}
+	return out
+}
+
+// readings описує значення погоди, на які посилається умова, напр. "temp -3.0°C, humidity 95%"
+func readings(lang string, expr condition.Expr, w models2.Weather) string {
+	var parts []string
+	for _, f := range condition.Fields(expr) {
+		switch f {
+		case condition.FieldTemp:
+			parts = append(parts, i18n.T(lang, i18n.MsgReadingTemp, w.Temperature))
+		case condition.FieldHumidity:
+			parts = append(parts, i18n.T(lang, i18n.MsgReadingHumidity, w.Humidity))
+		case condition.FieldCondition:
+			parts = append(parts, i18n.T(lang, i18n.MsgReadingCondition, w.Condition))
+		}
+	}
+	return strings.Join(parts, ", ")
 }
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/i18n"
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/repository"
+)
+
+// Межі диспетчера outbox
+const (
+	outboxBatch      = 100
+	outboxMaxBackoff = time.Hour
+)
+
+// OutboxService надсилає сповіщення з outbox: невдалі спроби повторюються
+// з експоненційною затримкою, після MaxAttempts повідомлення стає dead
+type OutboxService struct {
+	Outbox      repository.OutboxRepository
+	Subs        repository.SubscriptionRepository
+	Tokens      *SubscriptionService // рендерить листи з токеном у момент надсилання
+	Notifier    notifier.Notifier
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func NewOutboxService(
+	outbox repository.OutboxRepository,
+	subs repository.SubscriptionRepository,
+	tokens *SubscriptionService,
+	n notifier.Notifier,
+	cfg config.Config,
+) *OutboxService {
+	return &OutboxService{
+		Outbox:      outbox,
+		Subs:        subs,
+		Tokens:      tokens,
+		Notifier:    n,
+		MaxAttempts: cfg.OutboxMaxAttempts,
+		Backoff:     cfg.OutboxBackoff,
+	}
+}
+
+// OutboxPage — сторінка повідомлень outbox
+type OutboxPage struct {
+	Items   []models.OutboxMessage `json:"items"`
+	Total   int64                  `json:"total"`
+	Page    int                    `json:"page"`
+	PerPage int                    `json:"per_page"`
+}
+
+// newOutboxMessage готує повідомлення до запису в outbox разом з умовою підписки
+// і погодою (якщо є), які його спричинили; SubscriptionID заповнює репозиторій
+func newOutboxMessage(sub *models.Subscription, m notifier.Message, w *models.Weather, now time.Time) *models.OutboxMessage {
+	out := &models.OutboxMessage{
+		Kind:          m.Kind,
+		Channel:       notifier.ChannelFor(sub, m),
+		Condition:     sub.Condition,
+		Subject:       m.Subject,
+		Body:          m.Body,
+		HTMLBody:      m.HTML,
+		Headers:       m.Headers,
+		Status:        models.OutboxPending,
+		NextAttemptAt: now,
+	}
+	if w != nil {
+		out.Weather = &models.WeatherSnapshot{
+			Temperature: w.Temperature,
+			Humidity:    w.Humidity,
+			Condition:   w.Condition,
+			UpdatedAt:   w.UpdatedAt,
+		}
+	}
+	return out
+}
+
+// DispatchDue надсилає повідомлення, час спроби яких настав, і повертає кількість надісланих.
+// Помилка доставки не перериває обробку решти повідомлень. Якщо ctx отримано
+// з LeaseService.Do, а оренду втрачено, обробка зупиняється з ErrLeaseLost,
+// а результат спроби, що вже йшла, не записується.
+func (s *OutboxService) DispatchDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.Outbox.DueOutbox(now, outboxBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		// процес, що втратив оренду планувальника, не має надсилати
+		if err := CheckFence(ctx); err != nil {
+			return sent, err
+		}
+		msg := &due[i]
+		until, err := s.deliver(ctx, msg, now)
+		if errors.Is(err, repository.ErrFenced) {
+			// токен листа не видано: оренду вже має інший процес
+			return sent, ErrLeaseLost
+		}
+		if !until.IsZero() {
+			// підписка в тихих годинах (напр. спроба після збою потрапила на ніч) —
+			// переносимо без втрати спроби
+			msg.NextAttemptAt = until
+		} else if err != nil {
+			s.fail(msg, now, err)
+		} else {
+			msg.Status = models.OutboxSent
+			msg.Attempts++
+			msg.LastError = ""
+			msg.SentAt = &now
+			sent++
+		}
+		if err := s.Outbox.SaveOutbox(msg, fenceOf(ctx)); err != nil {
+			log.Printf("DispatchDue: failed to save outbox id=%d, err=%v", msg.ID, err)
+		}
+	}
+	if len(due) > 0 {
+		log.Printf("DispatchDue: %d of %d messages sent", sent, len(due))
+	}
+	return sent, nil
+}
+
+// deliver надсилає повідомлення; ненульовий час означає, що підписка зараз
+// на паузі чи в тихих годинах і надсилання треба відкласти до цього моменту
+func (s *OutboxService) deliver(ctx context.Context, msg *models.OutboxMessage, now time.Time) (time.Time, error) {
+	if msg.Kind == notifier.KindDigest {
+		// дайджест адресований email, а не окремій підписці
+		return time.Time{}, s.Notifier.Notify(ctx, &models.Subscription{Email: msg.Recipient}, notifier.Message{
+			Kind:    msg.Kind,
+			Subject: msg.Subject,
+			Body:    msg.Body,
+			HTML:    msg.HTMLBody,
+			Headers: msg.Headers,
+		})
+	}
+	sub, err := s.Subs.FindByID(msg.SubscriptionID)
+	if err != nil {
+		return time.Time{}, errSubscriptionGone
+	}
+	tokenMail := msg.Kind == notifier.KindConfirm || msg.Kind == notifier.KindManage
+	if !tokenMail {
+		if sub.UnsubscribedAt != nil {
+			return time.Time{}, errSubscriptionGone
+		}
+		if until, ok := SnoozedUntil(&sub, now); ok {
+			return until, nil
+		}
+		if until, ok := QuietUntil(&sub, now); ok {
+			return until, nil
+		}
+	}
+	m := notifier.Message{
+		Kind:    msg.Kind,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+		HTML:    msg.HTMLBody,
+		Headers: msg.Headers,
+	}
+	if tokenMail {
+		// у outbox лежить лише вид листа: токен видається і вставляється в лист
+		// зараз, тож у БД є тільки його хеш
+		if m, err = s.Tokens.TokenMessage(ctx, &sub, msg.Kind); err != nil {
+			return time.Time{}, err
+		}
+		msg.Subject = m.Subject
+	}
+	// канал підписки міг змінитися після постановки в чергу — записуємо фактичний
+	msg.Channel = notifier.ChannelFor(&sub, m)
+	return time.Time{}, s.Notifier.Notify(ctx, &sub, m)
+}
+
+// errSubscriptionGone — підписку видалено чи вимкнено; повторювати немає сенсу
+var errSubscriptionGone = errors.New("subscription deleted or unsubscribed")
+
+// fail записує невдалу спробу: наступна через Backoff·2^(n-1), але не пізніше
+// ніж за outboxMaxBackoff; після MaxAttempts — dead
+func (s *OutboxService) fail(msg *models.OutboxMessage, now time.Time, err error) {
+	msg.Attempts++
+	msg.LastError = truncate(err.Error(), 1024)
+	if errors.Is(err, errSubscriptionGone) || msg.Attempts >= s.MaxAttempts {
+		msg.Status = models.OutboxDead
+		log.Printf("DispatchDue: outbox id=%d is dead after %d attempts, err=%v", msg.ID, msg.Attempts, err)
+		return
+	}
+	msg.NextAttemptAt = now.Add(backoff(s.Backoff, msg.Attempts))
+	log.Printf("DispatchDue: outbox id=%d attempt %d failed, retry at %s, err=%v",
+		msg.ID, msg.Attempts, msg.NextAttemptAt.Format(time.RFC3339), err)
+}
+
+// backoff повертає затримку перед спробою після attempts невдалих
+func backoff(base time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > outboxMaxBackoff {
+		d = outboxMaxBackoff
+	}
+	return d
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// List повертає сторінку повідомлень outbox зі статусом status (усі, якщо порожній)
+func (s *OutboxService) List(status string, page, perPage int) (OutboxPage, error) {
+	switch status {
+	case "", models.OutboxPending, models.OutboxSent, models.OutboxDead, models.OutboxHeld, models.OutboxDigested:
+	default:
+		return OutboxPage{}, i18n.Wrap(ErrInvalidOutboxStatus, i18n.MsgUnknownOutboxStatus, status)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	items, total, err := s.Outbox.FindOutbox(status, (page-1)*perPage, perPage)
+	if err != nil {
+		return OutboxPage{}, err
+	}
+	if items == nil {
+		items = []models.OutboxMessage{}
+	}
+	return OutboxPage{Items: items, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+// Notification — запис журналу сповіщень для підписника: лише вид, стан доставки
+// і час. Тема, тіло й заголовки лишаються в outbox: у них посилання з токенами.
+type Notification struct {
+	ID            uint       `json:"id"`
+	Kind          string     `json:"kind"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	CreatedAt     time.Time  `json:"created_at"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at"`
+}
+
+// NotificationPage — сторінка журналу сповіщень підписки
+type NotificationPage struct {
+	Items   []Notification `json:"items"`
+	Total   int64          `json:"total"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"per_page"`
+}
+
+// Notifications повертає журнал сповіщень підписки адреси owner, новіші першими
+func (s *OutboxService) Notifications(owner string, subID uint, page, perPage int) (NotificationPage, error) {
+	sub, err := s.Subs.FindByID(subID)
+	if err != nil || !strings.EqualFold(sub.Email, owner) {
+		return NotificationPage{}, ErrSubscriptionNotFound
+	}
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	msgs, total, err := s.Outbox.FindOutboxBySubscription(subID, (page-1)*perPage, perPage)
+	if err != nil {
+		return NotificationPage{}, err
+	}
+	items := make([]Notification, 0, len(msgs))
+	for _, m := range msgs {
+		items = append(items, Notification{
+			ID:            m.ID,
+			Kind:          m.Kind,
+			Status:        m.Status,
+			Attempts:      m.Attempts,
+			CreatedAt:     m.CreatedAt,
+			NextAttemptAt: m.NextAttemptAt,
+			SentAt:        m.SentAt,
+		})
+	}
+	return NotificationPage{Items: items, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+// Retry повертає dead-повідомлення в чергу з обнуленим лічильником спроб
+func (s *OutboxService) Retry(id uint) (*models.OutboxMessage, error) {
+	msg, err := s.Outbox.FindOutboxByID(id)
+	if err != nil {
+		return nil, ErrOutboxNotFound
+	}
+	if msg.Status != models.OutboxDead {
+		return nil, i18n.Wrap(ErrInvalidOutboxStatus, i18n.MsgOutboxNotDead, msg.Status)
+	}
+	msg.Status = models.OutboxPending
+	msg.Attempts = 0
+	msg.NextAttemptAt = time.Now()
+	if err := s.Outbox.SaveOutbox(&msg, nil); err != nil {
+		return nil, err
+	}
+	log.Printf("Retry: outbox id=%d requeued", id)
+	return &msg, nil
+}
@@ -0,0 +1,322 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/notifier/notifiertest"
+	"myapp/pkg/repository"
+	"myapp/pkg/services"
+	"myapp/pkg/utils"
+)
+
+// memOutbox — outbox у пам'яті поверх повідомлень, поставлених у чергу mockSubRepo
+type memOutbox struct {
+	msgs []*models.OutboxMessage
+}
+
+func (m *memOutbox) DueOutbox(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	var out []models.OutboxMessage
+	for _, msg := range m.msgs {
+		if msg.Status == models.OutboxPending && !msg.NextAttemptAt.After(now) && len(out) < limit {
+			out = append(out, *msg)
+		}
+	}
+	return out, nil
+}
+func (m *memOutbox) SaveOutbox(msg *models.OutboxMessage, _ *repository.Fence) error {
+	for _, stored := range m.msgs {
+		if stored.ID == msg.ID {
+			*stored = *msg
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+func (m *memOutbox) FindOutboxByID(id uint) (models.OutboxMessage, error) {
+	for _, msg := range m.msgs {
+		if msg.ID == id {
+			return *msg, nil
+		}
+	}
+	return models.OutboxMessage{}, errors.New("record not found")
+}
+func (m *memOutbox) FindOutbox(status string, offset, limit int) ([]models.OutboxMessage, int64, error) {
+	var out []models.OutboxMessage
+	for _, msg := range m.msgs {
+		if status == "" || msg.Status == status {
+			out = append(out, *msg)
+		}
+	}
+	return out, int64(len(out)), nil
+}
+
+func (m *memOutbox) FindOutboxBySubscription(subID uint, offset, limit int) ([]models.OutboxMessage, int64, error) {
+	var out []models.OutboxMessage
+	for i := len(m.msgs) - 1; i >= 0; i-- {
+		if m.msgs[i].SubscriptionID == subID {
+			out = append(out, *m.msgs[i])
+		}
+	}
+	return out, int64(len(out)), nil
+}
+
+func newTestOutbox(subs *mockSubRepo, maxAttempts int) (*services.OutboxService, *memOutbox) {
+	box := &memOutbox{msgs: subs.queued}
+	tokens := services.NewSubscriptionService(subs, nil, testLinks, testTmpl, config.Config{})
+	svc := services.NewOutboxService(box, subs, tokens, notifier.NewRouter(config.Config{WebhookAllowPrivate: true}), config.Config{
+		OutboxMaxAttempts: maxAttempts,
+		OutboxBackoff:     time.Minute,
+	})
+	return svc, box
+}
+
+func TestOutboxService_DispatchDue(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	var sentTo []string
+	utils.SendMessage = func(e utils.Email) error {
+		sentTo = append(sentTo, e.To+":"+e.Subject)
+		return nil
+	}
+
+	now := time.Now()
+	subs := &mockSubRepo{byID: map[uint]models.Subscription{1: {ID: 1, Email: "a@b"}}}
+	subs.queued = []*models.OutboxMessage{
+		{ID: 1, SubscriptionID: 1, Kind: notifier.KindAlert, Subject: "due", Status: models.OutboxPending, NextAttemptAt: now.Add(-time.Second)},
+		{ID: 2, SubscriptionID: 1, Kind: notifier.KindAlert, Subject: "later", Status: models.OutboxPending, NextAttemptAt: now.Add(time.Minute)},
+		{ID: 3, SubscriptionID: 1, Kind: notifier.KindAlert, Subject: "dead", Status: models.OutboxDead},
+	}
+	svc, box := newTestOutbox(subs, 3)
+
+	n, err := svc.DispatchDue(context.Background(), now)
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 sent, got %d, %v", n, err)
+	}
+	if strings.Join(sentTo, ",") != "a@b:due" {
+		t.Errorf("unexpected deliveries: %v", sentTo)
+	}
+	if m := box.msgs[0]; m.Status != models.OutboxSent || m.SentAt == nil || m.Attempts != 1 {
+		t.Errorf("unexpected sent message: %+v", m)
+	}
+	if box.msgs[1].Status != models.OutboxPending {
+		t.Errorf("message that is not due must stay pending")
+	}
+}
+
+// Невдалі спроби повторюються з подвоєнням затримки, після MaxAttempts — dead
+func TestOutboxService_BackoffAndDeadLetter(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	utils.SendMessage = func(utils.Email) error { return errors.New("smtp down") }
+
+	now := time.Now()
+	subs := &mockSubRepo{byID: map[uint]models.Subscription{1: {ID: 1, Email: "a@b"}}}
+	subs.queued = []*models.OutboxMessage{
+		{ID: 1, SubscriptionID: 1, Kind: notifier.KindAlert, Status: models.OutboxPending, NextAttemptAt: now},
+	}
+	svc, box := newTestOutbox(subs, 3)
+	msg := box.msgs[0]
+
+	for i, wantDelay := range []time.Duration{time.Minute, 2 * time.Minute} {
+		if n, _ := svc.DispatchDue(context.Background(), now); n != 0 {
+			t.Fatalf("attempt %d: nothing must be sent", i+1)
+		}
+		if msg.Status != models.OutboxPending || msg.Attempts != i+1 || msg.LastError != "smtp down" {
+			t.Fatalf("attempt %d: unexpected message %+v", i+1, msg)
+		}
+		if got := msg.NextAttemptAt.Sub(now); got != wantDelay {
+			t.Errorf("attempt %d: want delay %s, got %s", i+1, wantDelay, got)
+		}
+		if n, _ := svc.DispatchDue(context.Background(), now); n != 0 || msg.Attempts != i+1 {
+			t.Fatalf("attempt %d: message must wait for its backoff", i+1)
+		}
+		now = msg.NextAttemptAt
+	}
+
+	svc.DispatchDue(context.Background(), now)
+	if msg.Status != models.OutboxDead || msg.Attempts != 3 {
+		t.Errorf("want dead after 3 attempts, got %+v", msg)
+	}
+
+	page, err := svc.List(models.OutboxDead, 1, 20)
+	if err != nil || page.Total != 1 || page.Items[0].ID != 1 {
+		t.Errorf("dead message must be listed, got %+v, %v", page, err)
+	}
+}
+
+func TestOutboxService_DeletedSubscription(t *testing.T) {
+	subs := &mockSubRepo{}
+	subs.queued = []*models.OutboxMessage{
+		{ID: 1, SubscriptionID: 9, Kind: notifier.KindAlert, Status: models.OutboxPending, NextAttemptAt: time.Now()},
+	}
+	svc, box := newTestOutbox(subs, 5)
+
+	svc.DispatchDue(context.Background(), time.Now())
+	if box.msgs[0].Status != models.OutboxDead || box.msgs[0].Attempts != 1 {
+		t.Errorf("message for a deleted subscription must be dead at once, got %+v", box.msgs[0])
+	}
+}
+
+// Повний шлях: обчислення → outbox → диспетчер → вебхук підписки
+func TestOutboxService_WebhookChannel(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	utils.SendMessage = func(utils.Email) error {
+		t.Error("webhook subscription must not be emailed")
+		return nil
+	}
+
+	rcv := notifiertest.NewReceiver()
+	defer rcv.Close()
+
+	sub := models.Subscription{ID: 3, Condition: "temp < 0", City: "Kyiv", Channel: models.ChannelWebhook, WebhookURL: rcv.URL, WebhookSecret: "s3cret"}
+	subs := &mockSubRepo{byID: map[uint]models.Subscription{3: sub}}
+	if _, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svc, _ := newTestOutbox(subs, 3)
+	if n, err := svc.DispatchDue(context.Background(), time.Now()); n != 1 || err != nil {
+		t.Fatalf("want 1 sent, got %d, %v", n, err)
+	}
+
+	reqs := rcv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("want 1 webhook request, got %d", len(reqs))
+	}
+	var p notifier.WebhookPayload
+	json.Unmarshal(reqs[0].Body, &p)
+	if p.Event != notifier.KindAlert || p.SubscriptionID != 3 || !strings.Contains(p.Text, "Unsubscribe: http://alerts.test/") {
+		t.Errorf("unexpected payload: %+v", p)
+	}
+}
+
+// Токен підтвердження є лише в надісланому листі: жоден рядок outbox його не містить
+func TestOutboxService_ConfirmTokenNotStored(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	var mailed []utils.Email
+	utils.SendMessage = func(e utils.Email) error {
+		mailed = append(mailed, e)
+		return nil
+	}
+
+	subs := &mockSubRepo{}
+	ss := services.NewSubscriptionService(subs, &mockWeatherRepo{exists: true}, testLinks, testTmpl, config.Config{ResendInterval: time.Nanosecond})
+	sub := &models.Subscription{Email: "a@b", City: "Kyiv", Condition: "temp < 0"}
+	if err := ss.Create(sub); err != nil {
+		t.Fatal(err)
+	}
+	subs.byID = map[uint]models.Subscription{sub.ID: *sub}
+	if _, err := ss.ResendConfirmation("a@b", "Kyiv"); err != nil {
+		t.Fatal(err)
+	}
+	svc, box := newTestOutbox(subs, 3)
+	if n, err := svc.DispatchDue(context.Background(), time.Now()); n != 2 || err != nil {
+		t.Fatalf("want 2 sent, got %d, %v", n, err)
+	}
+	if len(mailed) != 2 {
+		t.Fatalf("want 2 emails, got %d", len(mailed))
+	}
+
+	var sent []string
+	for _, e := range mailed {
+		sent = append(sent, confirmToken(t, e.Body))
+	}
+	for _, msg := range box.msgs {
+		row, _ := json.Marshal(msg)
+		for _, token := range sent {
+			if strings.Contains(string(row)+msg.HTMLBody, token) {
+				t.Errorf("outbox id=%d contains the raw token", msg.ID)
+			}
+		}
+		if msg.Status != models.OutboxSent || msg.Subject == "" {
+			t.Errorf("unexpected outbox row: %+v", msg)
+		}
+	}
+
+	// діє лише токен з останнього листа
+	if _, err := ss.Confirm(sent[0]); !errors.Is(err, services.ErrTokenNotFound) {
+		t.Errorf("replaced token must not confirm, got %v", err)
+	}
+	if _, err := ss.Confirm(sent[1]); err != nil {
+		t.Errorf("token from the email must confirm, got %v", err)
+	}
+}
+
+func TestOutboxService_Retry(t *testing.T) {
+	subs := &mockSubRepo{}
+	subs.queued = []*models.OutboxMessage{
+		{ID: 1, Status: models.OutboxDead, Attempts: 8, LastError: "smtp down"},
+		{ID: 2, Status: models.OutboxSent},
+	}
+	svc, box := newTestOutbox(subs, 8)
+
+	msg, err := svc.Retry(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Status != models.OutboxPending || msg.Attempts != 0 || box.msgs[0].Status != models.OutboxPending {
+		t.Errorf("want message requeued, got %+v", box.msgs[0])
+	}
+	if _, err := svc.Retry(2); !errors.Is(err, services.ErrInvalidOutboxStatus) {
+		t.Errorf("sent message: want ErrInvalidOutboxStatus, got %v", err)
+	}
+	if _, err := svc.Retry(42); !errors.Is(err, services.ErrOutboxNotFound) {
+		t.Errorf("unknown message: want ErrOutboxNotFound, got %v", err)
+	}
+	if _, err := svc.List("bogus", 1, 20); !errors.Is(err, services.ErrInvalidOutboxStatus) {
+		t.Errorf("unknown status: want ErrInvalidOutboxStatus, got %v", err)
+	}
+}
+
+// Журнал сповіщень: вид, статус і спроби — без тіла листа з посиланнями й без чужих підписок
+func TestOutboxService_Notifications(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	utils.SendMessage = func(utils.Email) error { return errors.New("mailbox full") }
+
+	sub := models.Subscription{ID: 4, Email: "a@b", City: "Kyiv", Condition: "temp < 0", NotifyClear: true}
+	subs := &mockSubRepo{byID: map[uint]models.Subscription{4: sub}}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{City: "Kyiv", Temperature: -4, Humidity: 70, Condition: "Snow"})
+	ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{City: "Kyiv", Temperature: 2, Humidity: 60, Condition: "Clear"})
+
+	svc, _ := newTestOutbox(subs, 3)
+	svc.DispatchDue(context.Background(), time.Now())
+
+	page, err := svc.Notifications("a@b", 4, 1, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.Total != 2 {
+		t.Fatalf("want 2 notifications, got %d", page.Total)
+	}
+	clear, alert := page.Items[0], page.Items[1]
+	if alert.Kind != notifier.KindAlert || clear.Kind != notifier.KindClear {
+		t.Errorf("want newest first, got %s, %s", page.Items[0].Kind, page.Items[1].Kind)
+	}
+	if alert.Status != models.OutboxPending || alert.Attempts != 1 || alert.NextAttemptAt.IsZero() || alert.SentAt != nil {
+		t.Errorf("alert must record the failed attempt, got %+v", alert)
+	}
+	raw, _ := json.Marshal(page)
+	for _, leak := range []string{"body", "headers", "subject", "unsubscribe", "token"} {
+		if strings.Contains(string(raw), leak) {
+			t.Errorf("notification log must not expose %q: %s", leak, raw)
+		}
+	}
+
+	if _, err := svc.Notifications("a@b", 99, 1, 20); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Errorf("want ErrSubscriptionNotFound, got %v", err)
+	}
+	if _, err := svc.Notifications("other@b", 4, 1, 20); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Errorf("other owner: want ErrSubscriptionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"time"
+
+	"myapp/pkg/i18n"
+	"myapp/pkg/models"
+)
+
+// clockLayout — формат тихих годин підписки
+const clockLayout = "15:04"
+
+// QuietUntil повертає кінець тихих годин підписки, якщо момент now у них потрапляє.
+// Години рахуються в часовому поясі підписки; вікно на кшталт 22:00–07:00
+// переходить через північ. Однакові початок і кінець означають, що тихих годин немає.
+func QuietUntil(sub *models.Subscription, now time.Time) (time.Time, bool) {
+	if sub.QuietStart == "" || sub.QuietEnd == "" {
+		return time.Time{}, false
+	}
+	start, err := parseClock(sub.QuietStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := parseClock(sub.QuietEnd)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+
+	local := now.In(location(sub.Timezone))
+	mins := local.Hour()*60 + local.Minute()
+
+	var quiet bool
+	if start < end {
+		quiet = mins >= start && mins < end
+	} else {
+		quiet = mins >= start || mins < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	day := local
+	if start > end && mins >= start {
+		day = day.AddDate(0, 0, 1)
+	}
+	y, m, d := day.Date()
+	return time.Date(y, m, d, end/60, end%60, 0, 0, local.Location()), true
+}
+
+// SnoozedUntil повертає кінець паузи підписки, якщо в момент now вона призупинена.
+// Коли пауза минає, підписка відновлюється сама — окремої дії не потрібно.
+func SnoozedUntil(sub *models.Subscription, now time.Time) (time.Time, bool) {
+	if sub.PausedUntil == nil || !now.Before(*sub.PausedUntil) {
+		return time.Time{}, false
+	}
+	return *sub.PausedUntil, true
+}
+
+// cooldownUntil повертає кінець проміжку між сповіщеннями підписки (CooldownMinutes
+// від останнього спрацювання, LastAlertAt), якщо в момент now він ще триває.
+// LastSent тут не годиться: його оновлює й відбій, а для дайджесту — лише збирання дайджесту.
+func cooldownUntil(sub *models.Subscription, now time.Time) (time.Time, bool) {
+	if sub.CooldownMinutes <= 0 || sub.LastAlertAt == nil {
+		return time.Time{}, false
+	}
+	until := sub.LastAlertAt.Add(sub.Cooldown())
+	return until, now.Before(until)
+}
+
+// checkSchedule перевіряє часовий пояс і тихі години; порожній пояс — UTC
+func checkSchedule(sub *models.Subscription) error {
+	if sub.Timezone == "" {
+		sub.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(sub.Timezone); err != nil {
+		return i18n.Wrap(ErrInvalidSchedule, i18n.MsgInvalidTimezone, sub.Timezone)
+	}
+	if (sub.QuietStart == "") != (sub.QuietEnd == "") {
+		return i18n.Wrap(ErrInvalidSchedule, i18n.MsgQuietHoursPair)
+	}
+	for _, v := range []string{sub.QuietStart, sub.QuietEnd} {
+		if v == "" {
+			continue
+		}
+		if _, err := parseClock(v); err != nil {
+			return i18n.Wrap(ErrInvalidSchedule, i18n.MsgInvalidQuietTime, v)
+		}
+	}
+	return nil
+}
+
+// parseClock перетворює "HH:MM" на хвилини від початку доби
+func parseClock(s string) (int, error) {
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// location повертає часовий пояс підписки; невідомий чи порожній — UTC
+func location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,128 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/services"
+	"myapp/pkg/utils"
+)
+
+func TestQuietUntil(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	at := func(y int, m time.Month, d, h, min int) time.Time { return time.Date(y, m, d, h, min, 0, 0, kyiv) }
+
+	night := models.Subscription{Timezone: "Europe/Kyiv", QuietStart: "22:00", QuietEnd: "07:00"}
+	lunch := models.Subscription{Timezone: "Europe/Kyiv", QuietStart: "12:00", QuietEnd: "13:30"}
+
+	tests := []struct {
+		name  string
+		sub   models.Subscription
+		now   time.Time
+		want  time.Time
+		quiet bool
+	}{
+		{"BeforeWindow", night, at(2025, 1, 10, 21, 59), time.Time{}, false},
+		{"StartOfWindow", night, at(2025, 1, 10, 22, 0), at(2025, 1, 11, 7, 0), true},
+		{"AfterMidnight", night, at(2025, 1, 11, 3, 15), at(2025, 1, 11, 7, 0), true},
+		{"EndOfWindow", night, at(2025, 1, 11, 7, 0), time.Time{}, false},
+		{"SameDayWindow", lunch, at(2025, 1, 10, 12, 45), at(2025, 1, 10, 13, 30), true},
+		{"OutsideSameDay", lunch, at(2025, 1, 10, 13, 30), time.Time{}, false},
+		// 21:30 UTC — це вже 23:30 у Києві взимку
+		{"ServerInUTC", night, time.Date(2025, 1, 10, 21, 30, 0, 0, time.UTC), at(2025, 1, 11, 7, 0), true},
+		// ніч переходу на літній час: кінець вікна — 07:00 за новим часом
+		{"DSTSwitch", night, at(2025, 3, 29, 23, 0), at(2025, 3, 30, 7, 0), true},
+		{"NoQuietHours", models.Subscription{Timezone: "Europe/Kyiv"}, at(2025, 1, 10, 3, 0), time.Time{}, false},
+		{"EmptyWindow", models.Subscription{QuietStart: "08:00", QuietEnd: "08:00"}, at(2025, 1, 10, 8, 0), time.Time{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, quiet := services.QuietUntil(&tc.sub, tc.now)
+			if quiet != tc.quiet || !got.Equal(tc.want) {
+				t.Errorf("got %v, %v; want %v, %v", got, quiet, tc.want, tc.quiet)
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_Create_Schedule(t *testing.T) {
+	tests := []struct {
+		name, tz, start, end string
+		wantErr              bool
+	}{
+		{"DefaultsToUTC", "", "", "", false},
+		{"Valid", "Europe/Kyiv", "22:00", "07:00", false},
+		{"UnknownTimezone", "Mars/Olympus", "", "", true},
+		{"OnlyStart", "UTC", "22:00", "", true},
+		{"BadTime", "UTC", "22:00", "7am", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := services.NewSubscriptionService(&mockSubRepo{}, &mockWeatherRepo{exists: true}, testLinks, testTmpl, config.Config{})
+			sub := &models.Subscription{Email: "e@e", City: "C", Timezone: tc.tz, QuietStart: tc.start, QuietEnd: tc.end}
+			err := svc.Create(sub)
+			if tc.wantErr != errors.Is(err, services.ErrInvalidSchedule) {
+				t.Fatalf("wantErr=%v, got %v", tc.wantErr, err)
+			}
+			if !tc.wantErr && sub.Timezone == "" {
+				t.Error("timezone must be defaulted")
+			}
+		})
+	}
+}
+
+// Сповіщення в тихі години ставиться в чергу з часом спроби на кінець вікна
+func TestEvaluateAndNotify_DefersInQuietHours(t *testing.T) {
+	subs := &mockSubRepo{}
+	// вікно від години тому до двох годин уперед — now точно всередині
+	now := time.Now().UTC()
+	end := now.Add(2 * time.Hour).Format("15:04")
+	start := now.Add(-time.Hour).Format("15:04")
+	sub := models.Subscription{ID: 5, Condition: "temp < 0", Email: "a@b", City: "C", Timezone: "UTC", QuietStart: start, QuietEnd: end}
+
+	if _, err := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil).EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -1}); err != nil {
+		t.Fatal(err)
+	}
+	got := subs.lastQueued()
+	if d := got.NextAttemptAt.Sub(now); d < time.Hour || d > 2*time.Hour {
+		t.Errorf("alert must be deferred to %s, next attempt at %v", end, got.NextAttemptAt)
+	}
+	if sub.AlertState != models.AlertStateFired {
+		t.Error("alert state changes even if delivery is deferred")
+	}
+}
+
+// Диспетчер не надсилає в тихі години, а переносить спробу, не рахуючи її
+func TestOutboxService_DispatchDue_QuietHours(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	sent := 0
+	utils.SendMessage = func(utils.Email) error { sent++; return nil }
+
+	now := time.Date(2025, 1, 10, 23, 0, 0, 0, time.UTC)
+	quiet := models.Subscription{ID: 1, Email: "a@b", Timezone: "UTC", QuietStart: "22:00", QuietEnd: "07:00"}
+	subs := &mockSubRepo{byID: map[uint]models.Subscription{1: quiet}}
+	subs.queued = []*models.OutboxMessage{
+		{ID: 1, SubscriptionID: 1, Kind: notifier.KindAlert, Status: models.OutboxPending, NextAttemptAt: now, Attempts: 2},
+		{ID: 2, SubscriptionID: 1, Kind: notifier.KindConfirm, Status: models.OutboxPending, NextAttemptAt: now},
+	}
+	svc, box := newTestOutbox(subs, 5)
+
+	n, err := svc.DispatchDue(context.Background(), now)
+	if err != nil || n != 1 || sent != 1 {
+		t.Fatalf("only the confirmation must be sent, got n=%d sent=%d err=%v", n, sent, err)
+	}
+	alert := box.msgs[0]
+	want := time.Date(2025, 1, 11, 7, 0, 0, 0, time.UTC)
+	if alert.Status != models.OutboxPending || !alert.NextAttemptAt.Equal(want) || alert.Attempts != 2 {
+		t.Errorf("alert must be deferred to %v without an attempt: %+v", want, alert)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/repository"
+	"time"
+)
+
+// RunService обчислює всі підписки й записує кожен запуск у журнал SchedulerRun
+type RunService struct {
+	Runs      repository.RunRepository
+	Evaluator *CityEvaluator
+	Lease     *LeaseService
+	Workers   int
+	// Timeout — дедлайн одного запуску; 0 — без дедлайну
+	Timeout time.Duration
+}
+
+func NewRunService(runs repository.RunRepository, evaluator *CityEvaluator, lease *LeaseService, cfg config.Config) *RunService {
+	return &RunService{Runs: runs, Evaluator: evaluator, Lease: lease, Workers: cfg.EvalWorkers, Timeout: cfg.EvalTimeout}
+}
+
+// RunPage — сторінка журналу запусків
+type RunPage struct {
+	Items   []models.SchedulerRun `json:"items"`
+	Total   int64                 `json:"total"`
+	Page    int                   `json:"page"`
+	PerPage int                   `json:"per_page"`
+}
+
+// Run обчислює підтверджені підписки і записує запуск: на початку — без
+// FinishedAt, у кінці — з підсумком. Такт cron (RunCron) обчислює лише підписки,
+// час яких настав; запуск на вимогу — усі. Помилки обчислення (зокрема дедлайн)
+// потрапляють у run.Errors; помилка повертається, лише коли журнал не вдалося зберегти.
+func (s *RunService) Run(ctx context.Context, trigger string, dryRun bool) (*models.SchedulerRun, error) {
+	run := &models.SchedulerRun{Trigger: trigger, DryRun: dryRun, StartedAt: time.Now()}
+	if err := s.Runs.SaveRun(run); err != nil {
+		return nil, fmt.Errorf("save run: %w", err)
+	}
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	evaluate := s.Evaluator.EvaluateAll
+	if trigger == models.RunCron {
+		evaluate = s.Evaluator.EvaluateDue
+	}
+	st, err := evaluate(ctx, s.Workers, dryRun)
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Evaluated, run.Matched, run.Sent, run.Failed = st.Evaluated, st.Matched, st.Sent, st.Failed
+	run.Errors = st.Errors
+	if err != nil {
+		run.Errors = append(run.Errors, err.Error())
+	}
+	if err := s.Runs.SaveRun(run); err != nil {
+		return run, fmt.Errorf("save run: %w", err)
+	}
+	return run, nil
+}
+
+// Trigger виконує запуск на вимогу (RunManual). Як і такт cron, він іде під орендою
+// планувальника: інакше репліка без оренди записувала б стан підписок поза fencing.
+// Якщо оренду утримує інша репліка, повертає ErrLeaseHeld. Пробний запуск змінює
+// лише журнал, тож оренди не потребує і виконується на будь-якій репліці.
+func (s *RunService) Trigger(ctx context.Context, dryRun bool) (*models.SchedulerRun, error) {
+	if dryRun {
+		return s.Run(ctx, models.RunManual, true)
+	}
+	var (
+		run    *models.SchedulerRun
+		runErr error
+	)
+	ran, err := s.Lease.Do(ctx, func(ctx context.Context) {
+		run, runErr = s.Run(ctx, models.RunManual, false)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("acquire lease: %w", err)
+	}
+	if !ran {
+		return nil, ErrLeaseHeld
+	}
+	return run, runErr
+}
+
+// List повертає сторінку журналу запусків, новіші першими
+func (s *RunService) List(page, perPage int) (RunPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	items, total, err := s.Runs.FindRuns((page-1)*perPage, perPage)
+	if err != nil {
+		return RunPage{}, err
+	}
+	if items == nil {
+		items = []models.SchedulerRun{}
+	}
+	return RunPage{Items: items, Total: total, Page: page, PerPage: perPage}, nil
+}
@@ -0,0 +1,151 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/services"
+)
+
+// memRuns — журнал запусків у пам'яті; finishedOnSave фіксує, чи був запис завершеним
+type memRuns struct {
+	runs           []models.SchedulerRun
+	finishedOnSave []bool
+}
+
+func (m *memRuns) SaveRun(run *models.SchedulerRun) error {
+	if run.ID == 0 {
+		run.ID = uint(len(m.runs) + 1)
+		m.runs = append(m.runs, *run)
+	} else {
+		m.runs[run.ID-1] = *run
+	}
+	m.finishedOnSave = append(m.finishedOnSave, run.FinishedAt != nil)
+	return nil
+}
+
+func (m *memRuns) FindRuns(offset, limit int) ([]models.SchedulerRun, int64, error) {
+	var out []models.SchedulerRun
+	for i := len(m.runs) - 1 - offset; i >= 0 && len(out) < limit; i-- {
+		out = append(out, m.runs[i])
+	}
+	return out, int64(len(m.runs)), nil
+}
+
+func newRunService(subs *mockSubRepo, runs *memRuns) *services.RunService {
+	ws := services.NewWeatherService(&spyRepo{returnWeather: models.Weather{City: "Kyiv", Temperature: -3}}, nil)
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	// оренда з TTL 0 вимкнена: запуск на вимогу виконується завжди
+	lease := &services.LeaseService{Repo: &memLease{}, Name: services.SchedulerLease}
+	return services.NewRunService(runs, services.NewCityEvaluator(ws, subs, ns), lease, config.Config{EvalWorkers: 1})
+}
+
+func TestRunService_RecordsRun(t *testing.T) {
+	subs := &mockSubRepo{verifiedList: []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"},
+		{ID: 2, Email: "a@b", City: "Kyiv", Condition: "temp < 0", AlertState: models.AlertStateFired},
+	}}
+	runs := &memRuns{}
+
+	run, err := newRunService(subs, runs).Run(context.Background(), models.RunCron, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs.runs) != 1 || run.ID != 1 {
+		t.Fatalf("want one run record, got %+v", runs.runs)
+	}
+	if len(runs.finishedOnSave) != 2 || runs.finishedOnSave[0] || !runs.finishedOnSave[1] {
+		t.Errorf("run must be saved when started and when finished, got %v", runs.finishedOnSave)
+	}
+	got := runs.runs[0]
+	if got.Trigger != models.RunCron || got.Evaluated != 2 || got.Matched != 2 || got.Sent != 1 || got.Failed != 0 {
+		t.Errorf("unexpected run %+v", got)
+	}
+}
+
+func TestRunService_RecordsErrors(t *testing.T) {
+	subs := &mockSubRepo{listErr: errors.New("db down")}
+	runs := &memRuns{}
+
+	run, err := newRunService(subs, runs).Run(context.Background(), models.RunManual, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !run.DryRun || run.FinishedAt == nil || len(run.Errors) != 1 {
+		t.Errorf("evaluation error must be recorded in the run, got %+v", run)
+	}
+}
+
+func TestRunService_List(t *testing.T) {
+	runs := &memRuns{}
+	svc := newRunService(&mockSubRepo{}, runs)
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Run(context.Background(), models.RunCron, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := svc.List(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].ID != 3 {
+		t.Errorf("want newest runs first, got %+v", page)
+	}
+}
+
+// Запуск на вимогу зі змінами йде під орендою планувальника; пробний — ні
+func TestRunService_TriggerUnderLease(t *testing.T) {
+	subs := &mockSubRepo{verifiedList: []models.Subscription{{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"}}}
+	runs := &memRuns{}
+	svc := newRunService(subs, runs)
+	leases := &memLease{leases: map[string]*models.Lease{}}
+	svc.Lease = newLease(leases, "a")
+
+	// оренду утримує інша репліка
+	if ran, _ := newLease(leases, "b").Do(context.Background(), func(context.Context) {}); !ran {
+		t.Fatal("b must take a free lease")
+	}
+	if _, err := svc.Trigger(context.Background(), false); !errors.Is(err, services.ErrLeaseHeld) {
+		t.Fatalf("want ErrLeaseHeld, got %v", err)
+	}
+	if len(runs.runs) != 0 || len(subs.queued) != 0 {
+		t.Fatalf("nothing must run without the lease, got runs=%d queued=%d", len(runs.runs), len(subs.queued))
+	}
+
+	run, err := svc.Trigger(context.Background(), true)
+	if err != nil || !run.DryRun || run.Trigger != models.RunManual || run.Evaluated != 1 {
+		t.Fatalf("dry run must not need the lease, got %+v err=%v", run, err)
+	}
+
+	// оренда b спливла — запуск забирає її
+	leases.leases[services.SchedulerLease].ExpiresAt = time.Now().Add(-time.Second)
+	run, err = svc.Trigger(context.Background(), false)
+	if err != nil || run.DryRun || run.Sent != 1 {
+		t.Fatalf("want a manual run under the lease, got %+v err=%v", run, err)
+	}
+}
+
+// Такт cron обчислює лише підписки, час яких настав; запуск на вимогу — усі
+func TestRunService_ManualIgnoresDue(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	list := []models.Subscription{
+		{ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0"},
+		{ID: 2, Email: "a@b", City: "Kyiv", Condition: "temp < 0", NextDueAt: &later},
+	}
+	svc := newRunService(&mockSubRepo{verifiedList: list}, &memRuns{})
+
+	for trigger, want := range map[string]int{models.RunCron: 1, models.RunManual: 2} {
+		run, err := svc.Run(context.Background(), trigger, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run.Evaluated != want {
+			t.Errorf("%s run: want %d evaluated, got %d", trigger, want, run.Evaluated)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/services"
+	"myapp/pkg/utils"
+)
+
+func TestSubscriptionService_Snooze(t *testing.T) {
+	paused := time.Now().Add(time.Hour)
+	cases := []struct {
+		name    string
+		id      uint
+		d       time.Duration
+		wantErr error
+		want    time.Duration // 0 — пауза знята
+	}{
+		{"Snooze", 7, 48 * time.Hour, nil, 48 * time.Hour},
+		{"Resume", 7, 0, nil, 0},
+		{"Negative", 7, -time.Hour, services.ErrInvalidSnooze, 0},
+		{"TooLong", 7, services.MaxSnooze + time.Hour, services.ErrInvalidSnooze, 0},
+		{"UnknownSubscription", 8, time.Hour, services.ErrSubscriptionNotFound, 0},
+		{"OtherOwner", 9, time.Hour, services.ErrSubscriptionNotFound, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mSub := &mockSubRepo{byID: map[uint]models.Subscription{
+				7: {ID: 7, Email: "a@b", PausedUntil: &paused, NextDueAt: &paused},
+				9: {ID: 9, Email: "other@b"},
+			}}
+			svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+
+			_, err := svc.Snooze("a@b", tc.id, tc.d)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want err %v, got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				if mSub.lastUpdated != nil {
+					t.Error("nothing must be saved on error")
+				}
+				return
+			}
+			got := mSub.lastUpdated.PausedUntil
+			switch {
+			case tc.want == 0 && got != nil:
+				t.Errorf("pause must be cleared, got %v", got)
+			case tc.want > 0 && (got == nil || time.Until(*got) < tc.want-time.Minute || time.Until(*got) > tc.want):
+				t.Errorf("want pause for %s, got %v", tc.want, got)
+			}
+			// next_due_at старої паузи не має затримати обчислення
+			if next := mSub.lastUpdated.NextDueAt; next != nil {
+				t.Errorf("next due must be reset with the pause, got %v", next)
+			}
+			// стан алерту й розклад пише планувальник, пауза їх не чіпає
+			if got := mSub.columns(); got != "next_due_at,paused_until" {
+				t.Errorf("want only the pause columns written, got %q", got)
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_SnoozeByToken(t *testing.T) {
+	valid := testLinks.Token(services.LinkSnooze, 7, time.Hour)
+	longer := time.Now().Add(72 * time.Hour)
+
+	cases := []struct {
+		name    string
+		token   string
+		sub     models.Subscription
+		wantErr error
+		updated bool
+	}{
+		{"Success", valid, models.Subscription{ID: 7}, nil, true},
+		{"KeepsLongerPause", valid, models.Subscription{ID: 7, PausedUntil: &longer}, nil, false},
+		{"UnsubscribeToken", testLinks.Token(services.LinkUnsubscribe, 7, 0), models.Subscription{ID: 7}, services.ErrTokenNotFound, false},
+		{"UnknownSubscription", valid, models.Subscription{ID: 8}, services.ErrTokenNotFound, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mSub := &mockSubRepo{byID: map[uint]models.Subscription{tc.sub.ID: tc.sub}}
+			svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+
+			sub, err := svc.SnoozeByToken(tc.token)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want err %v, got %v", tc.wantErr, err)
+			}
+			if (mSub.lastUpdated != nil) != tc.updated {
+				t.Fatalf("want update=%v, got %+v", tc.updated, mSub.lastUpdated)
+			}
+			if err == nil && time.Until(*sub.PausedUntil) < services.SnoozeLinkDuration-time.Minute {
+				t.Errorf("unexpected pause end %v", sub.PausedUntil)
+			}
+		})
+	}
+}
+
+// Призупинена підписка не обчислюється, а після кінця паузи працює як звичайно
+func TestEvaluateAndNotify_SkipsSnoozed(t *testing.T) {
+	subs := &mockSubRepo{}
+	ns := services.NewNotifyService(nil, subs, testLinks, testTmpl, nil)
+	until := time.Now().Add(time.Hour)
+	sub := models.Subscription{ID: 9, Condition: "temp < 0", Email: "a@b", City: "C", PausedUntil: &until}
+
+	queued, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -1})
+	if err != nil || queued {
+		t.Fatalf("snoozed subscription must be skipped, got %v, %v", queued, err)
+	}
+	if sub.LastEvaluatedAt != nil || sub.AlertState != "" || len(subs.queued) != 0 {
+		t.Errorf("snoozed subscription must not change: %+v", sub)
+	}
+	// до кінця паузи планувальник підписку не вибирає
+	if sub.NextDueAt == nil || !sub.NextDueAt.Equal(until) || subs.saves != 1 {
+		t.Errorf("want next due at the end of the pause, got %v (saves=%d)", sub.NextDueAt, subs.saves)
+	}
+	if _, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -1}); err != nil || subs.saves != 1 {
+		t.Errorf("next due already at the end of the pause must not be saved again, got saves=%d err=%v", subs.saves, err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	sub.PausedUntil = &past
+	if queued, err := ns.EvaluateAndNotify(context.Background(), &sub, models.Weather{Temperature: -1}); err != nil || !queued {
+		t.Fatalf("alert must fire after the pause, got %v, %v", queued, err)
+	}
+
+	// у листі є посилання на паузу для цієї підписки
+	body := subs.lastQueued().Body
+	i := strings.Index(body, "Pause alerts for 24 hours: ")
+	if i < 0 {
+		t.Fatalf("body has no snooze link: %q", body)
+	}
+	link := strings.Fields(body[i+len("Pause alerts for 24 hours: "):])[0]
+	u, _ := url.Parse(link)
+	if u.Path != services.SnoozePath {
+		t.Errorf("unexpected snooze link: %s", link)
+	}
+	if id, err := testLinks.Verify(services.LinkSnooze, u.Query().Get("token")); err != nil || id != 9 {
+		t.Errorf("snooze token must verify to id 9, got %d, %v", id, err)
+	}
+}
+
+// Сповіщення, що вже в черзі, чекають до кінця паузи, не витрачаючи спроб
+func TestOutboxService_DispatchDue_Snoozed(t *testing.T) {
+	orig := utils.SendMessage
+	defer func() { utils.SendMessage = orig }()
+	sent := 0
+	utils.SendMessage = func(utils.Email) error { sent++; return nil }
+
+	now := time.Now()
+	until := now.Add(48 * time.Hour)
+	subs := &mockSubRepo{byID: map[uint]models.Subscription{1: {ID: 1, Email: "a@b", PausedUntil: &until}}}
+	subs.queued = []*models.OutboxMessage{
+		{ID: 1, SubscriptionID: 1, Kind: notifier.KindAlert, Status: models.OutboxPending, NextAttemptAt: now},
+	}
+	svc, box := newTestOutbox(subs, 5)
+
+	if n, err := svc.DispatchDue(context.Background(), now); err != nil || n != 0 || sent != 0 {
+		t.Fatalf("nothing must be sent while snoozed, got n=%d sent=%d err=%v", n, sent, err)
+	}
+	if msg := box.msgs[0]; msg.Status != models.OutboxPending || !msg.NextAttemptAt.Equal(until) || msg.Attempts != 0 {
+		t.Errorf("alert must be deferred to %v: %+v", until, msg)
+	}
+}
@@ -1,100 +1,618 @@
 package services
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"myapp/pkg/config"
+	"myapp/pkg/i18n"
 	"myapp/pkg/models"
+	"myapp/pkg/notifier"
 	"myapp/pkg/repository"
-	"myapp/pkg/utils"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
+	"myapp/pkg/tokens"
+	"net/url"
+	"strings"
 	"time"
 )
 
 type SubscriptionService struct {
 	SubRepo     repository.SubscriptionRepository
 	WeatherRepo repository.WeatherRepository
+	Links       *signedlink.Signer
+	Tmpl        *templates.Renderer
+	// ResendInterval — найменший проміжок між листами підтвердження на адресу
+	ResendInterval time.Duration
+	// UnverifiedGrace — скільки зберігати непідтверджену підписку після спливання токена
+	UnverifiedGrace time.Duration
 }
 
 func NewSubscriptionService(
 	subRepo repository.SubscriptionRepository,
 	weatherRepo repository.WeatherRepository,
+	links *signedlink.Signer,
+	tmpl *templates.Renderer,
+	cfg config.Config,
 ) *SubscriptionService {
 	return &SubscriptionService{
-		SubRepo:     subRepo,
-		WeatherRepo: weatherRepo,
+		SubRepo:         subRepo,
+		WeatherRepo:     weatherRepo,
+		Links:           links,
+		Tmpl:            tmpl,
+		ResendInterval:  cfg.ResendInterval,
+		UnverifiedGrace: cfg.UnverifiedGrace,
 	}
 }
 
+// Create зберігає нову підписку. З sub беруться лише налаштування, які задає клієнт;
+// стан сповіщення, пауза, відписка та інші службові поля завжди починаються з нуля.
 func (s *SubscriptionService) Create(sub *models.Subscription) error {
 	log.Printf("Create: start subscription for email=%s, city=%s", sub.Email, sub.City)
-
-	// 1) Перевіряємо наявність міста в БД
-	if _, err := s.WeatherRepo.GetByCity(sub.City); err != nil {
-		log.Printf("Create: city not found=%s, err=%v", sub.City, err)
-		return fmt.Errorf("місто %q не знайдено", sub.City)
+	*sub = models.Subscription{
+		Email:           sub.Email,
+		City:            sub.City,
+		Condition:       sub.Condition,
+		Hysteresis:      sub.Hysteresis,
+		NotifyClear:     sub.NotifyClear,
+		Channel:         sub.Channel,
+		WebhookURL:      sub.WebhookURL,
+		Language:        sub.Language,
+		Timezone:        sub.Timezone,
+		QuietStart:      sub.QuietStart,
+		QuietEnd:        sub.QuietEnd,
+		IntervalMinutes: sub.IntervalMinutes,
+		CooldownMinutes: sub.CooldownMinutes,
 	}
 
-	// 2) Генеруємо токен
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		log.Printf("Create: token generation failed, err=%v", err)
+	// 1) Перевіряємо наявність міста в БД і канал доставки
+	if err := s.checkCity(sub.City); err != nil {
+		return err
+	}
+	if err := checkChannel(sub); err != nil {
+		return err
+	}
+	if err := checkSchedule(sub); err != nil {
 		return err
 	}
-	token := hex.EncodeToString(b)
-	expires := time.Now().Add(24 * time.Hour)
-
-	sub.Verified = false
-	sub.VerificationToken = token
-	sub.TokenExpiresAt = &expires
 
-	// 3) Зберігаємо підписку у репозиторій
-	if err := s.SubRepo.Create(sub); err != nil {
-		log.Printf("Create: failed to save subscription, err=%v", err)
+	if sub.Language == "" {
+		sub.Language = i18n.Default
+	}
+	if sub.IntervalMinutes == 0 {
+		sub.IntervalMinutes = DefaultIntervalMinutes
+	}
+	sub.AlertState = models.AlertStateCleared
+	// ключ підпису вебхуків власний у кожної підписки; клієнт бачить його лише у відповіді
+	secret, err := tokens.Secret()
+	if err != nil {
+		log.Printf("Create: failed to generate webhook secret, err=%v", err)
 		return err
 	}
-	log.Printf("Create: subscription saved, id=%d", sub.ID)
+	sub.WebhookSecret = secret
 
-	// 4) Відправляємо лист для підтвердження
-	link := fmt.Sprintf("http://localhost:8080/subscriptions/confirm?token=%s", token)
-	subject := "Please confirm your subscription"
-	body := fmt.Sprintf("Click to confirm: %s\nExpires at: %s", link, expires.Format(time.RFC1123))
-	if err := utils.SendEmail(sub.Email, subject, body); err != nil {
-		log.Printf("Create: failed to send email to %s, err=%v", sub.Email, err)
+	// 2) Зберігаємо підписку разом із листом підтвердження в outbox; надішле його
+	// диспетчер, тож збій пошти не губить ні підписку, ні лист. Токен видається
+	// в момент надсилання (див. TokenMessage)
+	msg := confirmation(sub)
+	if err := s.SubRepo.Create(sub, msg); err != nil {
+		log.Printf("Create: failed to save subscription, err=%v", err)
 		return err
 	}
-	log.Printf("Create: confirmation email sent to %s", sub.Email)
+	log.Printf("Create: subscription saved, id=%d, confirmation queued as outbox id=%d", sub.ID, msg.ID)
 
 	return nil
 }
 
-// Confirm підтверджує підписку за токеном
+// ResendConfirmation надсилає новий лист підтвердження для непідтвердженої
+// підписки email на місто city; попередній токен перестає діяти. Для однієї
+// адреси лист надсилається не частіше ніж раз на ResendInterval: інакше
+// повертається ErrResendTooSoon і час, через який можна повторити.
+func (s *SubscriptionService) ResendConfirmation(email, city string) (time.Duration, error) {
+	sub, err := s.SubRepo.FindByEmailCity(email, city)
+	if err != nil {
+		log.Printf("ResendConfirmation: subscription email=%s city=%s not found, err=%v", email, city, err)
+		return 0, ErrSubscriptionNotFound
+	}
+	if sub.Verified {
+		return 0, ErrAlreadyVerified
+	}
+
+	last, err := s.SubRepo.LastQueuedAt(email, notifier.KindConfirm)
+	if err != nil {
+		log.Printf("ResendConfirmation: failed to check last confirmation for email=%s, err=%v", email, err)
+		return 0, err
+	}
+	if last != nil {
+		if wait := s.ResendInterval - time.Since(*last); wait > 0 {
+			log.Printf("ResendConfirmation: rate limited for email=%s, retry in %s", email, wait.Round(time.Second))
+			return wait, ErrResendTooSoon
+		}
+	}
+
+	msg := confirmation(&sub)
+	if err := s.SubRepo.RevokeTokens(sub.ID, models.TokenConfirm, msg); err != nil {
+		log.Printf("ResendConfirmation: failed to queue confirmation, err=%v", err)
+		return 0, err
+	}
+	log.Printf("ResendConfirmation: subscription id=%d, confirmation queued as outbox id=%d", sub.ID, msg.ID)
+	return 0, nil
+}
+
+// RequestManageLink ставить в outbox лист із посиланням на керування підписками
+// email. Для адреси без підписок нічого не надсилається, але й помилки немає, щоб
+// запит не розкривав, чи є адреса в сервісі. Лист на адресу надсилається не частіше
+// ніж раз на ResendInterval: інакше повертається ErrResendTooSoon і час до повтору.
+func (s *SubscriptionService) RequestManageLink(email string) (time.Duration, error) {
+	subs, _, err := s.SubRepo.FindByEmail(email, 0, 1)
+	if err != nil {
+		log.Printf("RequestManageLink: failed to fetch subscriptions for email=%s, err=%v", email, err)
+		return 0, err
+	}
+	if len(subs) == 0 {
+		log.Printf("RequestManageLink: no subscriptions for email=%s", email)
+		return 0, nil
+	}
+
+	last, err := s.SubRepo.LastQueuedAt(email, notifier.KindManage)
+	if err != nil {
+		log.Printf("RequestManageLink: failed to check last manage link for email=%s, err=%v", email, err)
+		return 0, err
+	}
+	if last != nil {
+		if wait := s.ResendInterval - time.Since(*last); wait > 0 {
+			log.Printf("RequestManageLink: rate limited for email=%s, retry in %s", email, wait.Round(time.Second))
+			return wait, ErrResendTooSoon
+		}
+	}
+
+	// токен прив'язується до однієї з підписок, але дає доступ до всіх підписок адреси
+	sub := &subs[0]
+	msg := newOutboxMessage(sub, notifier.Message{Kind: notifier.KindManage}, nil, time.Now())
+	if err := s.SubRepo.RevokeTokens(sub.ID, models.TokenManage, msg); err != nil {
+		log.Printf("RequestManageLink: failed to queue manage link, err=%v", err)
+		return 0, err
+	}
+	log.Printf("RequestManageLink: email=%s, manage link queued as outbox id=%d", email, msg.ID)
+	return 0, nil
+}
+
+// PurgeUnverified видаляє непідтверджені підписки, токен яких сплив понад
+// UnverifiedGrace тому, звільняючи пару email і місто для нової підписки
+func (s *SubscriptionService) PurgeUnverified(now time.Time) (int64, error) {
+	if s.UnverifiedGrace <= 0 {
+		return 0, nil
+	}
+	n, err := s.SubRepo.DeleteUnverified(now.Add(-s.UnverifiedGrace))
+	if err != nil {
+		log.Printf("PurgeUnverified error: %v", err)
+		return 0, err
+	}
+	if n > 0 {
+		log.Printf("PurgeUnverified removed %d unverified subscriptions", n)
+	}
+	return n, nil
+}
+
+// confirmation готує лист підтвердження для outbox без тіла: посилання з токеном
+// рендерить TokenMessage під час надсилання, тож відкритий токен не потрапляє в БД
+func confirmation(sub *models.Subscription) *models.OutboxMessage {
+	return newOutboxMessage(sub, notifier.Message{Kind: notifier.KindConfirm}, nil, time.Now())
+}
+
+// TokenMessage видає підписці новий токен і рендерить лист kind із посиланням на нього;
+// попередній токен того самого призначення перестає діяти. Його викликає диспетчер
+// outbox у момент надсилання, тож токен є лише в самому листі. Процес, що втратив
+// оренду з ctx, токен не замінює (repository.ErrFenced).
+func (s *SubscriptionService) TokenMessage(ctx context.Context, sub *models.Subscription, kind string) (notifier.Message, error) {
+	var r templates.Rendered
+	switch kind {
+	case notifier.KindConfirm:
+		token, tok, err := s.issue(ctx, sub.ID, models.TokenConfirm)
+		if err != nil {
+			return notifier.Message{}, err
+		}
+		r, err = s.Tmpl.Render(sub.Language, templates.Confirm, templates.ConfirmData{
+			City:       sub.City,
+			Condition:  sub.Condition,
+			ConfirmURL: fmt.Sprintf("%s/subscriptions/confirm?token=%s", s.Links.BaseURL, token),
+			ExpiresAt:  *tok.ExpiresAt,
+		})
+		if err != nil {
+			return notifier.Message{}, fmt.Errorf("render confirmation: %w", err)
+		}
+	case notifier.KindManage:
+		token, tok, err := s.issue(ctx, sub.ID, models.TokenManage)
+		if err != nil {
+			return notifier.Message{}, err
+		}
+		r, err = s.Tmpl.Render(sub.Language, templates.Manage, templates.ManageData{
+			ManageURL: fmt.Sprintf("%s/subscriptions?token=%s", s.Links.BaseURL, token),
+			ExpiresAt: *tok.ExpiresAt,
+		})
+		if err != nil {
+			return notifier.Message{}, fmt.Errorf("render manage link: %w", err)
+		}
+	default:
+		return notifier.Message{}, fmt.Errorf("no token message of kind %q", kind)
+	}
+	return notifier.Message{Kind: kind, Subject: r.Subject, Body: r.Text, HTML: r.HTML}, nil
+}
+
+// Confirm підтверджує підписку за токеном із листа; токен одноразовий
 func (s *SubscriptionService) Confirm(token string) (*models.Subscription, error) {
-	log.Printf("Confirm: start confirm for token=%s", token)
+	sub, err := s.redeem(models.TokenConfirm, token, func(sub *models.Subscription) {
+		sub.Verified = true
+	})
+	if err != nil {
+		log.Printf("Confirm: failed to confirm subscription, err=%v", err)
+		return nil, err
+	}
+	log.Printf("Confirm: subscription confirmed for email=%s", sub.Email)
+	return sub, nil
+}
+
+// Unsubscribe вимикає підписку за підписаним токеном із листа.
+// Повторний виклик для вже відписаної підписки не є помилкою.
+func (s *SubscriptionService) Unsubscribe(token string) (*models.Subscription, error) {
+	id, err := s.Links.Verify(LinkUnsubscribe, token)
+	if err != nil {
+		log.Printf("Unsubscribe: invalid token, err=%v", err)
+		if errors.Is(err, signedlink.ErrExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrTokenNotFound
+	}
 
-	sub, err := s.SubRepo.FindByToken(token)
+	sub, err := s.SubRepo.FindByID(id)
 	if err != nil {
-		log.Printf("Confirm: token not found err=%v", err)
+		log.Printf("Unsubscribe: subscription id=%d not found, err=%v", id, err)
+		return nil, ErrTokenNotFound
+	}
+	if sub.UnsubscribedAt != nil {
+		return &sub, nil
+	}
+
+	now := time.Now()
+	sub.UnsubscribedAt = &now
+	if err := s.SubRepo.UpdateColumns(sub.ID, map[string]interface{}{"unsubscribed_at": now}); err != nil {
+		log.Printf("Unsubscribe: failed to update subscription, err=%v", err)
 		return nil, err
 	}
-	if sub.TokenExpiresAt == nil || time.Now().After(*sub.TokenExpiresAt) {
-		log.Printf("Confirm: token expired for email=%s", sub.Email)
-		return nil, fmt.Errorf("token expired")
+	log.Printf("Unsubscribe: subscription id=%d disabled for email=%s", sub.ID, sub.Email)
+	return &sub, nil
+}
+
+// UnsubscribeAll вимикає всі підписки адреси за підписаним посиланням із дайджесту
+// (заголовок List-Unsubscribe) і повертає, скільки їх було вимкнено
+func (s *SubscriptionService) UnsubscribeAll(token string) (int64, error) {
+	id, err := s.Links.Verify(LinkUnsubscribeAll, token)
+	if err != nil {
+		log.Printf("UnsubscribeAll: invalid token, err=%v", err)
+		if errors.Is(err, signedlink.ErrExpired) {
+			return 0, ErrTokenExpired
+		}
+		return 0, ErrTokenNotFound
+	}
+
+	sub, err := s.SubRepo.FindByID(id)
+	if err != nil {
+		log.Printf("UnsubscribeAll: subscription id=%d not found, err=%v", id, err)
+		return 0, ErrTokenNotFound
+	}
+	n, err := s.SubRepo.UnsubscribeEmail(sub.Email, time.Now())
+	if err != nil {
+		log.Printf("UnsubscribeAll: failed to update subscriptions, err=%v", err)
+		return 0, err
 	}
+	log.Printf("UnsubscribeAll: %d subscriptions disabled for email=%s", n, sub.Email)
+	return n, nil
+}
+
+// MaxSnooze — найдовша пауза підписки
+const MaxSnooze = 30 * 24 * time.Hour
 
-	sub.Verified = true
-	sub.VerificationToken = ""
-	sub.TokenExpiresAt = nil
+// SnoozeLinkDuration — на скільки призупиняє сповіщення посилання з листа
+const SnoozeLinkDuration = 24 * time.Hour
 
-	//  Оновлюємо статус у репозиторії
-	if err := s.SubRepo.UpdateSubscription(&sub); err != nil {
-		log.Printf("Confirm: failed to update subscription, err=%v", err)
+// Snooze призупиняє сповіщення підписки owner на d; d == 0 знімає паузу.
+// Коли пауза минає, планувальник сам знову обчислює підписку.
+func (s *SubscriptionService) Snooze(owner string, id uint, d time.Duration) (*models.Subscription, error) {
+	if d < 0 || d > MaxSnooze {
+		return nil, i18n.Wrap(ErrInvalidSnooze, i18n.MsgInvalidSnooze, int(MaxSnooze/(24*time.Hour)))
+	}
+	sub, err := s.owned("Snooze", owner, id)
+	if err != nil {
 		return nil, err
 	}
-	log.Printf("Confirm: subscription confirmed for email=%s", sub.Email)
+	if d == 0 {
+		sub.PausedUntil = nil
+	} else {
+		until := time.Now().Add(d)
+		sub.PausedUntil = &until
+	}
+	return s.savePause(&sub)
+}
+
+// SnoozeByToken призупиняє підписку на SnoozeLinkDuration за підписаним
+// посиланням із листа. Довшу паузу, задану раніше, посилання не скорочує.
+func (s *SubscriptionService) SnoozeByToken(token string) (*models.Subscription, error) {
+	id, err := s.Links.Verify(LinkSnooze, token)
+	if err != nil {
+		log.Printf("SnoozeByToken: invalid token, err=%v", err)
+		if errors.Is(err, signedlink.ErrExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrTokenNotFound
+	}
+
+	sub, err := s.SubRepo.FindByID(id)
+	if err != nil {
+		log.Printf("SnoozeByToken: subscription id=%d not found, err=%v", id, err)
+		return nil, ErrTokenNotFound
+	}
+	until := time.Now().Add(SnoozeLinkDuration)
+	if sub.PausedUntil != nil && sub.PausedUntil.After(until) {
+		return &sub, nil
+	}
+	sub.PausedUntil = &until
+	return s.savePause(&sub)
+}
+
+// savePause зберігає паузу підписки. next_due_at скидається: поки триває пауза, його
+// переносить на її кінець наступний такт, а відновлену підписку він одразу обчислить.
+func (s *SubscriptionService) savePause(sub *models.Subscription) (*models.Subscription, error) {
+	sub.NextDueAt = nil
+	updates := map[string]interface{}{"paused_until": sub.PausedUntil, "next_due_at": nil}
+	if err := s.SubRepo.UpdateColumns(sub.ID, updates); err != nil {
+		log.Printf("Snooze: failed to update subscription id=%d, err=%v", sub.ID, err)
+		return nil, err
+	}
+	if sub.PausedUntil == nil {
+		log.Printf("Snooze: subscription id=%d resumed", sub.ID)
+	} else {
+		log.Printf("Snooze: subscription id=%d paused until %s", sub.ID, sub.PausedUntil.Format(time.RFC3339))
+	}
+	return sub, nil
+}
+
+// SubscriptionPatch — часткове оновлення підписки; nil-поля не змінюються
+type SubscriptionPatch struct {
+	City        *string  `json:"city"         binding:"omitnil,min=1"`
+	Condition   *string  `json:"condition"    binding:"omitnil,condition"`
+	Hysteresis  *float64 `json:"hysteresis"   binding:"omitnil,gte=0"`
+	NotifyClear *bool    `json:"notify_clear"`
+	Channel     *string  `json:"channel"      binding:"omitnil,oneof=email webhook slack"`
+	WebhookURL  *string  `json:"webhook_url"  binding:"omitnil,url"`
+	Language    *string  `json:"language"     binding:"omitnil,oneof=en uk"`
+	Timezone    *string  `json:"timezone"`
+	QuietStart  *string  `json:"quiet_start"`
+	QuietEnd    *string  `json:"quiet_end"`
+
+	IntervalMinutes *int `json:"interval_minutes" binding:"omitnil,min=1,max=10080"`
+	CooldownMinutes *int `json:"cooldown_minutes" binding:"omitnil,gte=0,max=10080"`
+}
+
+// DefaultIntervalMinutes — як часто обчислюється підписка, якщо інтервал не задано
+const DefaultIntervalMinutes = 24 * 60
+
+// SubscriptionPage — сторінка результатів списку підписок
+type SubscriptionPage struct {
+	Items   []models.Subscription `json:"items"`
+	Total   int64                 `json:"total"`
+	Page    int                   `json:"page"`
+	PerPage int                   `json:"per_page"`
+}
+
+// MaxPerPage обмежує розмір сторінки списку підписок
+const MaxPerPage = 100
+
+// Get повертає підписку owner за id
+func (s *SubscriptionService) Get(owner string, id uint) (*models.Subscription, error) {
+	sub, err := s.owned("Get", owner, id)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// owned повертає підписку id, якщо вона належить адресі owner. Чужа підписка
+// не відрізняється від відсутньої, щоб за id не можна було перебирати чужі.
+func (s *SubscriptionService) owned(op, owner string, id uint) (models.Subscription, error) {
+	sub, err := s.SubRepo.FindByID(id)
+	if err != nil {
+		log.Printf("%s: subscription id=%d not found, err=%v", op, id, err)
+		return models.Subscription{}, ErrSubscriptionNotFound
+	}
+	if !strings.EqualFold(sub.Email, owner) {
+		log.Printf("%s: subscription id=%d does not belong to email=%s", op, id, owner)
+		return models.Subscription{}, ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+// List повертає сторінку підписок email. page рахується з 1.
+func (s *SubscriptionService) List(email string, page, perPage int) (SubscriptionPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	subs, total, err := s.SubRepo.FindByEmail(email, (page-1)*perPage, perPage)
+	if err != nil {
+		log.Printf("List: failed to fetch subscriptions for email=%s, err=%v", email, err)
+		return SubscriptionPage{}, err
+	}
+	if subs == nil {
+		subs = []models.Subscription{}
+	}
+	return SubscriptionPage{Items: subs, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+// Update застосовує часткові зміни до підписки owner. Нове місто проходить ту саму перевірку, що й у Create;
+// зміна міста чи умови скидає стан сповіщення, бо старий стан до нової умови не стосується,
+// а зміна інтервалу робить підписку готовою до обчислення на найближчому такті.
+func (s *SubscriptionService) Update(owner string, id uint, p SubscriptionPatch) (*models.Subscription, error) {
+	sub, err := s.owned("Update", owner, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// у БД пишуться лише змінені колонки, щоб не затерти стан, який тим часом записав планувальник
+	updates := map[string]interface{}{}
+	reset := false
+	if p.City != nil && *p.City != sub.City {
+		if err := s.checkCity(*p.City); err != nil {
+			return nil, err
+		}
+		sub.City = *p.City
+		updates["city"] = sub.City
+		reset = true
+	}
+	if p.Condition != nil && *p.Condition != sub.Condition {
+		sub.Condition = *p.Condition
+		updates["condition"] = sub.Condition
+		reset = true
+	}
+	if p.Hysteresis != nil {
+		sub.Hysteresis = *p.Hysteresis
+		updates["hysteresis"] = sub.Hysteresis
+	}
+	if p.NotifyClear != nil {
+		sub.NotifyClear = *p.NotifyClear
+		updates["notify_clear"] = sub.NotifyClear
+	}
+	if p.Channel != nil {
+		sub.Channel = *p.Channel
+		updates["channel"] = sub.Channel
+	}
+	if p.WebhookURL != nil {
+		sub.WebhookURL = *p.WebhookURL
+		updates["webhook_url"] = sub.WebhookURL
+	}
+	if p.Language != nil {
+		sub.Language = *p.Language
+		updates["language"] = sub.Language
+	}
+	if p.Timezone != nil {
+		sub.Timezone = *p.Timezone
+	}
+	if p.QuietStart != nil {
+		sub.QuietStart = *p.QuietStart
+		updates["quiet_start"] = sub.QuietStart
+	}
+	if p.QuietEnd != nil {
+		sub.QuietEnd = *p.QuietEnd
+		updates["quiet_end"] = sub.QuietEnd
+	}
+	if p.IntervalMinutes != nil && *p.IntervalMinutes != sub.IntervalMinutes {
+		// новий інтервал діє одразу: підписку обчислить найближчий такт
+		sub.IntervalMinutes = *p.IntervalMinutes
+		sub.NextDueAt = nil
+		updates["interval_minutes"] = sub.IntervalMinutes
+		updates["next_due_at"] = nil
+	}
+	if p.CooldownMinutes != nil {
+		sub.CooldownMinutes = *p.CooldownMinutes
+		updates["cooldown_minutes"] = sub.CooldownMinutes
+	}
+	if err := checkChannel(&sub); err != nil {
+		return nil, err
+	}
+	if err := checkSchedule(&sub); err != nil {
+		return nil, err
+	}
+	if p.Timezone != nil {
+		// checkSchedule замінює порожній пояс на UTC
+		updates["timezone"] = sub.Timezone
+	}
+	if reset {
+		sub.AlertState = models.AlertStateCleared
+		sub.StateChangedAt = nil
+		sub.LastValue = nil
+		updates["alert_state"] = sub.AlertState
+		updates["state_changed_at"] = nil
+		updates["last_value"] = nil
+	}
+	if len(updates) == 0 {
+		return &sub, nil
+	}
+
+	if err := s.SubRepo.UpdateColumns(id, updates); err != nil {
+		log.Printf("Update: failed to update subscription id=%d, err=%v", id, err)
+		return nil, err
+	}
+	log.Printf("Update: subscription id=%d updated", id)
 	return &sub, nil
 }
 
+// RotateWebhookSecret видає підписці owner новий ключ підпису вебхуків і повертає
+// його; попередній ключ перестає діяти
+func (s *SubscriptionService) RotateWebhookSecret(owner string, id uint) (string, error) {
+	sub, err := s.owned("RotateWebhookSecret", owner, id)
+	if err != nil {
+		return "", err
+	}
+	secret, err := tokens.Secret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.SubRepo.UpdateColumns(sub.ID, map[string]interface{}{"webhook_secret": secret}); err != nil {
+		log.Printf("RotateWebhookSecret: failed to update subscription id=%d, err=%v", id, err)
+		return "", err
+	}
+	log.Printf("RotateWebhookSecret: subscription id=%d got a new webhook secret", id)
+	return secret, nil
+}
+
+// Delete видаляє підписку owner
+func (s *SubscriptionService) Delete(owner string, id uint) error {
+	if _, err := s.owned("Delete", owner, id); err != nil {
+		return err
+	}
+	ok, err := s.SubRepo.Delete(id)
+	if err != nil {
+		log.Printf("Delete: failed to delete subscription id=%d, err=%v", id, err)
+		return err
+	}
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	log.Printf("Delete: subscription id=%d deleted", id)
+	return nil
+}
+
+// checkCity перевіряє, що для міста є дані погоди
+func (s *SubscriptionService) checkCity(city string) error {
+	if _, err := s.WeatherRepo.GetByCity(city); err != nil {
+		log.Printf("checkCity: city not found=%s, err=%v", city, err)
+		return i18n.Wrap(ErrCityNotFound, i18n.MsgCityNotFoundNamed, city)
+	}
+	return nil
+}
+
+// checkChannel перевіряє, що для вебхук-каналів задано адресу http(s); порожній
+// канал — email. Внутрішні адреси відсікає вже клієнт вебхуків під час з'єднання.
+func checkChannel(sub *models.Subscription) error {
+	switch sub.Channel {
+	case "":
+		sub.Channel = models.ChannelEmail
+	case models.ChannelWebhook, models.ChannelSlack:
+		if sub.WebhookURL == "" {
+			return i18n.Wrap(ErrInvalidChannel, i18n.MsgWebhookURLRequired, sub.Channel)
+		}
+	}
+	if sub.WebhookURL != "" {
+		u, err := url.Parse(sub.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return i18n.Wrap(ErrInvalidChannel, i18n.MsgWebhookURLScheme)
+		}
+	}
+	return nil
+}
+
 // ListVerified повертає всі підтверджені підписки
 func (s *SubscriptionService) ListVerified() ([]models.Subscription, error) {
 	log.Printf("ListVerified: fetching all verified subscriptions")
@@ -2,55 +2,257 @@
 package services_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"myapp/pkg/config"
 	"myapp/pkg/models"
+	"myapp/pkg/notifier"
+	"myapp/pkg/repository"
 	"myapp/pkg/services"
-	"myapp/pkg/utils"
+	"myapp/pkg/tokens"
 )
 
 // mockSubRepo збирає аргументи викликів і повертає помилки за налаштуванням
 type mockSubRepo struct {
 	lastCreated  *models.Subscription
 	createErr    error
-	findByToken  models.Subscription
-	findErr      error
+	tokens       map[string]*models.SubscriptionToken // за хешем
+	lastToken    *models.SubscriptionToken
 	lastUpdated  *models.Subscription
+	lastUpdates  map[string]interface{}
+	lastFence    *repository.Fence
 	updateErr    error
 	verifiedList []models.Subscription
 	listErr      error
+	cities       []string
+	byID         map[uint]models.Subscription
+	queued       []*models.OutboxMessage
+	saves        int
+	// lastConfirm — що повертає LastQueuedAt; purgedBefore — аргумент DeleteUnverified
+	lastConfirm  *time.Time
+	purgedBefore time.Time
 }
 
-func (m *mockSubRepo) Create(sub *models.Subscription) error {
-	// Зберігаємо лише основні поля
-	m.lastCreated = &models.Subscription{
-		Email:             sub.Email,
-		City:              sub.City,
-		VerificationToken: sub.VerificationToken,
-		TokenExpiresAt:    sub.TokenExpiresAt,
-		Verified:          sub.Verified,
+// record імітує запис в outbox у тій самій транзакції, що й зміна підписки
+func (m *mockSubRepo) record(sub *models.Subscription, msg *models.OutboxMessage, err error) error {
+	if err != nil || msg == nil {
+		return err
 	}
-	return m.createErr
+	msg.ID = uint(len(m.queued) + 1)
+	msg.SubscriptionID = sub.ID
+	m.queued = append(m.queued, msg)
+	return nil
 }
-func (m *mockSubRepo) FindByToken(token string) (models.Subscription, error) {
-	return m.findByToken, m.findErr
+
+func (m *mockSubRepo) lastQueued() *models.OutboxMessage {
+	if len(m.queued) == 0 {
+		return &models.OutboxMessage{}
+	}
+	return m.queued[len(m.queued)-1]
 }
-func (m *mockSubRepo) UpdateSubscription(sub *models.Subscription) error {
-	m.lastUpdated = &models.Subscription{
-		Email:             sub.Email,
-		City:              sub.City,
-		Verified:          sub.Verified,
-		VerificationToken: sub.VerificationToken,
-		TokenExpiresAt:    sub.TokenExpiresAt,
+
+// saveToken зберігає копію токена, як це робить БД
+func (m *mockSubRepo) saveToken(tok *models.SubscriptionToken) {
+	if m.tokens == nil {
+		m.tokens = map[string]*models.SubscriptionToken{}
+	}
+	tok.ID = uint(len(m.tokens) + 1)
+	cp := *tok
+	m.tokens[tok.Hash] = &cp
+	m.lastToken = &cp
+}
+
+func (m *mockSubRepo) Create(sub *models.Subscription, msg *models.OutboxMessage) error {
+	cp := *sub
+	m.lastCreated = &cp
+	return m.record(sub, msg, m.createErr)
+}
+
+// UpdateColumns застосовує updates до збереженої в byID підписки так, як їх
+// побачила б БД: назви колонок збігаються з json-тегами моделі
+func (m *mockSubRepo) UpdateColumns(id uint, updates map[string]interface{}) error {
+	m.lastUpdates = updates
+	sub := m.byID[id]
+	sub.ID = id
+	raw, err := json.Marshal(updates)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &sub); err != nil {
+		return err
+	}
+	if secret, ok := updates["webhook_secret"].(string); ok {
+		sub.WebhookSecret = secret
+	}
+	m.lastUpdated = &sub
+	if m.updateErr == nil && m.byID != nil {
+		m.byID[id] = sub
 	}
 	return m.updateErr
 }
+
+// columns повертає відсортовані через кому колонки останнього UpdateColumns
+func (m *mockSubRepo) columns() string {
+	var cols []string
+	for c := range m.lastUpdates {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	return strings.Join(cols, ",")
+}
+
+func (m *mockSubRepo) FindByID(id uint) (models.Subscription, error) {
+	sub, ok := m.byID[id]
+	if !ok {
+		return models.Subscription{}, errors.New("record not found")
+	}
+	return sub, nil
+}
+func (m *mockSubRepo) FindByIDs(ids []uint) ([]models.Subscription, error) {
+	var out []models.Subscription
+	for _, id := range ids {
+		if sub, err := m.FindByID(id); err == nil {
+			out = append(out, sub)
+			continue
+		}
+		for _, sub := range m.verifiedList {
+			if sub.ID == id {
+				out = append(out, sub)
+			}
+		}
+	}
+	return out, m.listErr
+}
+func (m *mockSubRepo) UnsubscribeEmail(email string, at time.Time) (int64, error) {
+	var n int64
+	for id, sub := range m.byID {
+		if sub.Email == email && sub.UnsubscribedAt == nil {
+			sub.UnsubscribedAt = &at
+			m.byID[id] = sub
+			n++
+		}
+	}
+	return n, m.updateErr
+}
+func (m *mockSubRepo) FindByEmail(email string, offset, limit int) ([]models.Subscription, int64, error) {
+	var all []models.Subscription
+	for _, sub := range m.verifiedList {
+		if sub.Email == email {
+			all = append(all, sub)
+		}
+	}
+	end := offset + limit
+	if end > len(all) {
+		end = len(all)
+	}
+	if offset > end {
+		offset = end
+	}
+	return all[offset:end], int64(len(all)), m.listErr
+}
+func (m *mockSubRepo) Delete(id uint) (bool, error) {
+	_, ok := m.byID[id]
+	delete(m.byID, id)
+	return ok, m.updateErr
+}
 func (m *mockSubRepo) FindAllVerified() ([]models.Subscription, error) {
 	return m.verifiedList, m.listErr
 }
+func (m *mockSubRepo) FindDueVerified(now time.Time) ([]models.Subscription, error) {
+	var out []models.Subscription
+	for _, s := range m.verifiedList {
+		if s.NextDueAt == nil || !s.NextDueAt.After(now) {
+			out = append(out, s)
+		}
+	}
+	return out, m.listErr
+}
+func (m *mockSubRepo) FindVerifiedByCity(city string) ([]models.Subscription, error) {
+	var out []models.Subscription
+	for _, s := range m.verifiedList {
+		if s.City == city {
+			out = append(out, s)
+		}
+	}
+	return out, m.listErr
+}
+
+// SaveAlertState, як і БД, відкидає запис, якщо збережений у byID стан уже не prevState
+func (m *mockSubRepo) SaveAlertState(sub *models.Subscription, prevState string, msg *models.OutboxMessage, fence *repository.Fence) (bool, error) {
+	m.saves++
+	m.lastFence = fence
+	if stored, ok := m.byID[sub.ID]; ok {
+		if stored.AlertState != prevState {
+			return false, m.updateErr
+		}
+		m.byID[sub.ID] = *sub
+	}
+	return m.updateErr == nil, m.record(sub, msg, m.updateErr)
+}
+func (m *mockSubRepo) SubscribedCities() ([]string, error) {
+	return m.cities, m.listErr
+}
+func (m *mockSubRepo) FindByEmailCity(email, city string) (models.Subscription, error) {
+	for _, sub := range m.byID {
+		if sub.Email == email && sub.City == city {
+			return sub, nil
+		}
+	}
+	return models.Subscription{}, errors.New("record not found")
+}
+func (m *mockSubRepo) FindToken(hash string) (*models.SubscriptionToken, error) {
+	if tok, ok := m.tokens[hash]; ok {
+		cp := *tok
+		return &cp, nil
+	}
+	return nil, m.listErr
+}
+func (m *mockSubRepo) ReplaceToken(tok *models.SubscriptionToken, _ *repository.Fence) error {
+	if err := m.RevokeTokens(tok.SubscriptionID, tok.Purpose, nil); err != nil {
+		return err
+	}
+	m.saveToken(tok)
+	return nil
+}
+func (m *mockSubRepo) RevokeTokens(subID uint, purpose string, msg *models.OutboxMessage) error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	for hash, old := range m.tokens {
+		if old.SubscriptionID == subID && old.Purpose == purpose {
+			delete(m.tokens, hash)
+		}
+	}
+	return m.record(&models.Subscription{ID: subID}, msg, nil)
+}
+func (m *mockSubRepo) RedeemToken(tok *models.SubscriptionToken, sub *models.Subscription) (bool, error) {
+	if _, ok := m.tokens[tok.Hash]; !ok {
+		return false, nil
+	}
+	if sub != nil {
+		if m.updateErr != nil {
+			return false, m.updateErr
+		}
+		cp := *sub
+		m.lastUpdated = &cp
+	}
+	delete(m.tokens, tok.Hash)
+	return true, nil
+}
+func (m *mockSubRepo) LastQueuedAt(email, kind string) (*time.Time, error) {
+	return m.lastConfirm, m.listErr
+}
+func (m *mockSubRepo) DeleteUnverified(t time.Time) (int64, error) {
+	m.purgedBefore = t
+	return 2, m.updateErr
+}
 
 // mockWeatherRepo перевіряє наявність міста
 type mockWeatherRepo struct {
@@ -70,21 +272,24 @@ func (m *mockWeatherRepo) UpdateWeather(city string, updates map[string]interfac
 }
 
 func TestSubscriptionService_Create(t *testing.T) {
-	orig := utils.SendEmail
-	defer func() { utils.SendEmail = orig }()
-
 	cases := []struct {
 		name      string
 		exists    bool
 		createErr error
-		emailErr  error
 		wantErr   bool
 		check     func(t *testing.T, m *mockSubRepo)
 	}{
-		{"CityNotFound", false, nil, nil, true, nil},
-		{"RepoError", true, errors.New("db err"), nil, true, nil},
-		{"EmailError", true, nil, errors.New("send err"), true, nil},
-		{"Success", true, nil, nil, false, func(t *testing.T, m *mockSubRepo) {
+		{"CityNotFound", false, nil, true, func(t *testing.T, m *mockSubRepo) {
+			if m.lastCreated != nil {
+				t.Error("Create must not be called for unknown city")
+			}
+		}},
+		{"RepoError", true, errors.New("db err"), true, func(t *testing.T, m *mockSubRepo) {
+			if len(m.queued) != 0 {
+				t.Error("confirmation must not be queued when the subscription is not saved")
+			}
+		}},
+		{"Success", true, nil, false, func(t *testing.T, m *mockSubRepo) {
 			sub := m.lastCreated
 			if sub == nil {
 				t.Fatal("Expected Create to be called")
@@ -92,27 +297,48 @@ func TestSubscriptionService_Create(t *testing.T) {
 			if sub.Email != "e@e" || sub.City != "C" {
 				t.Errorf("Unexpected Email/City: %+v", sub)
 			}
-			// Токен має бути 32 hex-символи
-			if match, _ := regexp.MatchString("^[0-9a-f]{32}$", sub.VerificationToken); !match {
-				t.Errorf("Invalid token format: %q", sub.VerificationToken)
+			if sub.Verified {
+				t.Error("Expected Verified to be false")
+			}
+			// Лист підтвердження ставиться в outbox разом із підпискою
+			if len(m.queued) != 1 {
+				t.Fatalf("Expected 1 queued confirmation, got %d", len(m.queued))
+			}
+			msg := m.queued[0]
+			if msg.Kind != notifier.KindConfirm || msg.Status != models.OutboxPending || msg.Body != "" || msg.HTMLBody != "" {
+				t.Errorf("Unexpected confirmation message: %+v", msg)
+			}
+			// токен видається лише під час надсилання листа
+			if len(m.tokens) != 0 {
+				t.Fatalf("no token must be stored before sending, got %v", m.tokens)
+			}
+			svc := services.NewSubscriptionService(m, nil, testLinks, testTmpl, config.Config{})
+			email, err := svc.TokenMessage(context.Background(), &models.Subscription{ID: msg.SubscriptionID, City: "C"}, msg.Kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// У листі — токен із 64 hex-символів, у БД — лише його хеш
+			token := confirmToken(t, email.Body)
+			tok := m.lastToken
+			if tok == nil || tok.Purpose != models.TokenConfirm || tok.Hash != tokens.Hash(token) {
+				t.Fatalf("Expected hashed confirm token, got %+v", tok)
+			}
+			if strings.Contains(email.Body, tok.Hash) {
+				t.Error("Hash must not be sent in the email")
 			}
 			// Термін дії має бути за межами зараз() + [23h,25h]
-			diff := sub.TokenExpiresAt.Sub(time.Now())
+			diff := tok.ExpiresAt.Sub(time.Now())
 			if diff < 23*time.Hour || diff > 25*time.Hour {
-				t.Errorf("Unexpected TokenExpiresAt: %v", sub.TokenExpiresAt)
-			}
-			if sub.Verified {
-				t.Error("Expected Verified to be false")
+				t.Errorf("Unexpected ExpiresAt: %v", tok.ExpiresAt)
 			}
 		}},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			utils.SendEmail = func(_, _, _ string) error { return tc.emailErr }
 			mSub := &mockSubRepo{createErr: tc.createErr}
 			mW := &mockWeatherRepo{exists: tc.exists, err: errors.New("not found")}
-			svc := services.NewSubscriptionService(mSub, mW)
+			svc := services.NewSubscriptionService(mSub, mW, testLinks, testTmpl, config.Config{})
 			sub := &models.Subscription{Email: "e@e", City: "C"}
 
 			err := svc.Create(sub)
@@ -126,6 +352,78 @@ func TestSubscriptionService_Create(t *testing.T) {
 	}
 }
 
+// Тіло POST /subscriptions зв'язується з models.Subscription, тож клієнт може надіслати
+// й службові поля; Create їх відкидає
+func TestSubscriptionService_Create_IgnoresStateFields(t *testing.T) {
+	body := `{"email":"e@e","city":"C","condition":"temp < 0","cooldown_minutes":30,
+		"id":42,"verified":true,"alert_state":"fired","paused_until":"2099-01-01T00:00:00Z",
+		"unsubscribed_at":"2020-01-01T00:00:00Z","last_alert_at":"2099-01-01T00:00:00Z",
+		"last_sent":"2099-01-01T00:00:00Z","last_value":-40,"state_changed_at":"2020-01-01T00:00:00Z",
+		"last_evaluated_at":"2020-01-01T00:00:00Z","next_due_at":"2099-01-01T00:00:00Z"}`
+	var sub models.Subscription
+	if err := json.Unmarshal([]byte(body), &sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.PausedUntil == nil || sub.LastAlertAt == nil {
+		t.Fatal("the body must reach the model for the test to mean anything")
+	}
+
+	mSub := &mockSubRepo{}
+	svc := services.NewSubscriptionService(mSub, &mockWeatherRepo{exists: true}, testLinks, testTmpl, config.Config{})
+	if err := svc.Create(&sub); err != nil {
+		t.Fatal(err)
+	}
+	got := mSub.lastCreated
+	if got.Email != "e@e" || got.City != "C" || got.Condition != "temp < 0" || got.CooldownMinutes != 30 {
+		t.Errorf("settings must be kept, got %+v", got)
+	}
+	if got.ID == 42 || got.Verified || got.AlertState != models.AlertStateCleared || got.PausedUntil != nil ||
+		got.UnsubscribedAt != nil || got.LastAlertAt != nil || got.LastSent != nil || got.LastValue != nil ||
+		got.StateChangedAt != nil || got.LastEvaluatedAt != nil || got.NextDueAt != nil {
+		t.Errorf("state and pause fields must be ignored, got %+v", got)
+	}
+}
+
+// Лист підтвердження рендериться мовою підписки; без мови — мовою за замовчуванням
+func TestSubscriptionService_Create_Language(t *testing.T) {
+	for _, tc := range []struct{ lang, want, subject string }{
+		{"", "en", "Please confirm your subscription"},
+		{"uk", "uk", "Підтвердіть підписку"},
+	} {
+		mSub := &mockSubRepo{}
+		svc := services.NewSubscriptionService(mSub, &mockWeatherRepo{exists: true}, testLinks, testTmpl, config.Config{})
+		sub := &models.Subscription{Email: "e@e", City: "C", Language: tc.lang}
+		if err := svc.Create(sub); err != nil {
+			t.Fatal(err)
+		}
+		if sub.Language != tc.want {
+			t.Errorf("language %q: stored %q, want %q", tc.lang, sub.Language, tc.want)
+		}
+		email, err := svc.TokenMessage(context.Background(), sub, mSub.lastQueued().Kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if email.Subject != tc.subject {
+			t.Errorf("language %q: subject %q, want %q", tc.lang, email.Subject, tc.subject)
+		}
+	}
+}
+
+// confirmToken дістає токен із посилання підтвердження в листі
+func confirmToken(t *testing.T, body string) string {
+	t.Helper()
+	m := regexp.MustCompile(`/subscriptions/confirm\?token=([0-9a-f]{64})\b`).FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("No confirmation token in %q", body)
+	}
+	return m[1]
+}
+
+// putToken кладе в репозиторій хеш токена token
+func putToken(m *mockSubRepo, subID uint, purpose, token string, expires *time.Time) {
+	m.saveToken(&models.SubscriptionToken{SubscriptionID: subID, Purpose: purpose, Hash: tokens.Hash(token), ExpiresAt: expires})
+}
+
 func TestSubscriptionService_Confirm(t *testing.T) {
 	now := time.Now()
 	valid := now.Add(time.Hour)
@@ -133,51 +431,189 @@ func TestSubscriptionService_Confirm(t *testing.T) {
 
 	cases := []struct {
 		name      string
-		repoSub   models.Subscription
-		repoErr   error
+		purpose   string
+		expires   *time.Time
+		token     string
 		updateErr error
-		wantErr   bool
-		check     func(t *testing.T, m *mockSubRepo)
+		wantErr   error
 	}{
-		{"NotFound", models.Subscription{}, errors.New("no token"), nil, true, nil},
-		{"Expired", models.Subscription{TokenExpiresAt: &expired}, nil, nil, true, nil},
-		{"UpdateError", models.Subscription{TokenExpiresAt: &valid}, nil, errors.New("upd err"), true, nil},
-		{"Success", models.Subscription{TokenExpiresAt: &valid}, nil, nil, false, func(t *testing.T, m *mockSubRepo) {
-			upd := m.lastUpdated
-			if upd == nil {
-				t.Fatal("Expected UpdateSubscription to be called")
+		{"NotFound", models.TokenConfirm, &valid, "other", nil, services.ErrTokenNotFound},
+		{"WrongPurpose", models.TokenManage, &valid, "tok", nil, services.ErrTokenNotFound},
+		{"Expired", models.TokenConfirm, &expired, "tok", nil, services.ErrTokenExpired},
+		{"UpdateError", models.TokenConfirm, &valid, "tok", errors.New("upd err"), nil},
+		{"Success", models.TokenConfirm, &valid, "tok", nil, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mSub := &mockSubRepo{byID: map[uint]models.Subscription{5: {ID: 5, Email: "a@b"}}, updateErr: tc.updateErr}
+			putToken(mSub, 5, tc.purpose, "tok", tc.expires)
+			svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+
+			_, err := svc.Confirm(tc.token)
+			if tc.updateErr != nil {
+				if err == nil || len(mSub.tokens) != 1 {
+					t.Fatalf("token must survive a failed update, got %v", err)
+				}
+				return
 			}
-			if !upd.Verified {
-				t.Error("Expected Verified=true")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want err %v, got %v", tc.wantErr, err)
 			}
-			if upd.VerificationToken != "" {
-				t.Error("Expected VerificationToken cleared")
+			if err != nil {
+				return
 			}
-			if upd.TokenExpiresAt != nil {
-				t.Error("Expected TokenExpiresAt cleared")
+			if upd := mSub.lastUpdated; upd == nil || !upd.Verified {
+				t.Fatalf("Expected Verified=true, got %+v", upd)
 			}
-		}},
+			// токен одноразовий
+			if len(mSub.tokens) != 0 {
+				t.Error("Expected token to be deleted")
+			}
+			if _, err := svc.Confirm(tc.token); !errors.Is(err, services.ErrTokenNotFound) {
+				t.Errorf("second confirm must fail, got %v", err)
+			}
+		})
+	}
+}
+
+// Токени кожного призначення мають власний строк дії й не підходять до інших дій
+func TestSubscriptionService_TokenPurposes(t *testing.T) {
+	mSub := &mockSubRepo{byID: map[uint]models.Subscription{5: {ID: 5, Email: "a@b"}}}
+	svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+	sub := mSub.byID[5]
+
+	issued := map[string]string{}
+	for _, tc := range []struct {
+		kind, purpose string
+		ttl           time.Duration
+	}{
+		{notifier.KindConfirm, models.TokenConfirm, 24 * time.Hour},
+		{notifier.KindManage, models.TokenManage, time.Hour},
+	} {
+		m, err := svc.TokenMessage(context.Background(), &sub, tc.kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := regexp.MustCompile(`token=(\w+)`).FindStringSubmatch(m.Body)
+		if match == nil {
+			t.Fatalf("%s: link not found in %q", tc.kind, m.Body)
+		}
+		tok := mSub.tokens[tokens.Hash(match[1])]
+		switch {
+		case tok == nil || tok.Purpose != tc.purpose:
+			t.Fatalf("%s: want a %s token stored by hash, got %+v", tc.kind, tc.purpose, tok)
+		case time.Until(*tok.ExpiresAt) > tc.ttl || time.Until(*tok.ExpiresAt) < tc.ttl-time.Minute:
+			t.Errorf("%s: want expiry in %s, got %v", tc.purpose, tc.ttl, tok.ExpiresAt)
+		}
+		issued[tc.purpose] = match[1]
+	}
+
+	if _, err := svc.Authorize(issued[models.TokenConfirm]); !errors.Is(err, services.ErrTokenNotFound) {
+		t.Errorf("confirm token must not authorize, got %v", err)
+	}
+	if _, err := svc.Confirm(issued[models.TokenManage]); !errors.Is(err, services.ErrTokenNotFound) {
+		t.Errorf("manage token must not confirm, got %v", err)
+	}
+	if got, err := svc.Confirm(issued[models.TokenConfirm]); err != nil || got.ID != 5 {
+		t.Errorf("confirm: want subscription 5, got %+v, %v", got, err)
+	}
+	if email, err := svc.Authorize(issued[models.TokenManage]); err != nil || email != "a@b" {
+		t.Errorf("manage: want a@b, got %q, %v", email, err)
+	}
+
+	if _, err := svc.TokenMessage(context.Background(), &sub, notifier.KindAlert); err == nil {
+		t.Error("alerts carry no stored token and must be rejected")
+	}
+}
+
+func TestSubscriptionService_ResendConfirmation(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	long := time.Now().Add(-time.Hour)
+	pending := models.Subscription{ID: 3, Email: "a@b", City: "Kyiv"}
+
+	cases := []struct {
+		name     string
+		sub      models.Subscription
+		last     *time.Time
+		wantErr  error
+		wantWait bool
+	}{
+		{"Success", pending, &long, nil, false},
+		{"NeverSent", pending, nil, nil, false},
+		{"TooSoon", pending, &recent, services.ErrResendTooSoon, true},
+		{"AlreadyVerified", models.Subscription{ID: 3, Email: "a@b", City: "Kyiv", Verified: true}, nil, services.ErrAlreadyVerified, false},
+		{"UnknownCity", models.Subscription{ID: 3, Email: "a@b", City: "Lviv"}, nil, services.ErrSubscriptionNotFound, false},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			mSub := &mockSubRepo{findByToken: tc.repoSub, findErr: tc.repoErr, updateErr: tc.updateErr}
-			svc := services.NewSubscriptionService(mSub, nil)
-			_, err := svc.Confirm("tok")
-			if (err != nil) != tc.wantErr {
-				t.Fatalf("wantErr=%v, got %v", tc.wantErr, err)
+			mSub := &mockSubRepo{byID: map[uint]models.Subscription{3: tc.sub}, lastConfirm: tc.last}
+			putToken(mSub, 3, models.TokenConfirm, "old-token", &expired)
+			svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{ResendInterval: 5 * time.Minute})
+
+			wait, err := svc.ResendConfirmation("a@b", "Kyiv")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want err %v, got %v", tc.wantErr, err)
 			}
-			if tc.check != nil {
-				tc.check(t, mSub)
+			if (wait > 0) != tc.wantWait || wait > 4*time.Minute+time.Second {
+				t.Errorf("unexpected retry delay %s", wait)
+			}
+			if err != nil {
+				if len(mSub.queued) != 0 || len(mSub.tokens) != 1 {
+					t.Error("nothing must be queued or saved on error")
+				}
+				return
+			}
+
+			msg := mSub.lastQueued()
+			if msg.Kind != notifier.KindConfirm || msg.SubscriptionID != 3 {
+				t.Errorf("unexpected confirmation: %+v", msg)
+			}
+			// старе посилання перестає діяти одразу, а новий токен видається під час надсилання
+			if len(mSub.tokens) != 0 {
+				t.Fatalf("old token must be revoked, got %v", mSub.tokens)
+			}
+			email, err := svc.TokenMessage(context.Background(), &tc.sub, msg.Kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tok, ok := mSub.tokens[tokens.Hash(confirmToken(t, email.Body))]
+			if !ok || len(mSub.tokens) != 1 || tok.SubscriptionID != 3 {
+				t.Fatalf("token must be rotated, got %v", mSub.tokens)
+			}
+			if tok.ExpiresAt == nil || !tok.ExpiresAt.After(time.Now().Add(23*time.Hour)) {
+				t.Errorf("token expiry must be renewed, got %v", tok.ExpiresAt)
 			}
 		})
 	}
 }
 
+func TestSubscriptionService_PurgeUnverified(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	mSub := &mockSubRepo{}
+	svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{UnverifiedGrace: 48 * time.Hour})
+	if n, err := svc.PurgeUnverified(now); err != nil || n != 2 {
+		t.Fatalf("want 2 purged, got %d, %v", n, err)
+	}
+	if want := now.Add(-48 * time.Hour); !mSub.purgedBefore.Equal(want) {
+		t.Errorf("want cutoff %v, got %v", want, mSub.purgedBefore)
+	}
+
+	// нульовий період вимикає очищення
+	mSub = &mockSubRepo{}
+	svc = services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+	if n, err := svc.PurgeUnverified(now); err != nil || n != 0 || !mSub.purgedBefore.IsZero() {
+		t.Errorf("purge must be disabled, got %d, %v", n, err)
+	}
+}
+
 func TestSubscriptionService_ListVerified(t *testing.T) {
 	expected := []models.Subscription{{Email: "a"}, {Email: "b"}}
 	mSub := &mockSubRepo{verifiedList: expected}
-	svc := services.NewSubscriptionService(mSub, nil)
+	svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
 
 	out, err := svc.ListVerified()
 	if err != nil {
@@ -187,3 +623,290 @@ func TestSubscriptionService_ListVerified(t *testing.T) {
 		t.Fatalf("expected %d, got %d", len(expected), len(out))
 	}
 }
+
+func TestSubscriptionService_Unsubscribe(t *testing.T) {
+	at := time.Now().Add(-time.Hour)
+	valid := testLinks.Token(services.LinkUnsubscribe, 7, time.Hour)
+	wrongPurpose := testLinks.Token("snooze", 7, time.Hour)
+
+	cases := []struct {
+		name    string
+		token   string
+		sub     models.Subscription
+		wantErr error
+		updated bool
+	}{
+		{"Success", valid, models.Subscription{ID: 7}, nil, true},
+		{"AlreadyUnsubscribed", valid, models.Subscription{ID: 7, UnsubscribedAt: &at}, nil, false},
+		{"Tampered", valid + "x", models.Subscription{ID: 7}, services.ErrTokenNotFound, false},
+		{"WrongPurpose", wrongPurpose, models.Subscription{ID: 7}, services.ErrTokenNotFound, false},
+		{"UnknownSubscription", valid, models.Subscription{ID: 8}, services.ErrTokenNotFound, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mSub := &mockSubRepo{byID: map[uint]models.Subscription{tc.sub.ID: tc.sub}}
+			svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+
+			sub, err := svc.Unsubscribe(tc.token)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want err %v, got %v", tc.wantErr, err)
+			}
+			if (mSub.lastUpdated != nil) != tc.updated {
+				t.Fatalf("want update=%v, got %+v", tc.updated, mSub.lastUpdated)
+			}
+			if err == nil && sub.UnsubscribedAt == nil {
+				t.Error("expected UnsubscribedAt to be set")
+			}
+			if tc.updated && mSub.columns() != "unsubscribed_at" {
+				t.Errorf("want only unsubscribed_at written, got %q", mSub.columns())
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_List(t *testing.T) {
+	var subs []models.Subscription
+	for i := 1; i <= 5; i++ {
+		subs = append(subs, models.Subscription{ID: uint(i), Email: "a@b"})
+	}
+	subs = append(subs, models.Subscription{ID: 6, Email: "other@b"})
+	svc := services.NewSubscriptionService(&mockSubRepo{verifiedList: subs}, nil, testLinks, testTmpl, config.Config{})
+
+	page, err := svc.List("a@b", 2, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.Total != 5 || len(page.Items) != 2 || page.Items[0].ID != 3 {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	page, _ = svc.List("a@b", 4, 2)
+	if page.Items == nil || len(page.Items) != 0 {
+		t.Errorf("past the end must be an empty list, got %+v", page.Items)
+	}
+}
+
+func TestSubscriptionService_Update(t *testing.T) {
+	str := func(s string) *string { return &s }
+	changed := time.Now()
+	stored := models.Subscription{
+		ID: 1, Email: "a@b", City: "Kyiv", Condition: "temp < 0",
+		AlertState: models.AlertStateFired, StateChangedAt: &changed,
+	}
+
+	cases := []struct {
+		name      string
+		patch     services.SubscriptionPatch
+		cityFound bool
+		wantErr   error
+		wantState string
+		// колонки, які Update записує; решту міг тим часом змінити планувальник
+		columns string
+	}{
+		{"HysteresisKeepsState", services.SubscriptionPatch{Hysteresis: func() *float64 { v := 1.5; return &v }()}, true, nil, models.AlertStateFired, "hysteresis"},
+		{"ConditionResetsState", services.SubscriptionPatch{Condition: str("temp < -5")}, true, nil, models.AlertStateCleared, "alert_state,condition,last_value,state_changed_at"},
+		{"SameConditionKeepsState", services.SubscriptionPatch{Condition: str("temp < 0")}, true, nil, models.AlertStateFired, ""},
+		{"CityResetsState", services.SubscriptionPatch{City: str("Lviv")}, true, nil, models.AlertStateCleared, "alert_state,city,last_value,state_changed_at"},
+		{"UnknownCity", services.SubscriptionPatch{City: str("Atlantis")}, false, services.ErrCityNotFound, "", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mSub := &mockSubRepo{byID: map[uint]models.Subscription{1: stored}}
+			mW := &mockWeatherRepo{exists: tc.cityFound, err: errors.New("not found")}
+			svc := services.NewSubscriptionService(mSub, mW, testLinks, testTmpl, config.Config{})
+
+			sub, err := svc.Update("a@b", 1, tc.patch)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want err %v, got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				if mSub.lastUpdated != nil {
+					t.Error("rejected update must not be saved")
+				}
+				return
+			}
+			if sub.AlertState != tc.wantState || mSub.byID[1].AlertState != tc.wantState {
+				t.Errorf("want state %q, got %q", tc.wantState, sub.AlertState)
+			}
+			if got := mSub.columns(); got != tc.columns {
+				t.Errorf("want columns %q written, got %q", tc.columns, got)
+			}
+		})
+	}
+
+	svc := services.NewSubscriptionService(&mockSubRepo{byID: map[uint]models.Subscription{1: stored}}, nil, testLinks, testTmpl, config.Config{})
+	if _, err := svc.Update("a@b", 42, services.SubscriptionPatch{}); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Errorf("want ErrSubscriptionNotFound, got %v", err)
+	}
+	if _, err := svc.Update("other@b", 1, services.SubscriptionPatch{}); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Errorf("other owner: want ErrSubscriptionNotFound, got %v", err)
+	}
+}
+
+func TestSubscriptionService_Delete(t *testing.T) {
+	mSub := &mockSubRepo{byID: map[uint]models.Subscription{1: {ID: 1, Email: "a@b"}}}
+	svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+
+	if err := svc.Delete("other@b", 1); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Fatalf("other owner: want ErrSubscriptionNotFound, got %v", err)
+	}
+	if err := svc.Delete("A@b", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.Delete("a@b", 1); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Errorf("second delete: want ErrSubscriptionNotFound, got %v", err)
+	}
+}
+
+func TestSubscriptionService_CreateChannel(t *testing.T) {
+	mW := &mockWeatherRepo{exists: true}
+	svc := services.NewSubscriptionService(&mockSubRepo{}, mW, testLinks, testTmpl, config.Config{})
+
+	sub := &models.Subscription{Email: "e@e", City: "C", Channel: models.ChannelSlack}
+	if err := svc.Create(sub); !errors.Is(err, services.ErrInvalidChannel) {
+		t.Errorf("slack without webhook_url: want ErrInvalidChannel, got %v", err)
+	}
+
+	sub = &models.Subscription{Email: "e@e", City: "C"}
+	if err := svc.Create(sub); err != nil || sub.Channel != models.ChannelEmail {
+		t.Errorf("want default email channel, got %q, %v", sub.Channel, err)
+	}
+
+	for _, u := range []string{"file:///etc/passwd", "gopher://h/x", "http://", "hooks.example.com/x"} {
+		sub = &models.Subscription{Email: "e@e", City: "C", Channel: models.ChannelWebhook, WebhookURL: u}
+		if err := svc.Create(sub); !errors.Is(err, services.ErrInvalidChannel) {
+			t.Errorf("webhook_url %q: want ErrInvalidChannel, got %v", u, err)
+		}
+	}
+}
+
+// Кожна підписка отримує власний ключ підпису вебхуків, а ротація його замінює
+func TestSubscriptionService_WebhookSecret(t *testing.T) {
+	mSub := &mockSubRepo{}
+	svc := services.NewSubscriptionService(mSub, &mockWeatherRepo{exists: true}, testLinks, testTmpl, config.Config{})
+
+	a := &models.Subscription{Email: "e@e", City: "C"}
+	b := &models.Subscription{Email: "e@e", City: "D"}
+	if err := svc.Create(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Create(b); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.WebhookSecret) != 64 || a.WebhookSecret == b.WebhookSecret {
+		t.Fatalf("want distinct secrets, got %q and %q", a.WebhookSecret, b.WebhookSecret)
+	}
+
+	mSub.byID = map[uint]models.Subscription{1: *a}
+	secret, err := svc.RotateWebhookSecret("e@e", 1)
+	if err != nil || len(secret) != 64 || secret == a.WebhookSecret {
+		t.Fatalf("want a new secret, got %q, %v", secret, err)
+	}
+	if mSub.columns() != "webhook_secret" || mSub.lastUpdated.WebhookSecret != secret {
+		t.Errorf("want only webhook_secret written, got %v", mSub.lastUpdates)
+	}
+	if _, err := svc.RotateWebhookSecret("e@e", 2); !errors.Is(err, services.ErrSubscriptionNotFound) {
+		t.Errorf("want ErrSubscriptionNotFound, got %v", err)
+	}
+}
+
+// Посилання на керування надсилається лише адресі з підписками і не частіше ResendInterval
+func TestSubscriptionService_RequestManageLink(t *testing.T) {
+	recent := time.Now().Add(-time.Minute)
+	subs := []models.Subscription{{ID: 4, Email: "a@b"}, {ID: 5, Email: "a@b"}}
+
+	cases := []struct {
+		name    string
+		email   string
+		last    *time.Time
+		wantErr error
+		queued  bool
+	}{
+		{"Success", "a@b", nil, nil, true},
+		{"UnknownEmail", "nobody@b", nil, nil, false},
+		{"TooSoon", "a@b", &recent, services.ErrResendTooSoon, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mSub := &mockSubRepo{verifiedList: subs, lastConfirm: tc.last}
+			svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{ResendInterval: 5 * time.Minute})
+
+			wait, err := svc.RequestManageLink(tc.email)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want err %v, got %v", tc.wantErr, err)
+			}
+			if (wait > 0) != (tc.wantErr != nil) {
+				t.Errorf("unexpected retry delay %s", wait)
+			}
+			if !tc.queued {
+				if len(mSub.queued) != 0 {
+					t.Errorf("nothing must be queued, got %+v", mSub.queued)
+				}
+				return
+			}
+			msg := mSub.lastQueued()
+			if len(mSub.queued) != 1 || msg.Kind != notifier.KindManage || msg.SubscriptionID != 4 || msg.Body != "" {
+				t.Errorf("want an empty manage message for subscription 4, got %+v", msg)
+			}
+		})
+	}
+}
+
+// Токен керування з листа дає доступ до підписок своєї адреси, поки не сплив,
+// і не витрачається за використання
+func TestSubscriptionService_Authorize(t *testing.T) {
+	mSub := &mockSubRepo{byID: map[uint]models.Subscription{4: {ID: 4, Email: "a@b", Language: "en"}}}
+	svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+
+	sub := mSub.byID[4]
+	m, err := svc.TokenMessage(context.Background(), &sub, notifier.KindManage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := regexp.MustCompile(`/subscriptions\?token=(\w+)`).FindStringSubmatch(m.Body)
+	if token == nil {
+		t.Fatalf("manage link not found in %q", m.Body)
+	}
+	for i := 0; i < 2; i++ {
+		if email, err := svc.Authorize(token[1]); err != nil || email != "a@b" {
+			t.Fatalf("use %d: want a@b, got %q, %v", i+1, email, err)
+		}
+	}
+
+	if _, err := svc.Authorize("bogus"); !errors.Is(err, services.ErrTokenNotFound) {
+		t.Errorf("unknown token: want ErrTokenNotFound, got %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	putToken(mSub, 4, models.TokenManage, "old", &expired)
+	if _, err := svc.Authorize("old"); !errors.Is(err, services.ErrTokenExpired) {
+		t.Errorf("expired token: want ErrTokenExpired, got %v", err)
+	}
+	putToken(mSub, 4, models.TokenConfirm, "confirm", nil)
+	if _, err := svc.Authorize("confirm"); !errors.Is(err, services.ErrTokenNotFound) {
+		t.Errorf("confirm token must not authorize, got %v", err)
+	}
+}
+
+// Посилання з дайджесту вимикає всі підписки адреси, а чужі не чіпає
+func TestSubscriptionService_UnsubscribeAll(t *testing.T) {
+	mSub := &mockSubRepo{byID: map[uint]models.Subscription{
+		1: {ID: 1, Email: "a@b"},
+		2: {ID: 2, Email: "a@b"},
+		3: {ID: 3, Email: "c@d"},
+	}}
+	svc := services.NewSubscriptionService(mSub, nil, testLinks, testTmpl, config.Config{})
+
+	if _, err := svc.UnsubscribeAll(testLinks.Token(services.LinkUnsubscribe, 1, 0)); !errors.Is(err, services.ErrTokenNotFound) {
+		t.Errorf("single unsubscribe link must not work, got %v", err)
+	}
+	n, err := svc.UnsubscribeAll(testLinks.Token(services.LinkUnsubscribeAll, 2, 0))
+	if err != nil || n != 2 {
+		t.Fatalf("want 2 unsubscribed, got %d, %v", n, err)
+	}
+	if mSub.byID[1].UnsubscribedAt == nil || mSub.byID[3].UnsubscribedAt != nil {
+		t.Errorf("only subscriptions of a@b must be disabled: %+v", mSub.byID)
+	}
+}
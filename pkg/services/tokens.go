@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"myapp/pkg/models"
+	"myapp/pkg/tokens"
+)
+
+// tokenTTL — строк дії токена за призначенням. Відписка й пауза з листа йдуть
+// підписаними посиланнями (signedlink), тож токенів у БД для них немає.
+var tokenTTL = map[string]time.Duration{
+	models.TokenConfirm: 24 * time.Hour,
+	models.TokenManage:  time.Hour,
+}
+
+// newToken створює токен призначення purpose: сам токен — для листа,
+// запис із його хешем — для БД
+func newToken(subID uint, purpose string, now time.Time) (string, *models.SubscriptionToken, error) {
+	ttl, ok := tokenTTL[purpose]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown token purpose %q", purpose)
+	}
+	token, hash, err := tokens.New()
+	if err != nil {
+		return "", nil, fmt.Errorf("generate token: %w", err)
+	}
+	exp := now.Add(ttl)
+	return token, &models.SubscriptionToken{SubscriptionID: subID, Purpose: purpose, Hash: hash, ExpiresAt: &exp}, nil
+}
+
+// issue видає підписці новий токен призначення purpose: зберігає його хеш замість
+// попередніх того самого призначення і повертає сам токен разом із записом.
+// Запис умовний на оренду з ctx (fenceOf), якщо вона є.
+func (s *SubscriptionService) issue(ctx context.Context, subID uint, purpose string) (string, *models.SubscriptionToken, error) {
+	token, tok, err := newToken(subID, purpose, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.SubRepo.ReplaceToken(tok, fenceOf(ctx)); err != nil {
+		log.Printf("issue: failed to save %s token for subscription id=%d, err=%v", purpose, subID, err)
+		return "", nil, err
+	}
+	return token, tok, nil
+}
+
+// redeem шукає одноразовий токен за хешем, перевіряє призначення і строк дії,
+// застосовує apply до підписки і зберігає її разом із видаленням токена
+func (s *SubscriptionService) redeem(purpose, token string, apply func(*models.Subscription)) (*models.Subscription, error) {
+	tok, err := s.lookup(purpose, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.SubRepo.FindByID(tok.SubscriptionID)
+	if err != nil {
+		log.Printf("redeem: subscription id=%d of %s token not found, err=%v", tok.SubscriptionID, purpose, err)
+		return nil, ErrTokenNotFound
+	}
+	apply(&sub)
+	ok, err := s.SubRepo.RedeemToken(tok, &sub)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &sub, nil
+}
+
+// lookup шукає токен за хешем і перевіряє його призначення та строк дії
+func (s *SubscriptionService) lookup(purpose, token string) (*models.SubscriptionToken, error) {
+	tok, err := s.SubRepo.FindToken(tokens.Hash(token))
+	if err != nil {
+		return nil, err
+	}
+	// БД порівнює рядки не за сталий час, тож хеш звіряємо ще раз
+	if tok == nil || tok.Purpose != purpose || !tokens.Match(token, tok.Hash) {
+		return nil, ErrTokenNotFound
+	}
+	if tok.ExpiresAt != nil && time.Now().After(*tok.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return tok, nil
+}
+
+// Authorize перевіряє токен керування з листа і повертає адресу, підписками якої
+// він дозволяє керувати. На відміну від токена підтвердження, він не витрачається
+// і діє до кінця строку, щоб за одним посиланням можна було зробити кілька змін.
+func (s *SubscriptionService) Authorize(token string) (string, error) {
+	tok, err := s.lookup(models.TokenManage, token)
+	if err != nil {
+		return "", err
+	}
+	sub, err := s.SubRepo.FindByID(tok.SubscriptionID)
+	if err != nil {
+		log.Printf("Authorize: subscription id=%d of manage token not found, err=%v", tok.SubscriptionID, err)
+		return "", ErrTokenNotFound
+	}
+	return sub.Email, nil
+}
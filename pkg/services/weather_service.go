@@ -2,17 +2,20 @@ package services
 
 import (
 	"log"
+	"myapp/pkg/events"
 	"myapp/pkg/models"
 	"myapp/pkg/repository"
 )
 
 type WeatherService struct {
 	Repo repository.WeatherRepository
+	// Events отримує WeatherChanged після кожного збереження; nil — без подій
+	Events *events.Bus
 }
 
-func NewWeatherService(r repository.WeatherRepository) *WeatherService {
+func NewWeatherService(r repository.WeatherRepository, bus *events.Bus) *WeatherService {
 	log.Println("Initializing WeatherService")
-	return &WeatherService{Repo: r}
+	return &WeatherService{Repo: r, Events: bus}
 }
 
 func (s *WeatherService) GetCurrentWeather(city string) (models.Weather, error) {
@@ -34,6 +37,7 @@ func (s *WeatherService) SaveWeather(w *models.Weather) error {
 		return err
 	}
 	log.Printf("SaveWeather success for city=%q", w.City)
+	s.Events.Publish(events.WeatherChanged{City: w.City})
 	return nil
 }
 
@@ -55,6 +59,7 @@ func (s *WeatherService) UpdateWeather(city string, inp UpdateInput) (models.Wea
 		return models.Weather{}, err
 	}
 	log.Printf("UpdateWeather success for city=%q: %+v", city, w)
+	s.Events.Publish(events.WeatherChanged{City: city})
 	return w, nil
 }
 
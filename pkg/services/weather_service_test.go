@@ -3,8 +3,10 @@ package services_test
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
+	"myapp/pkg/events"
 	"myapp/pkg/models"
 	"myapp/pkg/services"
 )
@@ -34,7 +36,7 @@ func (s *spyRepo) UpdateWeather(city string, updates map[string]interface{}) err
 
 func TestGetCurrentWeather_Success(t *testing.T) {
 	spy := &spyRepo{returnWeather: models.Weather{Temperature: 1.23, Humidity: 45, Condition: "Fog"}}
-	svc := services.NewWeatherService(spy)
+	svc := services.NewWeatherService(spy, nil)
 
 	w, err := svc.GetCurrentWeather("CityX")
 	if err != nil {
@@ -51,7 +53,7 @@ func TestGetCurrentWeather_Success(t *testing.T) {
 
 func TestGetCurrentWeather_Error(t *testing.T) {
 	spy := &spyRepo{returnErr: errors.New("db fail")}
-	svc := services.NewWeatherService(spy)
+	svc := services.NewWeatherService(spy, nil)
 
 	_, err := svc.GetCurrentWeather("CityY")
 	if err == nil || err.Error() != "db fail" {
@@ -61,7 +63,7 @@ func TestGetCurrentWeather_Error(t *testing.T) {
 
 func TestSaveWeather_Success(t *testing.T) {
 	spy := &spyRepo{returnErr: nil}
-	svc := services.NewWeatherService(spy)
+	svc := services.NewWeatherService(spy, nil)
 	in := &models.Weather{Temperature: 5.5, Humidity: 30, Condition: "Sunny"}
 
 	if err := svc.SaveWeather(in); err != nil {
@@ -74,7 +76,7 @@ func TestSaveWeather_Success(t *testing.T) {
 
 func TestSaveWeather_Error(t *testing.T) {
 	spy := &spyRepo{returnErr: errors.New("save fail")}
-	svc := services.NewWeatherService(spy)
+	svc := services.NewWeatherService(spy, nil)
 
 	if err := svc.SaveWeather(&models.Weather{}); err == nil || err.Error() != "save fail" {
 		t.Fatalf("expected save fail, got %v", err)
@@ -84,7 +86,7 @@ func TestSaveWeather_Error(t *testing.T) {
 func TestUpdateWeather_Success(t *testing.T) {
 	expected := models.Weather{Temperature: 9.99, Humidity: 10, Condition: "Sun"}
 	spy := &spyRepo{returnWeather: expected, updateErr: nil}
-	svc := services.NewWeatherService(spy)
+	svc := services.NewWeatherService(spy, nil)
 	in := services.UpdateInput{Temperature: 9.99, Humidity: 10, Condition: "Sun"}
 
 	out, err := svc.UpdateWeather("CityZ", in)
@@ -106,7 +108,7 @@ func TestUpdateWeather_Success(t *testing.T) {
 
 func TestUpdateWeather_ErrorOnUpdate(t *testing.T) {
 	spy := &spyRepo{updateErr: errors.New("upd fail")}
-	svc := services.NewWeatherService(spy)
+	svc := services.NewWeatherService(spy, nil)
 
 	_, err := svc.UpdateWeather("CityA", services.UpdateInput{})
 	if err == nil || err.Error() != "upd fail" {
@@ -116,10 +118,31 @@ func TestUpdateWeather_ErrorOnUpdate(t *testing.T) {
 
 func TestUpdateWeather_ErrorOnGetAfterUpdate(t *testing.T) {
 	spy := &spyRepo{updateErr: nil, returnErr: errors.New("get fail")}
-	svc := services.NewWeatherService(spy)
+	svc := services.NewWeatherService(spy, nil)
 
 	_, err := svc.UpdateWeather("CityB", services.UpdateInput{})
 	if err == nil || err.Error() != "get fail" {
 		t.Fatalf("expected get fail, got %v", err)
 	}
 }
+
+func TestWeatherService_PublishesChanges(t *testing.T) {
+	bus := events.NewBus()
+	var got []string
+	bus.Subscribe(func(e events.WeatherChanged) { got = append(got, e.City) })
+
+	spy := &spyRepo{}
+	svc := services.NewWeatherService(spy, bus)
+	if err := svc.SaveWeather(&models.Weather{City: "Kyiv"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UpdateWeather("Lviv", services.UpdateInput{}); err != nil {
+		t.Fatal(err)
+	}
+	spy.returnErr = errors.New("save fail")
+	_ = svc.SaveWeather(&models.Weather{City: "Odesa"})
+
+	if strings.Join(got, ",") != "Kyiv,Lviv" {
+		t.Errorf("want events for Kyiv,Lviv only, got %v", got)
+	}
+}
@@ -0,0 +1,89 @@
+// Package signedlink створює та перевіряє підписані посилання (HMAC-SHA256)
+// для дій без входу в систему: відписка, пауза тощо.
+package signedlink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"myapp/pkg/config"
+)
+
+var (
+	// ErrInvalid — токен пошкоджено або підпис не збігається
+	ErrInvalid = errors.New("signedlink: invalid token")
+	// ErrExpired — строк дії токена минув
+	ErrExpired = errors.New("signedlink: token expired")
+	// ErrNoSecret — APP_SECRET не задано
+	ErrNoSecret = errors.New("signedlink: APP_SECRET is empty")
+)
+
+type Signer struct {
+	secret  []byte
+	BaseURL string
+}
+
+// NewSigner створює підписувач із APP_SECRET. Без секрету повертає ErrNoSecret:
+// випадковий ключ у кожного процесу зробив би посилання з листів однієї репліки
+// недійсними для іншої і після перезапуску.
+func NewSigner(cfg config.Config) (*Signer, error) {
+	if cfg.AppSecret == "" {
+		return nil, ErrNoSecret
+	}
+	return &Signer{secret: []byte(cfg.AppSecret), BaseURL: strings.TrimRight(cfg.BaseURL, "/")}, nil
+}
+
+// Token підписує дію purpose для об'єкта id. ttl == 0 — без строку дії.
+// Формат: <id>.<expires unix або 0>.<base64url(hmac)>
+func (s *Signer) Token(purpose string, id uint, ttl time.Duration) string {
+	var exp int64
+	if ttl > 0 {
+		exp = time.Now().Add(ttl).Unix()
+	}
+	payload := fmt.Sprintf("%d.%d", id, exp)
+	return payload + "." + s.mac(purpose, payload)
+}
+
+// Verify перевіряє токен для дії purpose і повертає id об'єкта
+func (s *Signer) Verify(purpose, token string) (uint, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.mac(purpose, payload))) {
+		return 0, ErrInvalid
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+	if exp != 0 && time.Now().Unix() > exp {
+		return 0, ErrExpired
+	}
+	return uint(id), nil
+}
+
+// URL будує абсолютне посилання path?token=... для дії purpose
+func (s *Signer) URL(path, purpose string, id uint, ttl time.Duration) string {
+	return s.BaseURL + path + "?token=" + url.QueryEscape(s.Token(purpose, id, ttl))
+}
+
+func (s *Signer) mac(purpose, payload string) string {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(purpose))
+	m.Write([]byte{0})
+	m.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package signedlink_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/signedlink"
+)
+
+func newSigner(t *testing.T, cfg config.Config) *signedlink.Signer {
+	t.Helper()
+	s, err := signedlink.NewSigner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSigner_RoundTrip(t *testing.T) {
+	s := newSigner(t, config.Config{AppSecret: "k", BaseURL: "http://x/"})
+
+	tok := s.Token("unsubscribe", 42, 0)
+	id, err := s.Verify("unsubscribe", tok)
+	if err != nil || id != 42 {
+		t.Fatalf("want 42, got %d, %v", id, err)
+	}
+
+	if u := s.URL("/subscriptions/unsubscribe", "unsubscribe", 42, 0); !strings.HasPrefix(u, "http://x/subscriptions/unsubscribe?token=42.0.") {
+		t.Errorf("unexpected URL: %s", u)
+	}
+}
+
+func TestSigner_Rejects(t *testing.T) {
+	s := newSigner(t, config.Config{AppSecret: "k"})
+	other := newSigner(t, config.Config{AppSecret: "other"})
+	tok := s.Token("unsubscribe", 7, 0)
+
+	cases := []struct {
+		name    string
+		signer  *signedlink.Signer
+		purpose string
+		token   string
+		want    error
+	}{
+		{"WrongPurpose", s, "snooze", tok, signedlink.ErrInvalid},
+		{"WrongSecret", other, "unsubscribe", tok, signedlink.ErrInvalid},
+		{"TamperedID", s, "unsubscribe", "8" + tok[1:], signedlink.ErrInvalid},
+		{"Garbage", s, "unsubscribe", "abc", signedlink.ErrInvalid},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.signer.Verify(tc.purpose, tc.token)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("want %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestSigner_Expired(t *testing.T) {
+	s := newSigner(t, config.Config{AppSecret: "k"})
+	// строк зберігається з точністю до секунди
+	expiring := s.Token("snooze", 1, time.Millisecond)
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := s.Verify("snooze", expiring); !errors.Is(err, signedlink.ErrExpired) {
+		t.Errorf("want ErrExpired, got %v", err)
+	}
+}
+
+func TestNewSigner_RequiresSecret(t *testing.T) {
+	if _, err := signedlink.NewSigner(config.Config{}); !errors.Is(err, signedlink.ErrNoSecret) {
+		t.Errorf("want ErrNoSecret, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package templates рендерить тексти сповіщень із пар шаблонів
+// text/template (.txt) та html/template (.html).
+//
+// Шаблони лежать у files/<locale>/<name>.txt|.html і вбудовані в бінарник.
+// Каталог TEMPLATE_DIR з тією ж структурою перекриває вбудовані файли за іменем.
+// Тема листа задається в .txt-шаблоні блоком {{define "subject"}}.
+//
+// Тексти беруться з каталогу i18n функцією {{t "key" args...}} мовою отримувача,
+// тож вбудованого набору en достатньо для всіх мов; {{lang}} повертає код мови.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/i18n"
+)
+
+// Імена шаблонів
+const (
+	Confirm = "confirm"
+	Alert   = "alert"
+	Clear   = "clear"
+	Digest  = "digest"
+	Manage  = "manage"
+)
+
+// DefaultLocale використовується, коли шаблону для мови немає
+const DefaultLocale = i18n.Default
+
+// ErrUnknownTemplate повертається, коли шаблону немає ні для мови, ні для DefaultLocale
+var ErrUnknownTemplate = errors.New("templates: unknown template")
+
+//go:embed files
+var embedded embed.FS
+
+// ConfirmData — дані листа підтвердження
+type ConfirmData struct {
+	City       string
+	Condition  string
+	ConfirmURL string
+	ExpiresAt  time.Time
+}
+
+// ManageData — дані листа з посиланням на керування підписками адреси
+type ManageData struct {
+	ManageURL string
+	ExpiresAt time.Time
+}
+
+// AlertData — дані сповіщення та «відбою»
+type AlertData struct {
+	City           string
+	Condition      string
+	Readings       string
+	UnsubscribeURL string
+	// SnoozeURL — посилання на паузу сповіщень; порожнє — без нього
+	SnoozeURL string
+}
+
+// DigestData — дані дайджесту: по розділу на місто, повідомлення в порядку появи
+type DigestData struct {
+	Count    int
+	Sections []DigestSection
+}
+
+type DigestSection struct {
+	City  string
+	Items []DigestItem
+}
+
+// DigestItem — одне сповіщення дайджесту: його тема і текст мовою підписки
+type DigestItem struct {
+	Subject string
+	Text    string
+}
+
+// Rendered — результат рендерингу; HTML порожній, якщо .html-шаблону немає
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Renderer struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// New завантажує вбудовані шаблони, перекриті файлами з cfg.TemplateDir
+func New(cfg config.Config) (*Renderer, error) {
+	base, err := fs.Sub(embedded, "files")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TemplateDir == "" {
+		return Load(base)
+	}
+	return Load(overlay{top: os.DirFS(cfg.TemplateDir), base: base})
+}
+
+// Load розбирає всі шаблони <locale>/<name>.txt і необов'язкові .html з fsys
+func Load(fsys fs.FS) (*Renderer, error) {
+	names, err := fs.Glob(fsys, "*/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	r := &Renderer{
+		text: map[string]*texttemplate.Template{},
+		html: map[string]*htmltemplate.Template{},
+	}
+	for _, name := range names {
+		key := strings.TrimSuffix(name, ".txt")
+
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		t, err := texttemplate.New(key).Option("missingkey=error").
+			Funcs(funcs(DefaultLocale)).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("templates: %s: %w", name, err)
+		}
+		if t.Lookup("subject") == nil {
+			return nil, fmt.Errorf("templates: %s: missing {{define \"subject\"}}", name)
+		}
+		r.text[key] = t
+
+		src, err = fs.ReadFile(fsys, key+".html")
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		h, err := htmltemplate.New(key).Option("missingkey=error").
+			Funcs(funcs(DefaultLocale)).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("templates: %s.html: %w", key, err)
+		}
+		r.html[key] = h
+	}
+	return r, nil
+}
+
+// Render рендерить шаблон name мовою locale. Файл шаблону береться для locale
+// або, якщо його немає, для DefaultLocale; тексти {{t}} — завжди мовою locale.
+func (r *Renderer) Render(locale, name string, data interface{}) (Rendered, error) {
+	lang := i18n.Normalize(locale)
+	if lang == "" {
+		lang = DefaultLocale
+	}
+	key := path.Join(lang, name)
+	if _, ok := r.text[key]; !ok {
+		key = path.Join(DefaultLocale, name)
+	}
+	t, ok := r.text[key]
+	if !ok {
+		return Rendered{}, fmt.Errorf("%w: %q", ErrUnknownTemplate, name)
+	}
+
+	// Клон із функціями потрібної мови; оригінал лишається невиконаним,
+	// бо html/template не дозволяє клонувати вже виконаний шаблон
+	t, err := t.Clone()
+	if err != nil {
+		return Rendered{}, err
+	}
+	t.Funcs(funcs(lang))
+
+	var subject, text, html bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Rendered{}, err
+	}
+	if err := t.Execute(&text, data); err != nil {
+		return Rendered{}, err
+	}
+	out := Rendered{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+	}
+	if h, ok := r.html[key]; ok {
+		h, err := h.Clone()
+		if err != nil {
+			return Rendered{}, err
+		}
+		if err := h.Funcs(funcs(lang)).Execute(&html, data); err != nil {
+			return Rendered{}, err
+		}
+		out.HTML = html.String()
+	}
+	return out, nil
+}
+
+// funcs повертає функції шаблонів для мови lang
+func funcs(lang string) map[string]interface{} {
+	return map[string]interface{}{
+		"t": func(key string, args ...interface{}) string {
+			return i18n.T(lang, key, args...)
+		},
+		"lang": func() string { return lang },
+	}
+}
+
+// overlay читає файл спершу з top, а якщо його там немає — з base
+type overlay struct {
+	top, base fs.FS
+}
+
+func (o overlay) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
+
+// Glob об'єднує збіги з обох шарів
+func (o overlay) Glob(pattern string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, fsys := range []fs.FS{o.top, o.base} {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+	return out, nil
+}
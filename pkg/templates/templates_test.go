@@ -0,0 +1,179 @@
+package templates_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/i18n"
+	"myapp/pkg/templates"
+)
+
+var update = flag.Bool("update", false, "перезаписати golden-файли в testdata")
+
+var expires = time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+
+var goldenCases = []struct {
+	name string
+	data interface{}
+}{
+	{templates.Confirm, templates.ConfirmData{
+		City:       "Kyiv",
+		Condition:  "temp < 0",
+		ConfirmURL: "http://alerts.test/subscriptions/confirm?token=abc",
+		ExpiresAt:  expires,
+	}},
+	{templates.Manage, templates.ManageData{
+		ManageURL: "http://alerts.test/subscriptions?token=abc",
+		ExpiresAt: expires,
+	}},
+	{templates.Alert, templates.AlertData{
+		City:           "Kyiv",
+		Condition:      "temp < 0 && humidity > 80",
+		Readings:       "temp -3.0°C, humidity 95%",
+		UnsubscribeURL: "http://alerts.test/subscriptions/unsubscribe?token=xyz",
+		SnoozeURL:      "http://alerts.test/subscriptions/snooze?token=abc",
+	}},
+	{templates.Clear, templates.AlertData{
+		City:           "Kyiv",
+		Condition:      "temp < 0",
+		Readings:       "temp 1.5°C",
+		UnsubscribeURL: "http://alerts.test/subscriptions/unsubscribe?token=xyz",
+		SnoozeURL:      "http://alerts.test/subscriptions/snooze?token=abc",
+	}},
+	{templates.Digest, templates.DigestData{
+		Count: 3,
+		Sections: []templates.DigestSection{
+			{City: "Kyiv", Items: []templates.DigestItem{
+				{Subject: "Weather Alert for Kyiv", Text: "Condition temp < 0 met: current temp -3.0°C\n\nUnsubscribe: http://alerts.test/u?token=a"},
+				{Subject: "All clear for Kyiv", Text: "Condition temp < 0 no longer holds: current temp 1.0°C\n\nUnsubscribe: http://alerts.test/u?token=a"},
+			}},
+			{City: "Lviv", Items: []templates.DigestItem{
+				{Subject: "Weather Alert for Lviv", Text: "Condition rain met: current condition Rain\n\nUnsubscribe: http://alerts.test/u?token=b"},
+			}},
+		},
+	}},
+}
+
+func TestRender_Golden(t *testing.T) {
+	r, err := templates.New(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range i18n.Supported {
+		for _, tc := range goldenCases {
+			t.Run(lang+"/"+tc.name, func(t *testing.T) {
+				out, err := r.Render(lang, tc.name, tc.data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if out.Subject == "" || strings.Contains(out.Subject, "\n") {
+					t.Errorf("subject = %q, want a single non-empty line", out.Subject)
+				}
+				golden(t, lang+"_"+tc.name+".txt", "Subject: "+out.Subject+"\n\n"+out.Text+"\n")
+				golden(t, lang+"_"+tc.name+".html", out.HTML)
+			})
+		}
+	}
+}
+
+func TestRender_EscapesHTML(t *testing.T) {
+	r, err := templates.New(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render("en", templates.Alert, templates.AlertData{City: "Kyiv", Condition: `condition == "<b>"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.HTML, "<b>") {
+		t.Errorf("html not escaped: %s", out.HTML)
+	}
+	if !strings.Contains(out.Text, `condition == "<b>"`) {
+		t.Errorf("text must stay verbatim, got %q", out.Text)
+	}
+}
+
+func TestRender_FallsBackToDefaultLocale(t *testing.T) {
+	r, err := templates.New(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := r.Render(templates.DefaultLocale, templates.Confirm, goldenCases[0].data)
+	got, err := r.Render("de-DE", templates.Confirm, goldenCases[0].data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want default locale %+v", got, want)
+	}
+	if _, err := r.Render("en", "nope", nil); err == nil {
+		t.Error("expected error for unknown template")
+	}
+}
+
+func TestNew_OverrideDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	src := `{{define "subject"}}Custom {{.City}}{{end}}Custom body {{.Readings}}`
+	if err := os.WriteFile(filepath.Join(dir, "en", "alert.txt"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := templates.New(config.Config{TemplateDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render("en", templates.Alert, templates.AlertData{City: "Lviv", Readings: "temp 1.0°C"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Subject != "Custom Lviv" || out.Text != "Custom body temp 1.0°C" {
+		t.Errorf("override not applied: %+v", out)
+	}
+	// html-шаблон не перекрито — лишається вбудований
+	if !strings.Contains(out.HTML, "Weather alert for Lviv") {
+		t.Errorf("embedded html expected, got %q", out.HTML)
+	}
+	// решта шаблонів береться з вбудованих
+	if _, err := r.Render("en", templates.Confirm, goldenCases[0].data); err != nil {
+		t.Errorf("embedded confirm: %v", err)
+	}
+}
+
+func TestNew_RejectsTemplateWithoutSubject(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "en", "alert.txt"), []byte("no subject"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := templates.New(config.Config{TemplateDir: dir}); err == nil {
+		t.Error("expected error for template without subject")
+	}
+}
+
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s mismatch\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+	}
+}
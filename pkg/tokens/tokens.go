@@ -0,0 +1,44 @@
+// Package tokens генерує одноразові токени для посилань із листів.
+// Сам токен потрапляє лише в лист, а в БД зберігається його SHA-256,
+// тож доступ на читання до БД не дає змоги скористатися токеном.
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// size — кількість випадкових байтів токена
+const size = 32
+
+// New повертає новий випадковий токен і його хеш для зберігання
+func New() (token, hash string, err error) {
+	token, err = Secret()
+	if err != nil {
+		return "", "", err
+	}
+	return token, Hash(token), nil
+}
+
+// Secret повертає випадковий ключ, який зберігається як є, бо потрібен
+// сервісу самому (напр. ключ підпису вебхуків підписки)
+func Secret() (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Hash повертає SHA-256 токена в hex — за ним токен шукається в БД
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Match порівнює токен зі збереженим хешем за сталий час
+func Match(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(token)), []byte(hash)) == 1
+}
@@ -0,0 +1,36 @@
+package tokens_test
+
+import (
+	"testing"
+
+	"myapp/pkg/tokens"
+)
+
+func TestNew(t *testing.T) {
+	tok, hash, err := tokens.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tok) != 64 || len(hash) != 64 || tok == hash {
+		t.Fatalf("unexpected token %q, hash %q", tok, hash)
+	}
+	if hash != tokens.Hash(tok) || !tokens.Match(tok, hash) {
+		t.Error("hash must match the token")
+	}
+
+	other, _, _ := tokens.New()
+	if other == tok {
+		t.Error("tokens must be random")
+	}
+	if tokens.Match(other, hash) || tokens.Match(hash, hash) {
+		t.Error("neither another token nor the hash itself may match")
+	}
+}
+
+func TestHash_Known(t *testing.T) {
+	// sha256("abc")
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := tokens.Hash("abc"); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
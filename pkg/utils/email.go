@@ -9,18 +9,34 @@ import (
 	"gopkg.in/gomail.v2"
 )
 
-var SendEmail = func(to, subject, body string) error {
+// Email — лист із довільними додатковими заголовками (напр. List-Unsubscribe).
+// Якщо задано HTML, лист надсилається як multipart/alternative з Body як текстовою частиною.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+	Headers map[string]string
+}
+
+var SendMessage = func(e Email) error {
 	from := config.NewConfig().SMTPUser
 	if from == "" {
 		from = "weather-alert@localhost"
 	}
-	log.Printf("→ Sending email to %s | from=%s | subject=%q", to, from, subject)
+	log.Printf("→ Sending email to %s | from=%s | subject=%q", e.To, from, e.Subject)
 
 	m := gomail.NewMessage()
 	m.SetHeader("From", from)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/plain", body)
+	m.SetHeader("To", e.To)
+	m.SetHeader("Subject", e.Subject)
+	for k, v := range e.Headers {
+		m.SetHeader(k, v)
+	}
+	m.SetBody("text/plain", e.Body)
+	if e.HTML != "" {
+		m.AddAlternative("text/html", e.HTML)
+	}
 
 	cfg := config.NewConfig()
 	port, _ := strconv.Atoi(cfg.SMTPPort)
@@ -28,9 +44,9 @@ var SendEmail = func(to, subject, body string) error {
 	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
 
 	if err := d.DialAndSend(m); err != nil {
-		log.Printf("✗ Error sending email to %s: %v", to, err)
+		log.Printf("✗ Error sending email to %s: %v", e.To, err)
 		return err
 	}
-	log.Printf("✓ Email successfully sent to %s", to)
+	log.Printf("✓ Email successfully sent to %s", e.To)
 	return nil
 }
@@ -1,19 +1,37 @@
 package validation
 
 import (
-	"regexp"
+	"errors"
 
-	"github.com/go-playground/validator/v10"
-)
+	"myapp/pkg/condition"
+	"myapp/pkg/i18n"
 
-var (
-	tempRe = regexp.MustCompile(`^temp\s*(<=|>=|<|>|==|=|!=)\s*[0-9]+(?:\.[0-9]+)?$`)
-	condRe = regexp.MustCompile(`^condition\s*=\s*[A-Za-z]+$`)
+	"github.com/go-playground/validator/v10"
 )
 
+// RegisterConditionValidator реєструє тег `condition`, який перевіряє рядок
+// тим самим парсером, що й сповіщувач
 func RegisterConditionValidator(v *validator.Validate) {
 	v.RegisterValidation("condition", func(fl validator.FieldLevel) bool {
-		s := fl.Field().String()
-		return tempRe.MatchString(s) || condRe.MatchString(s)
+		_, err := condition.Parse(fl.Field().String())
+		return err == nil
 	})
 }
+
+// Describe повертає зрозумілу помилку біндингу з ключем каталогу i18n.
+// Для тегу `condition` повідомлення містить позицію та очікуваний токен.
+func Describe(err error) error {
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		for _, fe := range ve {
+			if fe.Tag() != "condition" {
+				continue
+			}
+			src, _ := fe.Value().(string)
+			if _, perr := condition.Parse(src); perr != nil {
+				return i18n.Wrap(err, i18n.MsgInvalidCondition, src, perr)
+			}
+		}
+	}
+	return i18n.Wrap(err, i18n.MsgInvalidRequest, err.Error())
+}
@@ -0,0 +1,130 @@
+package validation_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/pkg/config"
+	"myapp/pkg/i18n"
+	"myapp/pkg/models"
+	"myapp/pkg/services"
+	"myapp/pkg/signedlink"
+	"myapp/pkg/templates"
+	"myapp/pkg/validation"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type emptyHistory struct{}
+
+func (emptyHistory) ReadingsSince(string, time.Time) ([]models.WeatherReading, error) {
+	return nil, nil
+}
+func (emptyHistory) LastReadingBefore(string, time.Time) (*models.WeatherReading, error) {
+	return nil, nil
+}
+func (emptyHistory) ReadingsBetween(string, time.Time, time.Time, int) ([]models.WeatherReading, error) {
+	return nil, nil
+}
+func (emptyHistory) DeleteReadingsBefore(time.Time) (int64, error) { return 0, nil }
+
+// Кожен рядок, який приймає валідатор, має обчислюватися сповіщувачем без помилки
+func TestConditionValidator_AcceptedEvaluates(t *testing.T) {
+	v := validator.New()
+	validation.RegisterConditionValidator(v)
+
+	tests := []struct {
+		cond   string
+		accept bool
+	}{
+		{"temp<0", true},
+		{"temp >= 12.5", true},
+		{"temp == -3", true},
+		{"condition=Rain", true},
+		{"condition = \"Light Rain\"", true},
+		{"rain", true},
+		{"RAIN", true},
+		{"humidity > 90", true},
+		{"temp < 0 AND (condition = Snow OR humidity > 90)", true},
+		{"NOT condition = Clear", true},
+		{"temp < 0 FOR 3h", true},
+		{"delta(temp, 6h) <= -10", true},
+		{"DELTA(humidity,1h)>20", true},
+		{"delta(temp, 90m) < -5 AND temp < 0", true},
+		{"delta(temp, 1h) > 3 FOR 2h", true},
+
+		{"", false},
+		{"snow", false},
+		{"temp < abc", false},
+		{"condition > Rain", false},
+		{"temp < 0 AND", false},
+		{"(temp < 0", false},
+		{"temp < 0 FOR", false},
+		{"delta(condition, 1h) > 1", false},
+		{"delta(temp 1h) > 1", false},
+		{"delta(temp, 1) > 1", false},
+		{"delta(temp, 1h) > Snow", false},
+		{"delta(humidity, 1h) > 150", false},
+	}
+
+	tmpl, err := templates.New(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := signedlink.NewSigner(config.Config{AppSecret: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := services.NewNotifyService(emptyHistory{}, nil, links, tmpl, nil)
+	w := models.Weather{City: "C", Temperature: 1, Humidity: 50, Condition: "Rain"}
+	for _, tc := range tests {
+		t.Run(tc.cond, func(t *testing.T) {
+			accepted := v.Var(tc.cond, "condition") == nil
+			if accepted != tc.accept {
+				t.Fatalf("want accept=%v, got %v", tc.accept, accepted)
+			}
+			if !accepted {
+				return
+			}
+			sub := models.Subscription{Email: "a@b", City: "C", Condition: tc.cond}
+			if _, err := ns.Evaluate(&sub, w); err != nil {
+				t.Errorf("validator accepted %q, but evaluation failed: %v", tc.cond, err)
+			}
+		})
+	}
+}
+
+func TestDescribe_ReportsPositionAndExpected(t *testing.T) {
+	v := validator.New()
+	v.SetTagName("binding")
+	validation.RegisterConditionValidator(v)
+
+	sub := models.Subscription{Email: "a@b.c", City: "C", Condition: "temp < abc"}
+	err := v.Struct(sub)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	msg := validation.Describe(err).Error()
+	if !strings.Contains(msg, "position 7") || !strings.Contains(msg, "expected number") {
+		t.Errorf("unexpected message: %q", msg)
+	}
+	if uk := i18n.Message(i18n.UK, validation.Describe(err), i18n.MsgInternal); !strings.HasPrefix(uk, `некоректна умова "temp < abc"`) {
+		t.Errorf("unexpected uk message: %q", uk)
+	}
+}
+
+func TestDescribe_PatchCondition(t *testing.T) {
+	v := validator.New()
+	v.SetTagName("binding")
+	validation.RegisterConditionValidator(v)
+
+	bad := "temp <"
+	if err := v.Struct(services.SubscriptionPatch{}); err != nil {
+		t.Fatalf("empty patch must be valid, got %v", err)
+	}
+	msg := validation.Describe(v.Struct(services.SubscriptionPatch{Condition: &bad})).Error()
+	if !strings.Contains(msg, "expected number") {
+		t.Errorf("unexpected message: %q", msg)
+	}
+}